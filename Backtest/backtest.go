@@ -0,0 +1,259 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	loadenv "learnGoLang/LoadEnv"
+	positionmanager "learnGoLang/PositionManager"
+	risk "learnGoLang/Risk"
+	strategy "learnGoLang/Strategy"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultEquity is the account size of runs without InitialEquity
+const defaultEquity = 10000
+
+// Params holds the execution settings used by a backtest run. The entry and exit
+// signals come from the strategy passed to Run, while the stops, the target and the
+// holding time limit are applied by a position manager and the entries are sized by a
+// risk manager, as in live trading.
+type Params struct {
+	positionmanager.Config
+	Risk              risk.Config
+	InitialEquity     float64 // Account size in the quote currency, 0 starts with 10000
+	CommissionPercent float64 // Charged on the entry and again on the exit notional
+	SlippagePoints    float64 // Price points lost on every fill
+	StructuralStops   bool    // The strategy gives every entry a stop, such as the swing stops of SWING_STOP_BARS
+	EntryInterval     time.Duration
+}
+
+// Trade is one closed position in the trade log
+type Trade struct {
+	Symbol     string    `json:"symbol"`
+	Side       string    `json:"side"`
+	EntryTime  time.Time `json:"entry_time"`
+	ExitTime   time.Time `json:"exit_time"`
+	EntryPrice float64   `json:"entry_price"`
+	ExitPrice  float64   `json:"exit_price"`
+	EntryFee   float64   `json:"entry_fee"` // Commission paid per unit when opening
+	ExitFee    float64   `json:"exit_fee"`  // Commission paid per unit when closing
+	Fees       float64   `json:"fees"`      // EntryFee + ExitFee
+	PnL        float64   `json:"pnl"`       // Net profit per unit after fees and slippage
+	PnLPct     float64   `json:"pnl_pct"`   // Net profit relative to the entry price, in percent
+	Quantity   float64   `json:"quantity"`
+	Profit     float64   `json:"profit"`     // PnL * Quantity, in the quote currency
+	ReturnPct  float64   `json:"return_pct"` // Profit relative to the equity before the trade, in percent
+	ExitReason string    `json:"exit_reason"`
+}
+
+// Result summarizes a backtest run
+type Result struct {
+	Trades         []Trade
+	Wins           int
+	Losses         int
+	TotalReturnPct float64 // Return of the account equity
+	FinalEquity    float64
+	RefusedEntries int // Entry signals refused for a stop wider than MAX_ALLOWED_SL_PCT or by the risk limits
+}
+
+// Exit reasons written to the trade log
+const (
+	ExitStopLoss     = positionmanager.ExitStopLoss
+	ExitTakeProfit   = positionmanager.ExitTakeProfit
+	ExitTrailingStop = positionmanager.ExitTrailingStop
+	ExitSignal       = positionmanager.ExitSignal
+	ExitMaxHold      = positionmanager.ExitMaxHold
+	ExitEndOfData    = "end_of_data"
+)
+
+// ParamsFromConfig builds backtest parameters from the strategy configuration
+func ParamsFromConfig(cfg loadenv.StrategyConfig) Params {
+	return Params{
+		Config:            positionmanager.ConfigFromStrategy(cfg),
+		CommissionPercent: cfg.CommissionPercent,
+		SlippagePoints:    cfg.SlippagePoints,
+		StructuralStops:   cfg.SwingStopBars > 0,
+		EntryInterval:     time.Duration(cfg.EntryTFMinutes) * time.Minute,
+	}
+}
+
+// Validate checks that the parameters describe a usable simulation
+func (p Params) Validate() error {
+	if err := p.Config.Validate(); err != nil {
+		return err
+	}
+	if err := p.Risk.Validate(); err != nil {
+		return err
+	}
+	if err := p.Risk.ValidateStops(p.StopLossPct, p.StructuralStops); err != nil {
+		return err
+	}
+	if p.CommissionPercent < 0 || p.SlippagePoints < 0 || p.InitialEquity < 0 {
+		return fmt.Errorf("commission, slippage and initial equity must not be negative")
+	}
+	return nil
+}
+
+// Run simulates the strategy bar by bar over the given candles. The strategy sees
+// every candle and its signals are filled at the close of the bar that produced them,
+// while stops and targets are checked against the highs and lows of the following bars.
+// The candles are expected to be at the entry timeframe (see timeframe.Resample) and
+// the strategy must be new, since it keeps the state of the run.
+func Run(candles []klinesfrombinance.Candle, strat strategy.Strategy, p Params) (Result, error) {
+	if err := p.Validate(); err != nil {
+		return Result{}, err
+	}
+
+	var result Result
+	manager := positionmanager.NewManager(p.Config)
+	sizer := risk.NewManager(p.Risk)
+	initialEquity := p.InitialEquity
+	if initialEquity == 0 {
+		initialEquity = defaultEquity
+	}
+	equity, quantity := initialEquity, 0.0
+
+	openPosition := func(i int, sig strategy.Signal) error {
+		c := candles[i]
+		side := sideOf(sig.Action)
+		entry := c.Close + float64(side)*p.SlippagePoints
+		if err := manager.CheckEntry(side, entry, sig.StopLoss); err != nil {
+			result.RefusedEntries++
+			return nil
+		}
+		size, err := sizer.Size(risk.Entry{Symbol: c.Symbol, Time: c.Datetime, Price: entry, StopLoss: manager.StopLevel(side, entry, sig.StopLoss), Equity: equity})
+		if errors.Is(err, risk.ErrRefused) {
+			result.RefusedEntries++
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		quantity = size
+		manager.Open(side, c.Datetime, entry, sig.StopLoss)
+		sizer.Opened(c.Symbol, quantity*entry)
+		strat.OnFill(strategy.Fill{Action: sig.Action, Time: c.Datetime, Price: entry, Quantity: quantity})
+		return nil
+	}
+
+	closePosition := func(i int, exitPrice float64, reason string) {
+		c := candles[i]
+		pos := manager.Position()
+		exitPrice -= float64(pos.Side) * p.SlippagePoints
+		entryFee := pos.EntryPrice * p.CommissionPercent / 100
+		exitFee := exitPrice * p.CommissionPercent / 100
+		pnl := float64(pos.Side)*(exitPrice-pos.EntryPrice) - entryFee - exitFee
+		profit := pnl * quantity
+		side := "long"
+		if pos.Side < 0 {
+			side = "short"
+		}
+		result.Trades = append(result.Trades, Trade{
+			Symbol:     c.Symbol,
+			Side:       side,
+			EntryTime:  pos.EntryTime,
+			ExitTime:   c.Datetime,
+			EntryPrice: pos.EntryPrice,
+			ExitPrice:  exitPrice,
+			EntryFee:   entryFee,
+			ExitFee:    exitFee,
+			Fees:       entryFee + exitFee,
+			PnL:        pnl,
+			PnLPct:     pnl / pos.EntryPrice * 100,
+			Quantity:   quantity,
+			Profit:     profit,
+			ReturnPct:  profit / equity * 100,
+			ExitReason: reason,
+		})
+		equity += profit
+		manager.Close()
+		sizer.Closed(c.Symbol, c.Datetime, profit)
+		strat.OnFill(strategy.Fill{Action: strategy.Exit, Time: c.Datetime, Price: exitPrice, Quantity: quantity})
+	}
+
+	for i, c := range candles {
+		strat.OnCandle(c)
+		sizer.OnCandle(c)
+
+		if exit, ok := manager.Check(c, strat.Signals()); ok {
+			closePosition(i, exit.Price, exit.Reason)
+		}
+		if manager.Position() != nil {
+			continue
+		}
+
+		// Flat, possibly after an exit on this bar: ask again for an entry
+		for _, sig := range strat.Signals() {
+			if sig.Action == strategy.EnterLong || sig.Action == strategy.EnterShort {
+				if err := openPosition(i, sig); err != nil {
+					return Result{}, err
+				}
+				break
+			}
+		}
+	}
+
+	if manager.Position() != nil {
+		last := len(candles) - 1
+		closePosition(last, candles[last].Close, ExitEndOfData)
+	}
+
+	for _, t := range result.Trades {
+		if t.PnL > 0 {
+			result.Wins++
+		} else {
+			result.Losses++
+		}
+	}
+	result.FinalEquity = equity
+	result.TotalReturnPct = (equity/initialEquity - 1) * 100
+	return result, nil
+}
+
+// sideOf returns 1 for a long entry and -1 for a short entry
+func sideOf(action strategy.Action) int {
+	if action == strategy.EnterShort {
+		return -1
+	}
+	return 1
+}
+
+// WriteTradeLog writes the trades to a CSV file
+func WriteTradeLog(filePath string, trades []Trade) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create trade log: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"symbol", "side", "entry_time", "exit_time", "entry_price", "exit_price", "entry_fee", "exit_fee", "fees", "pnl", "pnl_pct", "quantity", "profit", "return_pct", "exit_reason"})
+	for _, t := range trades {
+		writer.Write([]string{
+			t.Symbol,
+			t.Side,
+			t.EntryTime.Format("2006-01-02 15:04:05"),
+			t.ExitTime.Format("2006-01-02 15:04:05"),
+			strconv.FormatFloat(t.EntryPrice, 'f', 8, 64),
+			strconv.FormatFloat(t.ExitPrice, 'f', 8, 64),
+			strconv.FormatFloat(t.EntryFee, 'f', 8, 64),
+			strconv.FormatFloat(t.ExitFee, 'f', 8, 64),
+			strconv.FormatFloat(t.Fees, 'f', 8, 64),
+			strconv.FormatFloat(t.PnL, 'f', 8, 64),
+			strconv.FormatFloat(t.PnLPct, 'f', 4, 64),
+			strconv.FormatFloat(t.Quantity, 'f', 8, 64),
+			strconv.FormatFloat(t.Profit, 'f', 8, 64),
+			strconv.FormatFloat(t.ReturnPct, 'f', 4, 64),
+			t.ExitReason,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("error writing trade log: %w", err)
+	}
+	return nil
+}
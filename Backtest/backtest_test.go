@@ -0,0 +1,442 @@
+package backtest
+
+import (
+	"encoding/json"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	risk "learnGoLang/Risk"
+	strategy "learnGoLang/Strategy"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// makeCandles builds 15m candles whose open, high and low sit around the given closes
+func makeCandles(closes []float64) []klinesfrombinance.Candle {
+	start := time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC)
+	candles := make([]klinesfrombinance.Candle, len(closes))
+	for i, c := range closes {
+		open := c
+		if i > 0 {
+			open = closes[i-1]
+		}
+		dt := start.Add(time.Duration(i) * 15 * time.Minute)
+		candles[i] = klinesfrombinance.Candle{
+			Symbol:    "ETHUSDT",
+			Timestamp: dt.UnixMilli(),
+			Datetime:  dt,
+			Date:      dt.Format("2006-01-02"),
+			Hour:      dt.Hour(),
+			Open:      open,
+			High:      math.Max(open, c) + 0.5,
+			Low:       math.Min(open, c) - 0.5,
+			Close:     c,
+			Volume:    1,
+		}
+	}
+	return candles
+}
+
+// vShape falls for n bars and then rises for n bars
+func vShape(n int, start, step float64) []float64 {
+	closes := make([]float64, 0, 2*n)
+	price := start
+	for i := 0; i < n; i++ {
+		closes = append(closes, price)
+		price -= step
+	}
+	for i := 0; i < n; i++ {
+		closes = append(closes, price)
+		price += step
+	}
+	return closes
+}
+
+// testSetup is the strategy and execution settings of a test run
+type testSetup struct {
+	strategy.MACDParams
+	Params
+}
+
+func testParams() testSetup {
+	return testSetup{MACDParams: strategy.MACDParams{FastLength: 3, SlowLength: 6, SignalLength: 3}}
+}
+
+// run backtests a new MACD strategy
+func run(candles []klinesfrombinance.Candle, s testSetup) (Result, error) {
+	strat, err := strategy.NewMACD(s.MACDParams)
+	if err != nil {
+		return Result{}, err
+	}
+	return Run(candles, strat, s.Params)
+}
+
+func TestRunEntersOnCrossAndExitsAtEnd(t *testing.T) {
+	candles := makeCandles(vShape(30, 2000, 5))
+	result, err := run(candles, testParams())
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if len(result.Trades) != 1 {
+		t.Fatalf("got %d trades, want 1", len(result.Trades))
+	}
+	trade := result.Trades[0]
+	if trade.ExitReason != ExitEndOfData {
+		t.Errorf("exit reason = %s, want %s", trade.ExitReason, ExitEndOfData)
+	}
+	if trade.PnL <= 0 || result.Wins != 1 {
+		t.Errorf("expected a winning trade, got PnL %f", trade.PnL)
+	}
+}
+
+func TestRunAppliesFeesAndSlippage(t *testing.T) {
+	candles := makeCandles(vShape(30, 2000, 5))
+	clean, _ := run(candles, testParams())
+
+	p := testParams()
+	p.CommissionPercent = 0.1
+	p.SlippagePoints = 2
+	costly, err := run(candles, p)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	c, d := clean.Trades[0], costly.Trades[0]
+	if d.EntryPrice != c.EntryPrice+2 || d.ExitPrice != c.ExitPrice-2 {
+		t.Errorf("slippage not applied: entry %f/%f exit %f/%f", c.EntryPrice, d.EntryPrice, c.ExitPrice, d.ExitPrice)
+	}
+	wantFees := (d.EntryPrice + d.ExitPrice) * 0.001
+	if math.Abs(d.Fees-wantFees) > 1e-9 {
+		t.Errorf("fees = %f, want %f", d.Fees, wantFees)
+	}
+	if math.Abs(d.PnL-(d.ExitPrice-d.EntryPrice-wantFees)) > 1e-9 {
+		t.Errorf("PnL = %f does not account for fees", d.PnL)
+	}
+}
+
+func TestRunTakeProfit(t *testing.T) {
+	closes := vShape(30, 2000, 5)
+	p := testParams()
+	p.TakeProfitPct = 1
+	result, err := run(makeCandles(closes), p)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if len(result.Trades) == 0 || result.Trades[0].ExitReason != ExitTakeProfit {
+		t.Fatalf("expected a take profit exit, got %+v", result.Trades)
+	}
+	trade := result.Trades[0]
+	if trade.ExitPrice < trade.EntryPrice*1.01 {
+		t.Errorf("exit price %f below take profit target", trade.ExitPrice)
+	}
+}
+
+func TestRunSizesEntriesByRisk(t *testing.T) {
+	candles := makeCandles(vShape(30, 2000, 5))
+	p := testParams()
+	p.StopLossPct = 4
+	p.InitialEquity = 1000
+	p.Risk = risk.Config{Sizing: risk.RiskPerTrade, RiskPct: 1}
+	result, err := run(candles, p)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if len(result.Trades) != 1 {
+		t.Fatalf("got %d trades, want 1", len(result.Trades))
+	}
+
+	// Losing 1% of 1000 at a stop 4% away invests 250
+	trade := result.Trades[0]
+	if math.Abs(trade.Quantity*trade.EntryPrice-250) > 1e-9 {
+		t.Errorf("invested %f, want 250", trade.Quantity*trade.EntryPrice)
+	}
+	if math.Abs(result.FinalEquity-(1000+trade.Profit)) > 1e-9 || math.Abs(result.TotalReturnPct-trade.Profit/10) > 1e-9 {
+		t.Errorf("final equity %f and return %f%% do not match the profit %f", result.FinalEquity, result.TotalReturnPct, trade.Profit)
+	}
+
+	p.Risk.DailyLossLimitPct = -1
+	if _, err := run(candles, p); err == nil {
+		t.Error("expected an error for a negative daily loss limit")
+	}
+
+	// Without STOP_LOSS_PCT the swing stops of the strategy give the distance to size by
+	p.Risk.DailyLossLimitPct = 0
+	p.StopLossPct = 0
+	if _, err := run(candles, p); err == nil {
+		t.Error("expected an error for risk_per_trade sizing without a stop")
+	}
+	p.SwingStopBars = 20
+	p.StructuralStops = true
+	if result, err := run(candles, p); err != nil || len(result.Trades) != 1 {
+		t.Errorf("got %d trades and error %v sizing by the swing stops, want 1 trade", len(result.Trades), err)
+	}
+}
+
+func TestRunRefusesWideSwingStops(t *testing.T) {
+	// The entry at 1855 carries the bottom of the V at 1849.5 as its stop, 0.3% away
+	candles := makeCandles(vShape(30, 2000, 5))
+	p := testParams()
+	p.SwingStopBars = 20
+	p.MaxAllowedSLPct = 0.25
+	result, err := run(candles, p)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if len(result.Trades) != 0 || result.RefusedEntries != 1 {
+		t.Errorf("got %d trades and %d refused entries, want the entry refused", len(result.Trades), result.RefusedEntries)
+	}
+
+	p.MaxAllowedSLPct = 0.5
+	if result, _ := run(candles, p); len(result.Trades) != 1 || result.RefusedEntries != 0 {
+		t.Errorf("got %d trades and %d refused entries, want the entry within 0.5%%", len(result.Trades), result.RefusedEntries)
+	}
+}
+
+func TestRunInvalidParams(t *testing.T) {
+	_, err := run(nil, testSetup{MACDParams: strategy.MACDParams{FastLength: 26, SlowLength: 12, SignalLength: 9}})
+	if err == nil {
+		t.Error("expected an error when fast length >= slow length")
+	}
+	p := testParams()
+	p.StopLossPct = -1
+	if _, err := run(nil, p); err == nil {
+		t.Error("expected an error for a negative stop loss")
+	}
+}
+
+func TestWriteTradeLog(t *testing.T) {
+	candles := makeCandles(vShape(30, 2000, 5))
+	result, _ := run(candles, testParams())
+
+	path := filepath.Join(t.TempDir(), "trades.csv")
+	if err := WriteTradeLog(path, result.Trades); err != nil {
+		t.Fatalf("WriteTradeLog returned error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read trade log: %v", err)
+	}
+	if len(data) == 0 {
+		t.Error("trade log is empty")
+	}
+}
+
+func TestRunTrendFilterBlocksEntries(t *testing.T) {
+	// A steady decline keeps the higher timeframe trend down while the tail bounce
+	// produces an entry-timeframe cross up.
+	closes := make([]float64, 0, 400)
+	price := 3000.0
+	for i := 0; i < 380; i++ {
+		closes = append(closes, price)
+		price -= 2
+	}
+	for i := 0; i < 20; i++ {
+		closes = append(closes, price)
+		price += 3
+	}
+	candles := makeCandles(closes)
+
+	unfiltered, err := run(candles, testParams())
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if len(unfiltered.Trades) == 0 {
+		t.Fatal("expected the bounce to produce a trade without the trend filter")
+	}
+
+	p := testParams()
+	p.MACDParams.EntryInterval = 15 * time.Minute
+	p.TrendInterval = 4 * time.Hour
+	filtered, err := run(candles, p)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if len(filtered.Trades) != 0 {
+		t.Errorf("got %d trades, want 0 while the higher timeframe trend is down", len(filtered.Trades))
+	}
+}
+
+func TestRunShortTradesAreSymmetric(t *testing.T) {
+	// An inverted V: the rally gives a long, the fall gives a short once enabled
+	closes := vShape(30, 2000, 5)
+	for i := range closes {
+		closes[i] = 4000 - closes[i]
+	}
+	candles := makeCandles(closes)
+
+	longOnly, err := run(candles, testParams())
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	for _, trade := range longOnly.Trades {
+		if trade.Side != "long" {
+			t.Fatalf("got a %s trade with shorts disabled", trade.Side)
+		}
+	}
+
+	p := testParams()
+	p.EnableShortTrades = true
+	p.SlippagePoints = 1
+	result, err := run(candles, p)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	var short *Trade
+	for i := range result.Trades {
+		if result.Trades[i].Side == "short" {
+			short = &result.Trades[i]
+		}
+	}
+	if short == nil {
+		t.Fatalf("expected a short trade, got %+v", result.Trades)
+	}
+	if short.PnL <= 0 {
+		t.Errorf("short on a falling market lost money: %+v", short)
+	}
+	if math.Abs(short.PnL-(short.EntryPrice-short.ExitPrice-short.Fees)) > 1e-9 {
+		t.Errorf("short PnL = %f, want entry - exit - fees", short.PnL)
+	}
+}
+
+func TestRunPerSideCommission(t *testing.T) {
+	p := testParams()
+	p.CommissionPercent = 0.1
+	result, err := run(makeCandles(vShape(30, 2000, 5)), p)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	trade := result.Trades[0]
+	if math.Abs(trade.EntryFee-trade.EntryPrice*0.001) > 1e-9 || math.Abs(trade.ExitFee-trade.ExitPrice*0.001) > 1e-9 {
+		t.Errorf("entry fee %f / exit fee %f do not match their sides", trade.EntryFee, trade.ExitFee)
+	}
+	if trade.Fees != trade.EntryFee+trade.ExitFee {
+		t.Errorf("fees = %f, want %f", trade.Fees, trade.EntryFee+trade.ExitFee)
+	}
+}
+
+func TestRunConfirmationDelaysEntry(t *testing.T) {
+	candles := makeCandles(vShape(30, 2000, 5))
+	immediate, _ := run(candles, testParams())
+
+	p := testParams()
+	p.RequireConfirmation = true
+	confirmed, err := run(candles, p)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if len(confirmed.Trades) != 1 {
+		t.Fatalf("got %d trades, want 1", len(confirmed.Trades))
+	}
+	want := immediate.Trades[0].EntryTime.Add(15 * time.Minute)
+	if !confirmed.Trades[0].EntryTime.Equal(want) {
+		t.Errorf("entry at %s, want one bar after the signal at %s", confirmed.Trades[0].EntryTime, want)
+	}
+}
+
+func TestRunConfirmationRejectsFailedSignal(t *testing.T) {
+	closes := vShape(30, 2000, 5)
+	immediate, _ := run(makeCandles(closes), testParams())
+	signalBar := int(immediate.Trades[0].EntryTime.Sub(makeCandles(closes)[0].Datetime) / (15 * time.Minute))
+
+	// The bar after the signal closes lower, so the signal is not confirmed
+	closes[signalBar+1] = closes[signalBar] - 1
+	p := testParams()
+	p.RequireConfirmation = true
+	result, _ := run(makeCandles(closes), p)
+	for _, trade := range result.Trades {
+		if trade.EntryTime.Equal(makeCandles(closes)[signalBar+1].Datetime) {
+			t.Errorf("entered on an unconfirmed signal: %+v", trade)
+		}
+	}
+}
+
+func TestComputeMetrics(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	trade := func(entryDay, exitDay int, profit, returnPct float64) Trade {
+		return Trade{EntryTime: start.Add(time.Duration(entryDay) * day), ExitTime: start.Add(time.Duration(exitDay) * day), Profit: profit, ReturnPct: returnPct}
+	}
+	// An account of 100 gains 10%, loses 5% and gains 20%
+	result := Result{Trades: []Trade{trade(0, 10, 10, 10), trade(20, 30, -5.5, -5), trade(40, 50, 20.9, 20)}, Wins: 2, Losses: 1, TotalReturnPct: 25.4}
+	m := ComputeMetrics(result, start, start.Add(year))
+
+	for _, c := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"win rate", m.WinRatePct, 200.0 / 3},
+		{"net PnL", m.NetPnL, 25.4},
+		{"CAGR", m.CAGRPct, 25.4},
+		{"profit factor", m.ProfitFactor, 30.9 / 5.5},
+		{"expectancy", m.ExpectancyPct, 25.0 / 3},
+		{"max drawdown", m.MaxDrawdownPct, 5},
+		{"max drawdown duration", m.MaxDrawdownHours, 40 * 24}, // From the peak on day 10 to the recovery on day 50
+		{"sharpe", m.Sharpe, 1.147078},
+		{"sortino", m.Sortino, 5},
+		{"calmar", m.Calmar, 25.4 / 5},
+		{"average hold", m.AvgHoldHours, 10 * 24},
+		{"exposure", m.ExposurePct, 30.0 / 365 * 100},
+	} {
+		if math.Abs(c.got-c.want) > 1e-6 {
+			t.Errorf("%s = %f, want %f", c.name, c.got, c.want)
+		}
+	}
+
+	// A drawdown that has not recovered lasts until the end
+	result.Trades = result.Trades[:2]
+	if hours := ComputeMetrics(result, start, start.Add(100*day)).MaxDrawdownHours; hours != 90*24 {
+		t.Errorf("open drawdown lasted %f hours, want %d", hours, 90*24)
+	}
+
+	result.Trades[1].Profit, result.Trades[1].ReturnPct = 5.5, 5
+	if pf := ComputeMetrics(result, start, start.Add(year)).ProfitFactor; !math.IsInf(pf, 1) {
+		t.Errorf("profit factor without losses = %f, want +Inf", pf)
+	}
+	if m := ComputeMetrics(Result{}, start, start.Add(year)); m != (Metrics{}) {
+		t.Errorf("metrics of a run without trades = %+v, want zero", m)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	candles := makeCandles(vShape(30, 2000, 5))
+	result, err := run(candles, testParams())
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	report := NewReport("macd", candles, 15*time.Minute, result)
+	if len(report.Equity) != len(result.Trades)+1 || !report.End.Equal(candles[len(candles)-1].Datetime.Add(15*time.Minute)) {
+		t.Fatalf("unexpected report equity %+v ending at %s", report.Equity, report.End)
+	}
+
+	jsonPath, htmlPath := ReportPaths(filepath.Join(t.TempDir(), "trades.csv"))
+	if err := WriteReportJSON(jsonPath, report); err != nil {
+		t.Fatalf("WriteReportJSON returned error: %v", err)
+	}
+	var decoded struct {
+		Metrics map[string]any `json:"metrics"`
+		Trades  []Trade        `json:"trades"`
+	}
+	data, _ := os.ReadFile(jsonPath)
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	// Every trade wins, so the infinite profit factor is written as null
+	if pf, ok := decoded.Metrics["profit_factor"]; !ok || pf != nil {
+		t.Errorf("profit_factor = %v, want null", pf)
+	}
+	if len(decoded.Trades) != len(result.Trades) || !decoded.Trades[0].EntryTime.Equal(result.Trades[0].EntryTime) {
+		t.Errorf("decoded trades %+v, want %+v", decoded.Trades, result.Trades)
+	}
+
+	if err := WriteReportHTML(htmlPath, report); err != nil {
+		t.Fatalf("WriteReportHTML returned error: %v", err)
+	}
+	page, _ := os.ReadFile(htmlPath)
+	for _, want := range []string{"<polyline", "Max drawdown duration", "Sortino"} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("HTML report does not contain %q", want)
+		}
+	}
+}
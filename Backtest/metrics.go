@@ -0,0 +1,153 @@
+package backtest
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// year is the length of a year used to annualize ratios
+const year = 365 * 24 * time.Hour
+
+// Metrics summarizes the performance of a backtest run. Returns are those of the account
+// equity, so they depend on the position sizing.
+type Metrics struct {
+	Trades           int     `json:"trades"`
+	WinRatePct       float64 `json:"win_rate_pct"`
+	NetPnL           float64 `json:"net_pnl"` // Sum of the trade profits in the quote currency
+	TotalReturnPct   float64 `json:"total_return_pct"`
+	CAGRPct          float64 `json:"cagr_pct"`           // Compound annual growth rate of the equity
+	ProfitFactor     float64 `json:"profit_factor"`      // Gross profit / gross loss, +Inf when no trade lost
+	ExpectancyPct    float64 `json:"expectancy_pct"`     // Mean return of a trade
+	MaxDrawdownPct   float64 `json:"max_drawdown_pct"`   // Largest fall of the equity from a previous peak, in percent
+	MaxDrawdownHours float64 `json:"max_drawdown_hours"` // Longest time the equity stayed below a previous peak
+	Sharpe           float64 `json:"sharpe"`             // Mean / standard deviation of the trade returns, annualized by the trade frequency
+	Sortino          float64 `json:"sortino"`            // Like Sharpe, but only the losing trades count as deviation
+	Calmar           float64 `json:"calmar"`             // CAGR / max drawdown, +Inf when the equity only grew
+	AvgHoldHours     float64 `json:"avg_hold_hours"`
+	ExposurePct      float64 `json:"exposure_pct"` // Share of the time spent in a position
+}
+
+// MarshalJSON writes the infinite ratios as null, JSON has no infinity
+func (m Metrics) MarshalJSON() ([]byte, error) {
+	type plain Metrics
+	finite := func(v float64) *float64 {
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil
+		}
+		return &v
+	}
+	return json.Marshal(struct {
+		plain
+		ProfitFactor *float64 `json:"profit_factor"`
+		Calmar       *float64 `json:"calmar"`
+	}{plain(m), finite(m.ProfitFactor), finite(m.Calmar)})
+}
+
+// ComputeMetrics calculates the metrics of a run over the candles from start to end
+func ComputeMetrics(r Result, start, end time.Time) Metrics {
+	m := Metrics{Trades: len(r.Trades), TotalReturnPct: r.TotalReturnPct}
+	if m.Trades == 0 {
+		return m
+	}
+	m.WinRatePct = float64(r.Wins) / float64(m.Trades) * 100
+	period := end.Sub(start)
+
+	grossProfit, grossLoss := 0.0, 0.0
+	equity, peak := 1.0, 1.0
+	peakTime, underwater := start, false
+	var longestDrawdown, held time.Duration
+	returns := make([]float64, m.Trades)
+	for i, t := range r.Trades {
+		m.NetPnL += t.Profit
+		returns[i] = t.ReturnPct / 100
+		if t.Profit > 0 {
+			grossProfit += t.Profit
+		} else {
+			grossLoss -= t.Profit
+		}
+		held += t.ExitTime.Sub(t.EntryTime)
+
+		equity *= 1 + returns[i]
+		if equity >= peak {
+			if underwater {
+				longestDrawdown = max(longestDrawdown, t.ExitTime.Sub(peakTime))
+			}
+			peak, peakTime, underwater = equity, t.ExitTime, false
+		} else {
+			underwater = true
+		}
+		m.MaxDrawdownPct = math.Max(m.MaxDrawdownPct, (peak-equity)/peak*100)
+	}
+	if underwater {
+		longestDrawdown = max(longestDrawdown, end.Sub(peakTime))
+	}
+	m.MaxDrawdownHours = longestDrawdown.Hours()
+	m.AvgHoldHours = held.Hours() / float64(m.Trades)
+	switch {
+	case grossLoss > 0:
+		m.ProfitFactor = grossProfit / grossLoss
+	case grossProfit > 0:
+		m.ProfitFactor = math.Inf(1)
+	}
+
+	mean, deviation := meanAndDeviation(returns)
+	m.ExpectancyPct = mean * 100
+	if period <= 0 {
+		return m
+	}
+	m.ExposurePct = float64(held) / float64(period) * 100
+	years := float64(period) / float64(year)
+	if equity > 0 {
+		m.CAGRPct = (math.Pow(equity, 1/years) - 1) * 100
+	} else {
+		m.CAGRPct = -100
+	}
+	switch {
+	case m.MaxDrawdownPct > 0:
+		m.Calmar = m.CAGRPct / m.MaxDrawdownPct
+	case m.CAGRPct > 0:
+		m.Calmar = math.Inf(1)
+	}
+
+	tradesPerYear := float64(m.Trades) / years
+	if deviation > 0 {
+		m.Sharpe = mean / deviation * math.Sqrt(tradesPerYear)
+	}
+	if downside := downsideDeviation(returns); downside > 0 {
+		m.Sortino = mean / downside * math.Sqrt(tradesPerYear)
+	}
+	return m
+}
+
+// meanAndDeviation returns the mean and the sample standard deviation of the values
+func meanAndDeviation(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	sumSquares := 0.0
+	for _, v := range values {
+		sumSquares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sumSquares / float64(len(values)-1))
+}
+
+// downsideDeviation returns the root mean square of the negative values, the deviation
+// used by the Sortino ratio
+func downsideDeviation(values []float64) float64 {
+	sumSquares := 0.0
+	for _, v := range values {
+		if v < 0 {
+			sumSquares += v * v
+		}
+	}
+	return math.Sqrt(sumSquares / float64(len(values)))
+}
@@ -0,0 +1,235 @@
+package backtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EquityPoint is the equity of a run at its start and after every closed trade
+type EquityPoint struct {
+	Time        time.Time `json:"time"`
+	Equity      float64   `json:"equity"`       // Starts at 1
+	DrawdownPct float64   `json:"drawdown_pct"` // Fall from the previous peak
+}
+
+// Report is the performance report of a backtest run
+type Report struct {
+	Strategy string        `json:"strategy"`
+	Symbol   string        `json:"symbol"`
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Metrics  Metrics       `json:"metrics"`
+	Equity   []EquityPoint `json:"equity"`
+	Trades   []Trade       `json:"trades"`
+}
+
+// NewReport builds the report of a run over candles of the given interval
+func NewReport(strategyName string, candles []klinesfrombinance.Candle, interval time.Duration, r Result) Report {
+	report := Report{Strategy: strategyName, Trades: r.Trades}
+	if len(candles) > 0 {
+		report.Symbol = candles[0].Symbol
+		report.Start = candles[0].Datetime
+		report.End = candles[len(candles)-1].Datetime.Add(interval)
+	}
+	report.Metrics = ComputeMetrics(r, report.Start, report.End)
+
+	equity, peak := 1.0, 1.0
+	report.Equity = append(report.Equity, EquityPoint{Time: report.Start, Equity: equity})
+	for _, t := range r.Trades {
+		equity *= 1 + t.ReturnPct/100
+		peak = math.Max(peak, equity)
+		report.Equity = append(report.Equity, EquityPoint{Time: t.ExitTime, Equity: equity, DrawdownPct: (peak - equity) / peak * 100})
+	}
+	return report
+}
+
+// ReportPaths returns the JSON and HTML report paths next to the trade log, e.g.
+// trades.csv gives trades_report.json and trades_report.html
+func ReportPaths(outputFileName string) (jsonPath, htmlPath string) {
+	base := strings.TrimSuffix(outputFileName, filepath.Ext(outputFileName))
+	return base + "_report.json", base + "_report.html"
+}
+
+// WriteReportJSON writes the report as indented JSON
+func WriteReportJSON(path string, report Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// WriteReportHTML writes the report as a self-contained HTML page with the metrics and
+// the equity and drawdown charts drawn as inline SVG
+func WriteReportHTML(path string, report Report) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	defer file.Close()
+
+	equity := make([]float64, len(report.Equity))
+	drawdown := make([]float64, len(report.Equity))
+	for i, p := range report.Equity {
+		equity[i] = (p.Equity - 1) * 100
+		drawdown[i] = -p.DrawdownPct
+	}
+	m := report.Metrics
+	data := struct {
+		Report
+		Rows     [][2]string
+		Equity   chart
+		Drawdown chart
+	}{
+		Report: report,
+		Rows: [][2]string{
+			{"Trades", strconv.Itoa(m.Trades)},
+			{"Net PnL", formatNumber(m.NetPnL, "")},
+			{"Total return", formatNumber(m.TotalReturnPct, "%")},
+			{"CAGR", formatNumber(m.CAGRPct, "%")},
+			{"Sharpe", formatNumber(m.Sharpe, "")},
+			{"Sortino", formatNumber(m.Sortino, "")},
+			{"Calmar", formatNumber(m.Calmar, "")},
+			{"Max drawdown", formatNumber(m.MaxDrawdownPct, "%")},
+			{"Max drawdown duration", formatHours(m.MaxDrawdownHours)},
+			{"Win rate", formatNumber(m.WinRatePct, "%")},
+			{"Profit factor", formatNumber(m.ProfitFactor, "")},
+			{"Expectancy per trade", formatNumber(m.ExpectancyPct, "%")},
+			{"Average hold time", formatHours(m.AvgHoldHours)},
+			{"Exposure", formatNumber(m.ExposurePct, "%")},
+		},
+		Equity:   newChart(report.Equity, equity, "#2a7ae2", false),
+		Drawdown: newChart(report.Equity, drawdown, "#d9534f", true),
+	}
+	if err := reportTemplate.Execute(file, data); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// Size of the SVG charts in pixels
+const (
+	chartWidth  = 900
+	chartHeight = 260
+	chartMargin = 50
+)
+
+// chart is a line chart of a series over time, scaled to the SVG size
+type chart struct {
+	Width       int
+	Height      int
+	Left, Right int    // Ends of the time axis
+	Points      string // SVG polyline points
+	Area        string // Polygon closing the line to the zero axis, for filled charts
+	Color       string
+	ZeroY       float64
+	Top, Bottom string // Axis labels of the highest and lowest value
+	TopY        float64
+	BottomY     float64
+}
+
+func newChart(points []EquityPoint, values []float64, color string, filled bool) chart {
+	c := chart{Width: chartWidth, Height: chartHeight, Left: chartMargin, Right: chartWidth - chartMargin, Color: color}
+	if len(points) == 0 {
+		return c
+	}
+	low, high := 0.0, 0.0
+	for _, v := range values {
+		low, high = math.Min(low, v), math.Max(high, v)
+	}
+	if high == low {
+		high = low + 1
+	}
+	start, end := points[0].Time, points[len(points)-1].Time
+	span := float64(end.Sub(start))
+	x := func(t time.Time) float64 {
+		if span == 0 {
+			return chartMargin
+		}
+		return chartMargin + float64(t.Sub(start))/span*(chartWidth-2*chartMargin)
+	}
+	y := func(v float64) float64 {
+		return 10 + (high-v)/(high-low)*(chartHeight-40)
+	}
+
+	coords := make([]string, len(points))
+	for i, p := range points {
+		coords[i] = fmt.Sprintf("%.1f,%.1f", x(p.Time), y(values[i]))
+	}
+	c.Points = strings.Join(coords, " ")
+	c.ZeroY = y(0)
+	if filled {
+		c.Area = fmt.Sprintf("%.1f,%.1f %s %.1f,%.1f", x(start), c.ZeroY, c.Points, x(end), c.ZeroY)
+	}
+	c.Top, c.Bottom = formatNumber(high, "%"), formatNumber(low, "%")
+	c.TopY, c.BottomY = y(high)+4, y(low)+4
+	return c
+}
+
+// formatNumber writes a metric with 2 decimals and a unit, "∞" for an infinite ratio
+func formatNumber(v float64, unit string) string {
+	if math.IsInf(v, 1) {
+		return "∞"
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64) + unit
+}
+
+// formatHours writes a duration given in hours in days and hours
+func formatHours(hours float64) string {
+	d := time.Duration(hours * float64(time.Hour)).Round(time.Hour)
+	days := int(d / (24 * time.Hour))
+	if days == 0 {
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd %dh", days, int(d.Hours())-days*24)
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Strategy}} backtest of {{.Symbol}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td { padding: 4px 16px; border-bottom: 1px solid #ddd; }
+td:last-child { text-align: right; font-variant-numeric: tabular-nums; }
+svg { display: block; margin-bottom: 2em; }
+text { font-size: 12px; fill: #555; }
+</style>
+</head>
+<body>
+<h1>{{.Strategy}} backtest of {{.Symbol}}</h1>
+<p>{{.Start.Format "2006-01-02 15:04"}} to {{.End.Format "2006-01-02 15:04"}} UTC</p>
+<table>
+{{- range .Rows}}
+<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+{{- end}}
+</table>
+<h2>Equity</h2>
+{{template "chart" .Equity}}
+<h2>Drawdown</h2>
+{{template "chart" .Drawdown}}
+</body>
+</html>
+{{define "chart"}}<svg width="{{.Width}}" height="{{.Height}}">
+<line x1="{{.Left}}" y1="{{.ZeroY}}" x2="{{.Right}}" y2="{{.ZeroY}}" stroke="#aaa" stroke-dasharray="4"/>
+<text x="0" y="{{.TopY}}">{{.Top}}</text>
+<text x="0" y="{{.BottomY}}">{{.Bottom}}</text>
+{{- if .Area}}
+<polygon points="{{.Area}}" fill="{{.Color}}" fill-opacity="0.3" stroke="none"/>
+{{- end}}
+<polyline points="{{.Points}}" fill="none" stroke="{{.Color}}" stroke-width="1.5"/>
+</svg>{{end}}
+`))
@@ -0,0 +1,416 @@
+package binanceclient
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Binance error code returned when the request timestamp is outside of recvWindow
+const errCodeTimestamp = -1021
+
+// Client is an authenticated Binance spot REST client. Signed requests carry a
+// timestamp corrected by the measured server time offset and an HMAC-SHA256 signature.
+type Client struct {
+	BaseURL    string
+	APIKey     string
+	SecretKey  string
+	RecvWindow time.Duration
+	HTTPClient *http.Client
+
+	mu         sync.Mutex
+	timeOffset int64 // Server time minus local time, in milliseconds
+}
+
+// APIError is an error response from the Binance API
+type APIError struct {
+	StatusCode int
+	Code       int    `json:"code"`
+	Message    string `json:"msg"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Binance API error %d (HTTP %d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// OrderParams describes a new spot order. Price and TimeInForce are required for
+// LIMIT orders, StopPrice for STOP_LOSS and STOP_LOSS_LIMIT orders.
+type OrderParams struct {
+	Symbol           string
+	Side             string // BUY or SELL
+	Type             string // MARKET, LIMIT, STOP_LOSS, STOP_LOSS_LIMIT, ...
+	TimeInForce      string // GTC, IOC or FOK
+	Quantity         float64
+	Price            float64
+	StopPrice        float64
+	NewClientOrderID string
+}
+
+// OrderResponse is an order as reported by Binance
+type OrderResponse struct {
+	Symbol              string  `json:"symbol"`
+	OrderID             int64   `json:"orderId"`
+	ClientOrderID       string  `json:"clientOrderId"`
+	TransactTime        int64   `json:"transactTime"`
+	Time                int64   `json:"time"`
+	UpdateTime          int64   `json:"updateTime"`
+	Price               float64 `json:"price,string"`
+	StopPrice           float64 `json:"stopPrice,string"`
+	OrigQty             float64 `json:"origQty,string"`
+	ExecutedQty         float64 `json:"executedQty,string"`
+	CummulativeQuoteQty float64 `json:"cummulativeQuoteQty,string"`
+	Status              string  `json:"status"`
+	TimeInForce         string  `json:"timeInForce"`
+	Type                string  `json:"type"`
+	Side                string  `json:"side"`
+	Fills               []Trade `json:"fills"`
+}
+
+// Trade is one execution of an order, included in FULL order responses
+type Trade struct {
+	Price           float64 `json:"price,string"`
+	Qty             float64 `json:"qty,string"`
+	Commission      float64 `json:"commission,string"`
+	CommissionAsset string  `json:"commissionAsset"`
+}
+
+// Balance is the amount of one asset held in the account
+type Balance struct {
+	Asset  string  `json:"asset"`
+	Free   float64 `json:"free,string"`
+	Locked float64 `json:"locked,string"`
+}
+
+// Account holds the spot account balances
+type Account struct {
+	CanTrade bool      `json:"canTrade"`
+	Balances []Balance `json:"balances"`
+}
+
+// Filters are the trading rules of a symbol that orders must respect
+type Filters struct {
+	TickSize    float64
+	MinPrice    float64
+	MaxPrice    float64
+	StepSize    float64
+	MinQty      float64
+	MaxQty      float64
+	MinNotional float64
+}
+
+// SymbolInfo describes a tradable symbol
+type SymbolInfo struct {
+	Symbol     string
+	Status     string
+	BaseAsset  string
+	QuoteAsset string
+	Filters    Filters
+}
+
+// New creates a client. baseURL is the REST root such as https://api.binance.com.
+func New(baseURL, apiKey, secretKey string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		APIKey:     apiKey,
+		SecretKey:  secretKey,
+		RecvWindow: 5 * time.Second,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// BaseURLFrom returns the scheme and host of a Binance endpoint URL, so the klines
+// endpoint configured in BINANCE_API_BASE can be reused as the REST root.
+func BaseURLFrom(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid Binance URL '%s': %w", endpoint, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid Binance URL '%s': missing scheme or host", endpoint)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// SyncTime measures the offset between the local clock and the Binance server clock
+func (c *Client) SyncTime(ctx context.Context) error {
+	var result struct {
+		ServerTime int64 `json:"serverTime"`
+	}
+	before := time.Now().UnixMilli()
+	if err := c.do(ctx, http.MethodGet, "/api/v3/time", nil, false, &result); err != nil {
+		return fmt.Errorf("failed to fetch server time: %w", err)
+	}
+	after := time.Now().UnixMilli()
+
+	c.mu.Lock()
+	c.timeOffset = result.ServerTime - (before+after)/2
+	c.mu.Unlock()
+	return nil
+}
+
+// TimeOffset returns the last measured server time offset
+func (c *Client) TimeOffset() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Duration(c.timeOffset) * time.Millisecond
+}
+
+// PlaceOrder submits a new order
+func (c *Client) PlaceOrder(ctx context.Context, p OrderParams) (*OrderResponse, error) {
+	params := url.Values{}
+	params.Set("symbol", p.Symbol)
+	params.Set("side", p.Side)
+	params.Set("type", p.Type)
+	params.Set("quantity", formatDecimal(p.Quantity))
+	params.Set("newOrderRespType", "FULL")
+	if p.TimeInForce != "" {
+		params.Set("timeInForce", p.TimeInForce)
+	}
+	if p.Price > 0 {
+		params.Set("price", formatDecimal(p.Price))
+	}
+	if p.StopPrice > 0 {
+		params.Set("stopPrice", formatDecimal(p.StopPrice))
+	}
+	if p.NewClientOrderID != "" {
+		params.Set("newClientOrderId", p.NewClientOrderID)
+	}
+
+	var order OrderResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v3/order", params, true, &order); err != nil {
+		return nil, fmt.Errorf("failed to place order: %w", err)
+	}
+	return &order, nil
+}
+
+// CancelOrder cancels an open order
+func (c *Client) CancelOrder(ctx context.Context, symbol string, orderID int64) (*OrderResponse, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("orderId", strconv.FormatInt(orderID, 10))
+
+	var order OrderResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/v3/order", params, true, &order); err != nil {
+		return nil, fmt.Errorf("failed to cancel order %d: %w", orderID, err)
+	}
+	return &order, nil
+}
+
+// QueryOrder returns the current state of an order
+func (c *Client) QueryOrder(ctx context.Context, symbol string, orderID int64) (*OrderResponse, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("orderId", strconv.FormatInt(orderID, 10))
+
+	var order OrderResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v3/order", params, true, &order); err != nil {
+		return nil, fmt.Errorf("failed to query order %d: %w", orderID, err)
+	}
+	return &order, nil
+}
+
+// OpenOrders returns the open orders of a symbol
+func (c *Client) OpenOrders(ctx context.Context, symbol string) ([]OrderResponse, error) {
+	params := url.Values{}
+	if symbol != "" {
+		params.Set("symbol", symbol)
+	}
+
+	var orders []OrderResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v3/openOrders", params, true, &orders); err != nil {
+		return nil, fmt.Errorf("failed to fetch open orders: %w", err)
+	}
+	return orders, nil
+}
+
+// Account returns the account balances
+func (c *Client) Account(ctx context.Context) (*Account, error) {
+	var account Account
+	if err := c.do(ctx, http.MethodGet, "/api/v3/account", url.Values{}, true, &account); err != nil {
+		return nil, fmt.Errorf("failed to fetch account: %w", err)
+	}
+	return &account, nil
+}
+
+// ExchangeInfo returns the trading rules of a symbol
+func (c *Client) ExchangeInfo(ctx context.Context, symbol string) (*SymbolInfo, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+
+	var result struct {
+		Symbols []struct {
+			Symbol     string                   `json:"symbol"`
+			Status     string                   `json:"status"`
+			BaseAsset  string                   `json:"baseAsset"`
+			QuoteAsset string                   `json:"quoteAsset"`
+			Filters    []map[string]interface{} `json:"filters"`
+		} `json:"symbols"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v3/exchangeInfo", params, false, &result); err != nil {
+		return nil, fmt.Errorf("failed to fetch exchange info: %w", err)
+	}
+
+	for _, s := range result.Symbols {
+		if s.Symbol != symbol {
+			continue
+		}
+		info := &SymbolInfo{Symbol: s.Symbol, Status: s.Status, BaseAsset: s.BaseAsset, QuoteAsset: s.QuoteAsset}
+		for _, f := range s.Filters {
+			switch f["filterType"] {
+			case "PRICE_FILTER":
+				info.Filters.TickSize = filterValue(f, "tickSize")
+				info.Filters.MinPrice = filterValue(f, "minPrice")
+				info.Filters.MaxPrice = filterValue(f, "maxPrice")
+			case "LOT_SIZE":
+				info.Filters.StepSize = filterValue(f, "stepSize")
+				info.Filters.MinQty = filterValue(f, "minQty")
+				info.Filters.MaxQty = filterValue(f, "maxQty")
+			case "NOTIONAL", "MIN_NOTIONAL":
+				info.Filters.MinNotional = filterValue(f, "minNotional")
+			}
+		}
+		return info, nil
+	}
+	return nil, fmt.Errorf("symbol %s not found in exchange info", symbol)
+}
+
+// RoundQuantity rounds a quantity down to the symbol step size
+func (f Filters) RoundQuantity(qty float64) float64 {
+	return roundDown(qty, f.StepSize)
+}
+
+// RoundPrice rounds a price down to the symbol tick size
+func (f Filters) RoundPrice(price float64) float64 {
+	return roundDown(price, f.TickSize)
+}
+
+// Check verifies that an order quantity and price respect the filters. price may be 0
+// for market orders, in which case the notional check is skipped.
+func (f Filters) Check(qty, price float64) error {
+	if f.MinQty > 0 && qty < f.MinQty {
+		return fmt.Errorf("quantity %s is below the minimum %s", formatDecimal(qty), formatDecimal(f.MinQty))
+	}
+	if f.MaxQty > 0 && qty > f.MaxQty {
+		return fmt.Errorf("quantity %s is above the maximum %s", formatDecimal(qty), formatDecimal(f.MaxQty))
+	}
+	if price > 0 && f.MinNotional > 0 && qty*price < f.MinNotional {
+		return fmt.Errorf("order value %.8f is below the minimum notional %s", qty*price, formatDecimal(f.MinNotional))
+	}
+	return nil
+}
+
+// do sends a request and decodes the JSON response into out. Signed requests that fail
+// because of clock drift are retried once after re-syncing the server time.
+func (c *Client) do(ctx context.Context, method, path string, params url.Values, signed bool, out interface{}) error {
+	err := c.send(ctx, method, path, params, signed, out)
+	var apiErr *APIError
+	if signed && errors.As(err, &apiErr) && apiErr.Code == errCodeTimestamp {
+		if syncErr := c.SyncTime(ctx); syncErr != nil {
+			return err
+		}
+		err = c.send(ctx, method, path, params, signed, out)
+	}
+	return err
+}
+
+func (c *Client) send(ctx context.Context, method, path string, params url.Values, signed bool, out interface{}) error {
+	query := ""
+	if params != nil {
+		query = params.Encode()
+	}
+	if signed {
+		c.mu.Lock()
+		timestamp := time.Now().UnixMilli() + c.timeOffset
+		c.mu.Unlock()
+
+		extra := url.Values{}
+		extra.Set("recvWindow", strconv.FormatInt(c.RecvWindow.Milliseconds(), 10))
+		extra.Set("timestamp", strconv.FormatInt(timestamp, 10))
+		if query != "" {
+			query += "&"
+		}
+		query += extra.Encode()
+		query += "&signature=" + c.sign(query)
+	}
+
+	endpoint := c.BaseURL + path
+	if query != "" {
+		endpoint += "?" + query
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-MBX-APIKEY", c.APIKey)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		if json.Unmarshal(body, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = string(body)
+		}
+		return apiErr
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return nil
+}
+
+// sign returns the hex encoded HMAC-SHA256 of the payload keyed with the secret key
+func (c *Client) sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(c.SecretKey))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// filterValue parses a decimal string field of an exchange filter
+func filterValue(f map[string]interface{}, key string) float64 {
+	s, _ := f[key].(string)
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// roundDown rounds value down to a multiple of step, leaving it unchanged if step is 0
+func roundDown(value, step float64) float64 {
+	if step <= 0 {
+		return value
+	}
+	// The small epsilon keeps values like 0.3/0.1 from falling one step short
+	rounded := math.Floor(value/step+1e-9) * step
+	// Strip the binary noise (0.30000000000000004) so the value formats cleanly
+	decimals := 0
+	if s := formatDecimal(step); strings.Contains(s, ".") {
+		decimals = len(s) - strings.Index(s, ".") - 1
+	}
+	scale := math.Pow10(decimals)
+	return math.Round(rounded*scale) / scale
+}
+
+// formatDecimal formats a number without exponent or trailing zeros, as Binance expects
+func formatDecimal(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,196 @@
+package binanceclient
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testKey    = "test_api_key"
+	testSecret = "test_secret_key"
+)
+
+// standIn is a local Binance stand-in that rejects requests with a bad signature,
+// a missing API key or a timestamp outside of recvWindow
+type standIn struct {
+	serverOffset int64 // Milliseconds the server clock is ahead of the local clock
+	rejected     int32
+	lastQuery    string
+}
+
+func (s *standIn) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/time", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"serverTime":%d}`, time.Now().UnixMilli()+s.serverOffset)
+	})
+	mux.HandleFunc("/api/v3/exchangeInfo", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"symbols":[{"symbol":"ETHUSDT","status":"TRADING","baseAsset":"ETH","quoteAsset":"USDT","filters":[
+			{"filterType":"PRICE_FILTER","minPrice":"0.01","maxPrice":"1000000.00","tickSize":"0.01"},
+			{"filterType":"LOT_SIZE","minQty":"0.0001","maxQty":"9000.00","stepSize":"0.0001"},
+			{"filterType":"NOTIONAL","minNotional":"5.00"}]}]}`)
+	})
+	signed := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !s.verify(t, w, r) {
+				return
+			}
+			next(w, r)
+		}
+	}
+	mux.HandleFunc("/api/v3/order", signed(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		status := "NEW"
+		if r.Method == http.MethodDelete {
+			status = "CANCELED"
+		}
+		fmt.Fprintf(w, `{"symbol":"%s","orderId":42,"price":"%s","origQty":"%s","executedQty":"0","status":"%s","type":"%s","side":"%s"}`,
+			q.Get("symbol"), valueOr(q.Get("price"), "0"), valueOr(q.Get("quantity"), "0"), status, q.Get("type"), q.Get("side"))
+	}))
+	mux.HandleFunc("/api/v3/account", signed(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"canTrade":true,"balances":[{"asset":"ETH","free":"1.5","locked":"0.25"},{"asset":"USDT","free":"1000.00","locked":"0"}]}`)
+	}))
+	return mux
+}
+
+func (s *standIn) verify(t *testing.T, w http.ResponseWriter, r *http.Request) bool {
+	s.lastQuery = r.URL.RawQuery
+	if r.Header.Get("X-MBX-APIKEY") != testKey {
+		http.Error(w, `{"code":-2015,"msg":"Invalid API-key."}`, http.StatusUnauthorized)
+		return false
+	}
+	raw := r.URL.RawQuery
+	i := strings.LastIndex(raw, "&signature=")
+	if i < 0 {
+		http.Error(w, `{"code":-1102,"msg":"Mandatory parameter 'signature' was not sent."}`, http.StatusBadRequest)
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(raw[:i]))
+	if hex.EncodeToString(mac.Sum(nil)) != raw[i+len("&signature="):] {
+		http.Error(w, `{"code":-1022,"msg":"Signature for this request is not valid."}`, http.StatusBadRequest)
+		return false
+	}
+
+	q := r.URL.Query()
+	timestamp, _ := strconv.ParseInt(q.Get("timestamp"), 10, 64)
+	recvWindow, _ := strconv.ParseInt(q.Get("recvWindow"), 10, 64)
+	serverTime := time.Now().UnixMilli() + s.serverOffset
+	if timestamp > serverTime+1000 || serverTime-timestamp > recvWindow {
+		atomic.AddInt32(&s.rejected, 1)
+		http.Error(w, `{"code":-1021,"msg":"Timestamp for this request is outside of the recvWindow."}`, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func valueOr(v, fallback string) string {
+	if v == "" {
+		return fallback
+	}
+	return v
+}
+
+func newTestClient(t *testing.T, s *standIn) *Client {
+	server := httptest.NewServer(s.handler(t))
+	t.Cleanup(server.Close)
+	return New(server.URL, testKey, testSecret)
+}
+
+func TestPlaceAndCancelOrderAreSigned(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, &standIn{})
+
+	order, err := client.PlaceOrder(ctx, OrderParams{Symbol: "ETHUSDT", Side: "BUY", Type: "LIMIT", TimeInForce: "GTC", Quantity: 0.5, Price: 3000.25})
+	if err != nil {
+		t.Fatalf("PlaceOrder returned error: %v", err)
+	}
+	if order.OrderID != 42 || order.Price != 3000.25 || order.OrigQty != 0.5 || order.Status != "NEW" {
+		t.Errorf("unexpected order %+v", order)
+	}
+
+	canceled, err := client.CancelOrder(ctx, "ETHUSDT", 42)
+	if err != nil {
+		t.Fatalf("CancelOrder returned error: %v", err)
+	}
+	if canceled.Status != "CANCELED" {
+		t.Errorf("status = %s, want CANCELED", canceled.Status)
+	}
+	if _, err := client.QueryOrder(ctx, "ETHUSDT", 42); err != nil {
+		t.Errorf("QueryOrder returned error: %v", err)
+	}
+}
+
+func TestWrongSecretIsRejected(t *testing.T) {
+	client := newTestClient(t, &standIn{})
+	client.SecretKey = "wrong"
+
+	_, err := client.Account(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "-1022") {
+		t.Fatalf("expected a signature error, got %v", err)
+	}
+}
+
+func TestServerTimeOffsetIsApplied(t *testing.T) {
+	s := &standIn{serverOffset: 60_000}
+	client := newTestClient(t, s)
+
+	// The first call is rejected, the client re-syncs its clock and retries
+	account, err := client.Account(context.Background())
+	if err != nil {
+		t.Fatalf("Account returned error: %v", err)
+	}
+	if atomic.LoadInt32(&s.rejected) != 1 {
+		t.Errorf("rejected = %d, want 1", s.rejected)
+	}
+	if offset := client.TimeOffset(); offset < 59*time.Second || offset > 61*time.Second {
+		t.Errorf("time offset = %s, want about 60s", offset)
+	}
+	if len(account.Balances) != 2 || account.Balances[0].Free != 1.5 || account.Balances[0].Locked != 0.25 {
+		t.Errorf("unexpected balances %+v", account.Balances)
+	}
+	if !strings.Contains(s.lastQuery, "recvWindow=5000") {
+		t.Errorf("query %s does not carry recvWindow", s.lastQuery)
+	}
+}
+
+func TestExchangeInfoFilters(t *testing.T) {
+	client := newTestClient(t, &standIn{})
+
+	info, err := client.ExchangeInfo(context.Background(), "ETHUSDT")
+	if err != nil {
+		t.Fatalf("ExchangeInfo returned error: %v", err)
+	}
+	if info.BaseAsset != "ETH" || info.QuoteAsset != "USDT" {
+		t.Errorf("unexpected assets %+v", info)
+	}
+	f := info.Filters
+	if f.TickSize != 0.01 || f.StepSize != 0.0001 || f.MinNotional != 5 {
+		t.Errorf("unexpected filters %+v", f)
+	}
+	if got := f.RoundQuantity(0.123456); got != 0.1234 {
+		t.Errorf("RoundQuantity = %v, want 0.1234", got)
+	}
+	if got := f.RoundPrice(3000.129); got != 3000.12 {
+		t.Errorf("RoundPrice = %v, want 3000.12", got)
+	}
+	if err := f.Check(0.001, 3000); err == nil {
+		t.Error("expected a minimum notional error")
+	}
+}
+
+func TestBaseURLFrom(t *testing.T) {
+	got, err := BaseURLFrom("https://api.binance.com/api/v3/klines")
+	if err != nil || got != "https://api.binance.com" {
+		t.Errorf("BaseURLFrom = %s, %v", got, err)
+	}
+}
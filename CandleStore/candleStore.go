@@ -0,0 +1,116 @@
+package candlestore
+
+import (
+	"context"
+	"fmt"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	loadenv "learnGoLang/LoadEnv"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Storage backends selectable with DATA_STORE
+const (
+	CSV     = "csv"
+	SQLite  = "sqlite"
+	Parquet = "parquet"
+)
+
+// Open opens the candle store of one symbol and interval. path is the CSV file or the
+// Parquet folder of the series, or the SQLite database that may hold many series. The bad row
+// policy only applies to CSV files.
+func Open(backend, path, symbol, interval string, policy klinesfrombinance.BadRowPolicy) (klinesfrombinance.CandleStore, error) {
+	if !matchesBackend(backend, path) {
+		return nil, fmt.Errorf("%s is not a %s store (expected a %s file)", path, backend, strings.Join(extensions[backend], " or "))
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create data folder: %w", err)
+		}
+	}
+
+	var store klinesfrombinance.CandleStore
+	var err error
+	switch backend {
+	case CSV:
+		store, err = klinesfrombinance.OpenCSVStore(path, symbol, interval, policy)
+	case SQLite:
+		store, err = OpenSQLite(path, symbol, interval)
+	case Parquet:
+		store, err = OpenParquet(path, symbol, interval)
+	default:
+		return nil, fmt.Errorf("unknown candle store '%s' (expected csv, sqlite or parquet)", backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// extensions are the file extensions each backend accepts
+var extensions = map[string][]string{
+	CSV:     {".csv"},
+	SQLite:  {".db", ".sqlite", ".sqlite3"},
+	Parquet: {".parquet"},
+}
+
+// matchesBackend reports whether the extension of path belongs to the backend. Unknown
+// backends pass, Open reports them.
+func matchesBackend(backend, path string) bool {
+	accepted, ok := extensions[backend]
+	if !ok {
+		return true
+	}
+	return slices.Contains(accepted, strings.ToLower(filepath.Ext(path)))
+}
+
+// DataPath returns where the configured series is stored: DATA_FILE_PATH, or the
+// SeriesPath of DATA_STORE in its folder when the file is of another backend, such as
+// the default CSV path with DATA_STORE=sqlite
+func DataPath(cfg loadenv.Config) string {
+	if matchesBackend(cfg.Data.Store, cfg.Data.FilePath) {
+		return cfg.Data.FilePath
+	}
+	return SeriesPath(cfg.Data.Store, filepath.Dir(cfg.Data.FilePath), cfg.Exchange.Symbol, cfg.Exchange.Interval)
+}
+
+// SeriesPath returns where a backend keeps a series inside dir: one file per series for
+// CSV, one folder per series for Parquet, e.g. ETHUSDT_15m.parquet, and one shared
+// candles.db for SQLite
+func SeriesPath(backend, dir, symbol, interval string) string {
+	switch backend {
+	case SQLite:
+		return filepath.Join(dir, "candles.db")
+	case Parquet:
+		return filepath.Join(dir, fmt.Sprintf("%s_%s.parquet", symbol, interval))
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s_%s.csv", symbol, interval))
+}
+
+// OpenData opens the store of the configured symbol and interval at DataPath
+func OpenData(cfg loadenv.Config) (klinesfrombinance.CandleStore, error) {
+	policy, err := klinesfrombinance.ParseBadRowPolicy(cfg.Data.BadRowPolicy)
+	if err != nil {
+		return nil, err
+	}
+	return Open(cfg.Data.Store, DataPath(cfg), cfg.Exchange.Symbol, cfg.Exchange.Interval, policy)
+}
+
+// FetchData brings the configured candle history up to date and returns its candles
+func FetchData(ctx context.Context, cfg loadenv.Config) ([]klinesfrombinance.Candle, error) {
+	store, err := OpenData(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	log.Printf("Using %s candle store %s\n", cfg.Data.Store, DataPath(cfg))
+
+	updateErr := klinesfrombinance.UpdateStore(ctx, cfg.Exchange.APIBase, store, cfg.Exchange.Symbol, cfg.Exchange.Interval, nil)
+	if updateErr != nil {
+		return nil, updateErr
+	}
+	return klinesfrombinance.AllCandles(ctx, store)
+}
@@ -0,0 +1,285 @@
+package candlestore
+
+import (
+	"context"
+	"fmt"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	loadenv "learnGoLang/LoadEnv"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	hour = time.Hour.Milliseconds()
+	base = time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC).UnixMilli()
+)
+
+func hourly(from int64, hours ...int) []klinesfrombinance.Candle {
+	candles := make([]klinesfrombinance.Candle, len(hours))
+	for i, h := range hours {
+		price := 100 + float64(h)
+		candles[i] = klinesfrombinance.NewCandle("ETHUSDT", from+int64(h)*hour, price, price+2, price-1, price+1, float64(h))
+	}
+	return candles
+}
+
+func openBackend(t *testing.T, backend, dir string) klinesfrombinance.CandleStore {
+	t.Helper()
+	store, err := Open(backend, SeriesPath(backend, dir, "ETHUSDT", "1h"), "ETHUSDT", "1h", klinesfrombinance.RejectBadRows)
+	if err != nil {
+		t.Fatalf("Open(%s) returned error: %v", backend, err)
+	}
+	return store
+}
+
+func timestamps(candles []klinesfrombinance.Candle) []int64 {
+	ts := make([]int64, len(candles))
+	for i, c := range candles {
+		ts[i] = c.Timestamp
+	}
+	return ts
+}
+
+func TestBackends(t *testing.T) {
+	ctx := context.Background()
+	for _, backend := range []string{CSV, SQLite, Parquet} {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			store := openBackend(t, backend, dir)
+
+			if last, err := store.LastTimestamp(ctx); err != nil || last != 0 {
+				t.Fatalf("LastTimestamp of an empty store = %d, %v", last, err)
+			}
+			if err := store.Append(ctx, hourly(base, 0, 1, 2, 5)); err != nil {
+				t.Fatalf("Append returned error: %v", err)
+			}
+			// Out of order and overlapping: hour 2 is replaced, hour 3 fills part of the gap
+			replaced := hourly(base, 2)
+			replaced[0].Close = 102.5
+			if err := store.Append(ctx, append(hourly(base, 7, 3), replaced...)); err != nil {
+				t.Fatalf("Append returned error: %v", err)
+			}
+			store.Close()
+
+			// Everything must survive reopening
+			store = openBackend(t, backend, dir)
+			defer store.Close()
+
+			all, err := klinesfrombinance.AllCandles(ctx, store)
+			if err != nil {
+				t.Fatalf("AllCandles returned error: %v", err)
+			}
+			if got, want := fmt.Sprint(timestamps(all)), fmt.Sprint(timestamps(hourly(base, 0, 1, 2, 3, 5, 7))); got != want {
+				t.Fatalf("timestamps = %s, want %s", got, want)
+			}
+			if all[2].Close != 102.5 || all[4].High != 107 || all[4].Volume != 5 {
+				t.Errorf("unexpected values: %+v / %+v", all[2], all[4])
+			}
+
+			window, err := store.Range(ctx, base+hour, base+3*hour)
+			if err != nil || len(window) != 3 {
+				t.Errorf("Range = %d candles, %v; want 3", len(window), err)
+			}
+			if last, err := store.LastTimestamp(ctx); err != nil || last != base+7*hour {
+				t.Errorf("LastTimestamp = %d, %v", last, err)
+			}
+
+			gaps, err := store.Gaps(ctx)
+			want := []klinesfrombinance.Gap{{From: base + 4*hour, To: base + 4*hour}, {From: base + 6*hour, To: base + 6*hour}}
+			if err != nil || fmt.Sprint(gaps) != fmt.Sprint(want) {
+				t.Errorf("Gaps = %v, %v; want %v", gaps, err, want)
+			}
+
+			if err := store.SetKnownGaps(ctx, want[:1]); err != nil {
+				t.Fatalf("SetKnownGaps returned error: %v", err)
+			}
+			known, err := store.KnownGaps(ctx)
+			if err != nil || len(known) != 1 || known[0] != want[0] {
+				t.Errorf("KnownGaps = %v, %v", known, err)
+			}
+		})
+	}
+}
+
+func TestSQLiteKeepsSeriesApart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "candles.db")
+	eth, err := OpenSQLite(path, "ETHUSDT", "1h")
+	if err != nil {
+		t.Fatalf("OpenSQLite returned error: %v", err)
+	}
+	defer eth.Close()
+	btc, err := OpenSQLite(path, "BTCUSDT", "1h")
+	if err != nil {
+		t.Fatalf("OpenSQLite returned error: %v", err)
+	}
+	defer btc.Close()
+
+	eth.Append(ctx, hourly(base, 0, 1, 2))
+	btc.Append(ctx, hourly(base, 10))
+	if last, _ := eth.LastTimestamp(ctx); last != base+2*hour {
+		t.Errorf("ETHUSDT last = %d, want %d", last, base+2*hour)
+	}
+	if candles, _ := klinesfrombinance.AllCandles(ctx, btc); len(candles) != 1 || candles[0].Symbol != "BTCUSDT" {
+		t.Errorf("BTCUSDT candles = %+v", candles)
+	}
+}
+
+func TestParquetWritesMonthlyFiles(t *testing.T) {
+	ctx := context.Background()
+	path := SeriesPath(Parquet, t.TempDir(), "ETHUSDT", "1h")
+	store, err := OpenParquet(path, "ETHUSDT", "1h")
+	if err != nil {
+		t.Fatalf("OpenParquet returned error: %v", err)
+	}
+	endOfJanuary := time.Date(2025, time.January, 31, 22, 0, 0, 0, time.UTC).UnixMilli()
+	if err := store.Append(ctx, hourly(endOfJanuary, 0, 1, 2, 3)); err != nil {
+		t.Fatalf("Append returned error: %v", err)
+	}
+	january, err := os.Stat(filepath.Join(path, "2025-01.parquet"))
+	if err != nil {
+		t.Fatalf("no file for January: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(path, "2025-02.parquet")); err != nil {
+		t.Fatalf("no file for February: %v", err)
+	}
+
+	// Appending to February leaves the January file alone
+	if err := store.Append(ctx, hourly(endOfJanuary, 4, 6)); err != nil {
+		t.Fatalf("Append returned error: %v", err)
+	}
+	after, _ := os.Stat(filepath.Join(path, "2025-01.parquet"))
+	if !os.SameFile(january, after) {
+		t.Error("Append to February rewrote the January file")
+	}
+	february, _ := store.Range(ctx, endOfJanuary+2*hour, endOfJanuary+6*hour)
+	if got := timestamps(february); len(got) != 4 || got[0] != endOfJanuary+2*hour || got[3] != endOfJanuary+6*hour {
+		t.Errorf("February range = %v", got)
+	}
+	if gaps, _ := store.Gaps(ctx); len(gaps) != 1 || gaps[0].From != endOfJanuary+5*hour {
+		t.Errorf("gaps %v, want hour 5", gaps)
+	}
+	if last, _ := store.LastTimestamp(ctx); last != endOfJanuary+6*hour {
+		t.Errorf("LastTimestamp = %d, want the last February candle", last)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	src := openBackend(t, CSV, dir)
+	defer src.Close()
+	src.Append(ctx, hourly(base, 0, 1, 3))
+	src.SetKnownGaps(ctx, []klinesfrombinance.Gap{{From: base + 2*hour, To: base + 2*hour}})
+
+	for _, backend := range []string{SQLite, Parquet} {
+		dst := openBackend(t, backend, dir)
+		copied, err := Migrate(ctx, src, dst)
+		if err != nil || copied != 3 {
+			t.Fatalf("Migrate to %s = %d, %v", backend, copied, err)
+		}
+		candles, _ := klinesfrombinance.AllCandles(ctx, dst)
+		known, _ := dst.KnownGaps(ctx)
+		if len(candles) != 3 || candles[2].Close != 104 || len(known) != 1 {
+			t.Errorf("%s after migration: %d candles, known gaps %v", backend, len(candles), known)
+		}
+		dst.Close()
+	}
+}
+
+func TestUpdateStoreRepairsGapsInSQLite(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Hour).UnixMilli()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.ParseInt(r.URL.Query().Get("startTime"), 10, 64)
+		end, _ := strconv.ParseInt(r.URL.Query().Get("endTime"), 10, 64)
+		var rows []string
+		for ts := start; ts <= end && ts < now; ts += hour {
+			rows = append(rows, fmt.Sprintf(`[%d,"1","2","0.5","1.5","3",%d,"0",1,"0","0","0"]`, ts, ts+hour-1))
+		}
+		fmt.Fprint(w, "["+strings.Join(rows, ",")+"]")
+	}))
+	defer server.Close()
+
+	store, err := OpenSQLite(filepath.Join(t.TempDir(), "candles.db"), "ETHUSDT", "1h")
+	if err != nil {
+		t.Fatalf("OpenSQLite returned error: %v", err)
+	}
+	defer store.Close()
+	start := now - 6*hour
+	store.Append(ctx, hourly(start, 0, 3))
+
+	if err := klinesfrombinance.UpdateStore(ctx, server.URL, store, "ETHUSDT", "1h", klinesfrombinance.NewRateLimiter(6000)); err != nil {
+		t.Fatalf("UpdateStore returned error: %v", err)
+	}
+	candles, _ := klinesfrombinance.AllCandles(ctx, store)
+	if len(candles) != 6 {
+		t.Errorf("got %d candles, want 6: %v", len(candles), timestamps(candles))
+	}
+	if gaps, _ := store.Gaps(ctx); len(gaps) != 0 {
+		t.Errorf("gaps left: %v", gaps)
+	}
+}
+
+func TestOpenData(t *testing.T) {
+	ctx := context.Background()
+	var cfg loadenv.Config
+	cfg.Data.Store, cfg.Data.BadRowPolicy = SQLite, "reject"
+	cfg.Data.FilePath = filepath.Join(t.TempDir(), "ETHUSDT_1h.csv")
+	cfg.Exchange.Symbol, cfg.Exchange.Interval = "ETHUSDT", "1h"
+
+	store, err := OpenData(cfg)
+	if err != nil {
+		t.Fatalf("OpenData returned error: %v", err)
+	}
+	if err := store.Append(ctx, hourly(base, 0, 1, 2)); err != nil {
+		t.Fatalf("Append returned error: %v", err)
+	}
+	store.Close()
+
+	// The candles went to the SQLite database next to the configured CSV path
+	store = openBackend(t, SQLite, filepath.Dir(cfg.Data.FilePath))
+	defer store.Close()
+	if last, err := store.LastTimestamp(ctx); err != nil || last != base+2*hour {
+		t.Errorf("LastTimestamp after saving = %d, %v, want the third candle", last, err)
+	}
+
+	cfg.Data.BadRowPolicy = "ignore"
+	if _, err := OpenData(cfg); err == nil {
+		t.Error("expected an error for an unknown bad row policy")
+	}
+}
+
+func TestStorePathMatchesBackend(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Open(SQLite, filepath.Join(dir, "ETHUSDT_1h.csv"), "ETHUSDT", "1h", klinesfrombinance.RejectBadRows); err == nil {
+		t.Error("Open accepted a CSV file as a SQLite database")
+	}
+	if _, err := Open(Parquet, filepath.Join(dir, "candles.db"), "ETHUSDT", "1h", klinesfrombinance.RejectBadRows); err == nil {
+		t.Error("Open accepted a SQLite database as a Parquet file")
+	}
+
+	var cfg loadenv.Config
+	cfg.Data.FilePath = "data/ETHUSDC_15m.csv"
+	cfg.Exchange.Symbol, cfg.Exchange.Interval = "ETHUSDC", "15m"
+	for backend, want := range map[string]string{
+		CSV:     "data/ETHUSDC_15m.csv",
+		SQLite:  filepath.Join("data", "candles.db"),
+		Parquet: filepath.Join("data", "ETHUSDC_15m.parquet"),
+	} {
+		cfg.Data.Store = backend
+		if got := DataPath(cfg); got != want {
+			t.Errorf("DataPath with %s = %s, want %s", backend, got, want)
+		}
+	}
+	cfg.Data.Store, cfg.Data.FilePath = SQLite, "data/history.sqlite"
+	if got := DataPath(cfg); got != "data/history.sqlite" {
+		t.Errorf("DataPath kept %s, want the configured SQLite file", got)
+	}
+}
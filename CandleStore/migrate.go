@@ -0,0 +1,36 @@
+package candlestore
+
+import (
+	"context"
+	"fmt"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+)
+
+// migrateBatch is the number of candles written to the destination per Append
+const migrateBatch = 50000
+
+// Migrate copies every candle and known gap of src into dst and returns the number of
+// candles copied. Candles already in dst with the same timestamp are replaced.
+func Migrate(ctx context.Context, src, dst klinesfrombinance.CandleStore) (int, error) {
+	candles, err := klinesfrombinance.AllCandles(ctx, src)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read source candles: %w", err)
+	}
+	copied := 0
+	for start := 0; start < len(candles); start += migrateBatch {
+		end := min(start+migrateBatch, len(candles))
+		if err := dst.Append(ctx, candles[start:end]); err != nil {
+			return copied, fmt.Errorf("failed to write candles: %w", err)
+		}
+		copied = end
+	}
+
+	gaps, err := src.KnownGaps(ctx)
+	if err != nil {
+		return copied, fmt.Errorf("failed to read known gaps: %w", err)
+	}
+	if err := dst.SetKnownGaps(ctx, gaps); err != nil {
+		return copied, fmt.Errorf("failed to write known gaps: %w", err)
+	}
+	return copied, nil
+}
@@ -0,0 +1,240 @@
+package candlestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetCandle is one row of a Parquet history file. Timestamps are delta encoded and
+// the symbol is dictionary encoded, so they take almost no space.
+type parquetCandle struct {
+	Symbol    string  `parquet:"symbol,dict"`
+	Timestamp int64   `parquet:"timestamp,delta"`
+	Open      float64 `parquet:"open"`
+	High      float64 `parquet:"high"`
+	Low       float64 `parquet:"low"`
+	Close     float64 `parquet:"close"`
+	Volume    float64 `parquet:"volume"`
+}
+
+// monthLayout names the monthly files of a Parquet history, e.g. 2025-01.parquet
+const monthLayout = "2006-01"
+
+// ParquetStore keeps the history of one series in a folder of zstd compressed Parquet
+// files, one per calendar month in UTC. Parquet files cannot be appended to, so Append
+// only rewrites the months its candles fall in, and reads only load the months they
+// cover. A year of 1m candles is twelve files of about 44k rows each.
+type ParquetStore struct {
+	path     string // Folder of the monthly files
+	symbol   string
+	interval time.Duration
+}
+
+// OpenParquet opens the Parquet history in the folder at path, which does not need to
+// exist yet
+func OpenParquet(path, symbol, interval string) (*ParquetStore, error) {
+	info, err := os.Stat(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to open Parquet history %s: %w", path, err)
+	}
+	if err == nil && !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a Parquet history folder", path)
+	}
+	return &ParquetStore{path: path, symbol: symbol, interval: klinesfrombinance.GapInterval(interval)}, nil
+}
+
+// months returns the start of the months that have a file, oldest first
+func (s *ParquetStore) months() ([]time.Time, error) {
+	entries, err := os.ReadDir(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Parquet history %s: %w", s.path, err)
+	}
+	var months []time.Time
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".parquet")
+		if !ok || e.IsDir() {
+			continue
+		}
+		if month, err := time.Parse(monthLayout, name); err == nil {
+			months = append(months, month)
+		}
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Before(months[j]) })
+	return months, nil
+}
+
+// monthFile returns the file of the month that starts at month
+func (s *ParquetStore) monthFile(month time.Time) string {
+	return filepath.Join(s.path, month.Format(monthLayout)+".parquet")
+}
+
+// monthOf returns the start of the UTC month of a timestamp
+func monthOf(ts int64) time.Time {
+	t := time.UnixMilli(ts).UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// readFile loads the sorted candles of one Parquet file, a missing file has none
+func (s *ParquetStore) readFile(path string) ([]klinesfrombinance.Candle, error) {
+	rows, err := parquet.ReadFile[parquetCandle](path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Parquet file %s: %w", path, err)
+	}
+	candles := make([]klinesfrombinance.Candle, len(rows))
+	for i, r := range rows {
+		candles[i] = klinesfrombinance.NewCandle(s.symbol, r.Timestamp, r.Open, r.High, r.Low, r.Close, r.Volume)
+	}
+	candles, _ = klinesfrombinance.MergeCandles(nil, candles)
+	return candles, nil
+}
+
+// Range returns the candles opened between from and to
+func (s *ParquetStore) Range(ctx context.Context, from, to int64) ([]klinesfrombinance.Candle, error) {
+	months, err := s.months()
+	if err != nil {
+		return nil, err
+	}
+	var result []klinesfrombinance.Candle
+	for _, month := range months {
+		if month.AddDate(0, 1, 0).UnixMilli() <= from || month.UnixMilli() > to {
+			continue
+		}
+		candles, err := s.readFile(s.monthFile(month))
+		if err != nil {
+			return nil, err
+		}
+		start := sort.Search(len(candles), func(i int) bool { return candles[i].Timestamp >= from })
+		end := sort.Search(len(candles), func(i int) bool { return candles[i].Timestamp > to })
+		result = append(result, candles[start:end]...)
+	}
+	return result, nil
+}
+
+// Append merges the candles into the files of their months
+func (s *ParquetStore) Append(ctx context.Context, candles []klinesfrombinance.Candle) error {
+	if len(candles) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(s.path, 0755); err != nil {
+		return fmt.Errorf("failed to create Parquet history %s: %w", s.path, err)
+	}
+	byMonth := make(map[time.Time][]klinesfrombinance.Candle)
+	for _, c := range candles {
+		month := monthOf(c.Timestamp)
+		byMonth[month] = append(byMonth[month], c)
+	}
+	for month, fresh := range byMonth {
+		existing, err := s.readFile(s.monthFile(month))
+		if err != nil {
+			return err
+		}
+		merged, _ := klinesfrombinance.MergeCandles(existing, fresh)
+		if err := s.write(s.monthFile(month), merged); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write stores the candles in a temporary file that is synced and renamed over path
+func (s *ParquetStore) write(path string, candles []klinesfrombinance.Candle) (err error) {
+	rows := make([]parquetCandle, len(candles))
+	for i, c := range candles {
+		rows[i] = parquetCandle{Symbol: s.symbol, Timestamp: c.Timestamp, Open: c.Open, High: c.High, Low: c.Low, Close: c.Close, Volume: c.Volume}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary Parquet file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := parquet.Write(tmp, rows, parquet.Compression(&parquet.Zstd)); err != nil {
+		return fmt.Errorf("failed to write Parquet file: %w", err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		return fmt.Errorf("failed to set Parquet file mode: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync Parquet file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close Parquet file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// LastTimestamp returns the open time of the newest candle
+func (s *ParquetStore) LastTimestamp(ctx context.Context) (int64, error) {
+	months, err := s.months()
+	if err != nil || len(months) == 0 {
+		return 0, err
+	}
+	candles, err := s.readFile(s.monthFile(months[len(months)-1]))
+	if err != nil || len(candles) == 0 {
+		return 0, err
+	}
+	return candles[len(candles)-1].Timestamp, nil
+}
+
+// Gaps returns the missing intervals of the history, reading one month at a time
+func (s *ParquetStore) Gaps(ctx context.Context) ([]klinesfrombinance.Gap, error) {
+	step := s.interval.Milliseconds()
+	months, err := s.months()
+	if err != nil || step <= 0 {
+		return nil, err
+	}
+	var gaps []klinesfrombinance.Gap
+	prev := int64(-1)
+	for _, month := range months {
+		candles, err := s.readFile(s.monthFile(month))
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range candles {
+			if prev >= 0 && c.Timestamp-prev > step {
+				gaps = append(gaps, klinesfrombinance.Gap{From: prev + step, To: c.Timestamp - step})
+			}
+			prev = c.Timestamp
+		}
+	}
+	return gaps, nil
+}
+
+// KnownGaps reads the gap report next to the Parquet folder
+func (s *ParquetStore) KnownGaps(ctx context.Context) ([]klinesfrombinance.Gap, error) {
+	return klinesfrombinance.ReadGapReport(klinesfrombinance.GapReportPath(s.path))
+}
+
+// SetKnownGaps replaces the gap report next to the Parquet folder
+func (s *ParquetStore) SetKnownGaps(ctx context.Context, gaps []klinesfrombinance.Gap) error {
+	return klinesfrombinance.WriteGapReport(klinesfrombinance.GapReportPath(s.path), gaps, s.interval)
+}
+
+// Close has nothing to release, the files are always up to date
+func (s *ParquetStore) Close() error {
+	return nil
+}
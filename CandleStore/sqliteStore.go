@@ -0,0 +1,201 @@
+package candlestore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"net/url"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS candles (
+	symbol    TEXT    NOT NULL,
+	interval  TEXT    NOT NULL,
+	timestamp INTEGER NOT NULL,
+	open      REAL    NOT NULL,
+	high      REAL    NOT NULL,
+	low       REAL    NOT NULL,
+	close     REAL    NOT NULL,
+	volume    REAL    NOT NULL,
+	PRIMARY KEY (symbol, interval, timestamp)
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS known_gaps (
+	symbol   TEXT    NOT NULL,
+	interval TEXT    NOT NULL,
+	from_ts  INTEGER NOT NULL,
+	to_ts    INTEGER NOT NULL,
+	PRIMARY KEY (symbol, interval, from_ts)
+);`
+
+// SQLiteStore keeps candles in an embedded SQLite database. One database can hold any
+// number of series, each store reads and writes only its own symbol and interval.
+type SQLiteStore struct {
+	db       *sql.DB
+	symbol   string
+	interval string
+	step     int64 // Candle length in milliseconds, 0 if gaps cannot be computed
+}
+
+// OpenSQLite opens (or creates) the database at path for one symbol and interval. The
+// database runs in WAL mode with a busy timeout, so parallel syncs can share it.
+func OpenSQLite(path, symbol, interval string) (*SQLiteStore, error) {
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() +
+		"?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database %s: %w", path, err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create SQLite schema in %s: %w", path, err)
+	}
+	return &SQLiteStore{
+		db:       db,
+		symbol:   symbol,
+		interval: interval,
+		step:     klinesfrombinance.GapInterval(interval).Milliseconds(),
+	}, nil
+}
+
+// Range returns the candles opened between from and to
+func (s *SQLiteStore) Range(ctx context.Context, from, to int64) ([]klinesfrombinance.Candle, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT timestamp, open, high, low, close, volume FROM candles
+		WHERE symbol = ? AND interval = ? AND timestamp BETWEEN ? AND ?
+		ORDER BY timestamp`, s.symbol, s.interval, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query candles: %w", err)
+	}
+	defer rows.Close()
+
+	var candles []klinesfrombinance.Candle
+	for rows.Next() {
+		var ts int64
+		var open, high, low, close, volume float64
+		if err := rows.Scan(&ts, &open, &high, &low, &close, &volume); err != nil {
+			return nil, fmt.Errorf("failed to read candle: %w", err)
+		}
+		candles = append(candles, klinesfrombinance.NewCandle(s.symbol, ts, open, high, low, close, volume))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read candles: %w", err)
+	}
+	return candles, nil
+}
+
+// Append inserts the candles in one transaction, replacing rows with the same timestamp
+func (s *SQLiteStore) Append(ctx context.Context, candles []klinesfrombinance.Candle) error {
+	if len(candles) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT OR REPLACE INTO candles (symbol, interval, timestamp, open, high, low, close, volume)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, c := range candles {
+		if _, err := stmt.ExecContext(ctx, s.symbol, s.interval, c.Timestamp, c.Open, c.High, c.Low, c.Close, c.Volume); err != nil {
+			return fmt.Errorf("failed to insert candle %d: %w", c.Timestamp, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit candles: %w", err)
+	}
+	return nil
+}
+
+// LastTimestamp returns the open time of the newest candle
+func (s *SQLiteStore) LastTimestamp(ctx context.Context) (int64, error) {
+	var last sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `SELECT MAX(timestamp) FROM candles WHERE symbol = ? AND interval = ?`,
+		s.symbol, s.interval).Scan(&last)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query the last candle: %w", err)
+	}
+	return last.Int64, nil
+}
+
+// Gaps finds the missing intervals in the database, without loading the candles
+func (s *SQLiteStore) Gaps(ctx context.Context) ([]klinesfrombinance.Gap, error) {
+	if s.step == 0 {
+		return nil, nil
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT prev + ?, timestamp - ? FROM (
+			SELECT timestamp, LAG(timestamp) OVER (ORDER BY timestamp) AS prev FROM candles
+			WHERE symbol = ? AND interval = ?
+		) WHERE timestamp - prev > ?
+		ORDER BY timestamp`, s.step, s.step, s.symbol, s.interval, s.step)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query gaps: %w", err)
+	}
+	return scanGaps(rows)
+}
+
+// KnownGaps returns the gaps recorded as unrecoverable
+func (s *SQLiteStore) KnownGaps(ctx context.Context) ([]klinesfrombinance.Gap, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT from_ts, to_ts FROM known_gaps WHERE symbol = ? AND interval = ? ORDER BY from_ts`,
+		s.symbol, s.interval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query known gaps: %w", err)
+	}
+	return scanGaps(rows)
+}
+
+// SetKnownGaps replaces the gaps recorded as unrecoverable
+func (s *SQLiteStore) SetKnownGaps(ctx context.Context, gaps []klinesfrombinance.Gap) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM known_gaps WHERE symbol = ? AND interval = ?`, s.symbol, s.interval); err != nil {
+		return fmt.Errorf("failed to clear known gaps: %w", err)
+	}
+	for _, g := range gaps {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO known_gaps (symbol, interval, from_ts, to_ts) VALUES (?, ?, ?, ?)`,
+			s.symbol, s.interval, g.From, g.To); err != nil {
+			return fmt.Errorf("failed to record gap %s: %w", g, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit known gaps: %w", err)
+	}
+	return nil
+}
+
+// Close closes the database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func scanGaps(rows *sql.Rows) ([]klinesfrombinance.Gap, error) {
+	defer rows.Close()
+	var gaps []klinesfrombinance.Gap
+	for rows.Next() {
+		var g klinesfrombinance.Gap
+		if err := rows.Scan(&g.From, &g.To); err != nil {
+			return nil, fmt.Errorf("failed to read gap: %w", err)
+		}
+		gaps = append(gaps, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read gaps: %w", err)
+	}
+	return gaps, nil
+}
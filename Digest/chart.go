@@ -0,0 +1,84 @@
+package digest
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+)
+
+// Size of the equity chart in pixels
+const (
+	chartWidth  = 800
+	chartHeight = 400
+	chartMargin = 20
+)
+
+var (
+	chartBackground = color.RGBA{255, 255, 255, 255}
+	chartGrid       = color.RGBA{230, 230, 230, 255}
+	chartArea       = color.RGBA{219, 234, 254, 255}
+	chartLine       = color.RGBA{37, 99, 235, 255}
+)
+
+// RenderChart draws the equity over time as a PNG line chart. The stdlib has no fonts,
+// so the chart has no labels; the caption of the photo tells the period.
+func RenderChart(points []EquityPoint, width, height int) ([]byte, error) {
+	if len(points) < 2 {
+		return nil, fmt.Errorf("an equity chart needs at least 2 samples, got %d", len(points))
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, chartBackground)
+		}
+	}
+	plotW, plotH := float64(width-2*chartMargin), float64(height-2*chartMargin)
+	for i := 0; i <= 4; i++ {
+		y := chartMargin + int(plotH*float64(i)/4)
+		for x := chartMargin; x <= width-chartMargin; x++ {
+			img.Set(x, y, chartGrid)
+		}
+	}
+
+	low, high := points[0].Equity, points[0].Equity
+	for _, p := range points {
+		low, high = math.Min(low, p.Equity), math.Max(high, p.Equity)
+	}
+	if high == low { // A flat line in the middle
+		low, high = low-1, high+1
+	}
+	start, span := points[0].Time, points[len(points)-1].Time.Sub(points[0].Time).Seconds()
+	scale := func(p EquityPoint) (float64, float64) {
+		x := chartMargin + plotW*p.Time.Sub(start).Seconds()/math.Max(span, 1)
+		y := chartMargin + plotH*(high-p.Equity)/(high-low)
+		return x, y
+	}
+
+	bottom := height - chartMargin
+	for i := 1; i < len(points); i++ {
+		x0, y0 := scale(points[i-1])
+		x1, y1 := scale(points[i])
+		steps := int(math.Max(math.Abs(x1-x0), math.Abs(y1-y0))) + 1
+		for s := 0; s <= steps; s++ {
+			f := float64(s) / float64(steps)
+			x, y := int(math.Round(x0+(x1-x0)*f)), int(math.Round(y0+(y1-y0)*f))
+			for fill := y + 2; fill <= bottom; fill++ {
+				if img.RGBAAt(x, fill) == chartBackground {
+					img.Set(x, fill, chartArea)
+				}
+			}
+			for dy := -1; dy <= 1; dy++ {
+				img.Set(x, y+dy, chartLine)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode equity chart: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,285 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	execution "learnGoLang/Execution"
+	loadenv "learnGoLang/LoadEnv"
+	notifier "learnGoLang/Notifier"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// curveRetention is how long the equity samples are kept, enough for a weekly chart
+const curveRetention = 8 * 24 * time.Hour
+
+// Period of the digests
+type Period string
+
+const (
+	Off    Period = "off"
+	Daily  Period = "daily"
+	Weekly Period = "weekly"
+)
+
+// Config schedules the digests
+type Config struct {
+	Period         Period
+	At             time.Duration // Time of day in UTC, from midnight
+	Weekday        time.Weekday  // Day of the weekly digest
+	Chart          bool          // Attach an equity chart when there is a PhotoSender
+	SampleInterval time.Duration // How often the equity is sampled
+}
+
+// ConfigFromEnv reads the DIGEST_* settings
+func ConfigFromEnv(cfg loadenv.DigestConfig) (Config, error) {
+	c := Config{
+		Period:         Period(strings.ToLower(cfg.Period)),
+		Chart:          cfg.Chart,
+		SampleInterval: time.Duration(cfg.SampleMinutes) * time.Minute,
+	}
+	switch c.Period {
+	case Off, Daily, Weekly:
+	default:
+		return c, fmt.Errorf("invalid DIGEST_PERIOD '%s' (expected off, daily or weekly)", cfg.Period)
+	}
+	at, err := time.Parse("15:04", strings.TrimSpace(cfg.Time))
+	if err != nil {
+		return c, fmt.Errorf("invalid DIGEST_TIME '%s' (expected HH:MM): %w", cfg.Time, err)
+	}
+	c.At = time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute
+	weekday, err := parseWeekday(cfg.Weekday)
+	if err != nil {
+		return c, err
+	}
+	c.Weekday = weekday
+	if c.SampleInterval <= 0 {
+		return c, fmt.Errorf("DIGEST_SAMPLE_MINUTES must be positive, got %d", cfg.SampleMinutes)
+	}
+	return c, nil
+}
+
+// parseWeekday reads an English day name such as "monday" or "mon"
+func parseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || (len(s) == 3 && strings.HasPrefix(name, s)) {
+			return d, nil
+		}
+	}
+	return time.Sunday, fmt.Errorf("invalid DIGEST_WEEKDAY '%s' (expected a day such as monday)", s)
+}
+
+// Next returns the first digest time after t
+func (c Config) Next(t time.Time) time.Time {
+	t = t.UTC()
+	next := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Add(c.At)
+	for !next.After(t) || (c.Period == Weekly && next.Weekday() != c.Weekday) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Length returns the span of time a digest covers
+func (c Config) Length() time.Duration {
+	if c.Period == Weekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Source is the trader the digests report on
+type Source interface {
+	Status(ctx context.Context) (execution.Status, error)
+	Trades(since time.Time) []execution.ClosedTrade
+}
+
+// PhotoSender sends an image, such as the Telegram notifier. The image must arrive after
+// the events sent to the notifier of the scheduler before it.
+type PhotoSender interface {
+	SendPhoto(ctx context.Context, png []byte, caption string) error
+}
+
+// EquityPoint is a sample of the equity
+type EquityPoint struct {
+	Time   time.Time
+	Equity float64
+}
+
+// Digest is the performance of a period
+type Digest struct {
+	Period        Period
+	Symbol        string
+	From, To      time.Time
+	Trades        int
+	Wins, Losses  int
+	RealizedPnL   float64 // Profit of the trades closed in the period, after fees
+	UnrealizedPnL float64 // Profit of the open position at the latest price
+	Fees          float64 // Commission of the trades closed in the period
+	LargestWin    float64
+	LargestLoss   float64 // Negative, 0 without a losing trade
+	Equity        float64
+	DrawdownPct   float64 // Fall of the equity from its highest sample since the start
+	Curve         []EquityPoint
+}
+
+// Event writes the digest as a summary notification
+func (d Digest) Event() notifier.Event {
+	period := "Daily"
+	if d.Period == Weekly {
+		period = "Weekly"
+	}
+	message := fmt.Sprintf("%s digest of %s, %s to %s UTC", period, d.Symbol, d.From.Format("2006-01-02 15:04"), d.To.Format("2006-01-02 15:04"))
+	return notifier.DailySummary(message, map[string]any{
+		"trades":         d.Trades,
+		"wins":           d.Wins,
+		"losses":         d.Losses,
+		"realized_pnl":   round(d.RealizedPnL),
+		"unrealized_pnl": round(d.UnrealizedPnL),
+		"fees":           round(d.Fees),
+		"largest_win":    round(d.LargestWin),
+		"largest_loss":   round(d.LargestLoss),
+		"equity":         round(d.Equity),
+		"drawdown_pct":   round(d.DrawdownPct),
+	}, d.To)
+}
+
+// round keeps two decimals, so the fields read well in chats and emails
+func round(x float64) float64 {
+	return math.Round(x*100) / 100
+}
+
+// Scheduler samples the equity of a trader and sends a digest at every scheduled time.
+// Its methods are meant to be used from the goroutine running Run.
+type Scheduler struct {
+	cfg      Config
+	source   Source
+	notifier notifier.Notifier
+	photos   PhotoSender
+
+	curve []EquityPoint
+	peak  float64
+}
+
+// NewScheduler creates a scheduler sending to n, and the equity chart to photos when
+// Config.Chart is set and photos is not nil
+func NewScheduler(cfg Config, source Source, n notifier.Notifier, photos PhotoSender) *Scheduler {
+	return &Scheduler{cfg: cfg, source: source, notifier: n, photos: photos}
+}
+
+// Run samples the equity and sends the digests until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	if err := s.Sample(ctx, time.Now()); err != nil {
+		log.Printf("Error sampling equity: %v", err)
+	}
+	sampler := time.NewTicker(s.cfg.SampleInterval)
+	defer sampler.Stop()
+	next := s.cfg.Next(time.Now())
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+	log.Printf("Next %s digest at %s", s.cfg.Period, next.Format("2006-01-02 15:04 UTC"))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-sampler.C:
+			if err := s.Sample(ctx, now); err != nil {
+				log.Printf("Error sampling equity: %v", err)
+			}
+		case <-timer.C:
+			if err := s.Send(ctx, next); err != nil {
+				log.Printf("Error sending %s digest: %v", s.cfg.Period, err)
+			}
+			next = s.cfg.Next(next)
+			timer.Reset(time.Until(next))
+		}
+	}
+}
+
+// Sample records the equity at t
+func (s *Scheduler) Sample(ctx context.Context, t time.Time) error {
+	_, err := s.sample(ctx, t)
+	return err
+}
+
+// sample records the equity at t and returns the trader's status
+func (s *Scheduler) sample(ctx context.Context, t time.Time) (execution.Status, error) {
+	status, err := s.source.Status(ctx)
+	if err != nil {
+		return status, err
+	}
+	s.curve = append(s.curve, EquityPoint{Time: t.UTC(), Equity: status.Equity})
+	s.peak = max(s.peak, status.Equity)
+	cutoff := t.Add(-curveRetention)
+	for len(s.curve) > 0 && s.curve[0].Time.Before(cutoff) {
+		s.curve = s.curve[1:]
+	}
+	return status, nil
+}
+
+// Compile samples the equity at to and sums up the trades closed from from to to
+func (s *Scheduler) Compile(ctx context.Context, from, to time.Time) (Digest, error) {
+	status, err := s.sample(ctx, to)
+	if err != nil {
+		return Digest{}, fmt.Errorf("failed to read trader status: %w", err)
+	}
+	d := Digest{
+		Period:        s.cfg.Period,
+		Symbol:        status.Symbol,
+		From:          from.UTC(),
+		To:            to.UTC(),
+		UnrealizedPnL: status.UnrealizedPnL,
+		Equity:        status.Equity,
+	}
+	if s.peak > 0 {
+		d.DrawdownPct = (s.peak - status.Equity) / s.peak * 100
+	}
+	for _, trade := range s.source.Trades(from) {
+		if !trade.ExitTime.Before(to) {
+			continue
+		}
+		d.Trades++
+		d.RealizedPnL += trade.Profit
+		d.Fees += trade.Fees
+		if trade.Profit > 0 {
+			d.Wins++
+			d.LargestWin = max(d.LargestWin, trade.Profit)
+		} else {
+			d.Losses++
+			d.LargestLoss = min(d.LargestLoss, trade.Profit)
+		}
+	}
+	for _, p := range s.curve {
+		if !p.Time.Before(from) && !p.Time.After(to) {
+			d.Curve = append(d.Curve, p)
+		}
+	}
+	return d, nil
+}
+
+// Send compiles the digest of the period ending at t and sends it
+func (s *Scheduler) Send(ctx context.Context, t time.Time) error {
+	d, err := s.Compile(ctx, t.Add(-s.cfg.Length()), t)
+	if err != nil {
+		return err
+	}
+	if err := s.notifier.Notify(ctx, d.Event()); err != nil {
+		return fmt.Errorf("failed to send digest: %w", err)
+	}
+	if !s.cfg.Chart || s.photos == nil || len(d.Curve) < 2 {
+		return nil
+	}
+	chart, err := RenderChart(d.Curve, chartWidth, chartHeight)
+	if err != nil {
+		return err
+	}
+	caption := fmt.Sprintf("Equity of %s, %s to %s UTC", d.Symbol, d.From.Format("2006-01-02 15:04"), d.To.Format("2006-01-02 15:04"))
+	if err := s.photos.SendPhoto(ctx, chart, caption); err != nil {
+		return fmt.Errorf("failed to send equity chart: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	execution "learnGoLang/Execution"
+	loadenv "learnGoLang/LoadEnv"
+	notifier "learnGoLang/Notifier"
+	"testing"
+	"time"
+)
+
+var day = time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC) // A Friday
+
+// fakeSource is a trader whose equity is set by the test
+type fakeSource struct {
+	equity, unrealized float64
+	trades             []execution.ClosedTrade
+}
+
+func (f *fakeSource) Status(context.Context) (execution.Status, error) {
+	return execution.Status{Symbol: "ETHUSDT", Equity: f.equity, UnrealizedPnL: f.unrealized}, nil
+}
+
+func (f *fakeSource) Trades(since time.Time) []execution.ClosedTrade {
+	var trades []execution.ClosedTrade
+	for _, trade := range f.trades {
+		if !trade.ExitTime.Before(since) {
+			trades = append(trades, trade)
+		}
+	}
+	return trades
+}
+
+type recorder struct{ events []notifier.Event }
+
+func (r *recorder) Notify(ctx context.Context, e notifier.Event) error {
+	r.events = append(r.events, e)
+	return nil
+}
+
+type photoRecorder struct {
+	photos   [][]byte
+	captions []string
+}
+
+func (p *photoRecorder) SendPhoto(ctx context.Context, png []byte, caption string) error {
+	p.photos = append(p.photos, png)
+	p.captions = append(p.captions, caption)
+	return nil
+}
+
+func TestConfigNext(t *testing.T) {
+	daily, err := ConfigFromEnv(loadenv.DigestConfig{Period: "daily", Time: "08:30", Weekday: "monday", SampleMinutes: 15})
+	if err != nil {
+		t.Fatalf("ConfigFromEnv returned error: %v", err)
+	}
+	weekly := daily
+	weekly.Period = Weekly
+
+	for _, tt := range []struct {
+		cfg  Config
+		now  time.Time
+		want time.Time
+	}{
+		{daily, day.Add(7 * time.Hour), day.Add(8*time.Hour + 30*time.Minute)},
+		{daily, day.Add(8*time.Hour + 30*time.Minute), day.Add(32*time.Hour + 30*time.Minute)},
+		{weekly, day.Add(7 * time.Hour), day.AddDate(0, 0, 3).Add(8*time.Hour + 30*time.Minute)},
+	} {
+		if got := tt.cfg.Next(tt.now); !got.Equal(tt.want) {
+			t.Errorf("%s Next(%s) = %s, want %s", tt.cfg.Period, tt.now, got, tt.want)
+		}
+	}
+
+	for _, bad := range []loadenv.DigestConfig{
+		{Period: "monthly", Time: "00:00", Weekday: "monday", SampleMinutes: 15},
+		{Period: "daily", Time: "25:00", Weekday: "monday", SampleMinutes: 15},
+		{Period: "weekly", Time: "00:00", Weekday: "someday", SampleMinutes: 15},
+	} {
+		if _, err := ConfigFromEnv(bad); err == nil {
+			t.Errorf("expected an error for %+v", bad)
+		}
+	}
+}
+
+func TestCompileDigest(t *testing.T) {
+	source := &fakeSource{equity: 10000}
+	s := NewScheduler(Config{Period: Daily}, source, &recorder{}, nil)
+	s.Sample(context.Background(), day.Add(-time.Hour))
+	source.equity = 10500
+	s.Sample(context.Background(), day.Add(6*time.Hour))
+
+	source.equity, source.unrealized = 10290, -40
+	source.trades = []execution.ClosedTrade{
+		{ExitTime: day.Add(-2 * time.Hour), Profit: 1000, Fees: 5}, // Before the period
+		{ExitTime: day.Add(2 * time.Hour), Profit: 300, Fees: 4},
+		{ExitTime: day.Add(5 * time.Hour), Profit: -120, Fees: 3},
+		{ExitTime: day.Add(9 * time.Hour), Profit: 80, Fees: 2},
+		{ExitTime: day.Add(30 * time.Hour), Profit: 50, Fees: 1}, // After the period
+	}
+	d, err := s.Compile(context.Background(), day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+	if d.Trades != 3 || d.Wins != 2 || d.Losses != 1 || d.RealizedPnL != 260 || d.Fees != 9 || d.LargestWin != 300 || d.LargestLoss != -120 {
+		t.Errorf("digest %+v, want the 3 trades of the day", d)
+	}
+	if d.UnrealizedPnL != -40 || d.Equity != 10290 || d.DrawdownPct != 2 || len(d.Curve) != 2 {
+		t.Errorf("digest %+v, want a 2%% drawdown from the 10500 peak and 2 samples in the day", d)
+	}
+
+	e := d.Event()
+	if e.Type != notifier.EventDailySummary || e.Message != "Daily digest of ETHUSDT, 2025-01-17 00:00 to 2025-01-18 00:00 UTC" || e.Fields["realized_pnl"] != 260.0 {
+		t.Errorf("event %+v", e)
+	}
+}
+
+func TestSendAttachesChart(t *testing.T) {
+	source := &fakeSource{equity: 10000}
+	n, photos := &recorder{}, &photoRecorder{}
+	s := NewScheduler(Config{Period: Weekly, Chart: true}, source, n, photos)
+	for i := 0; i < 20; i++ {
+		source.equity += float64(i%5-2) * 10
+		s.Sample(context.Background(), day.Add(time.Duration(i)*time.Hour))
+	}
+	if err := s.Send(context.Background(), day.Add(24*time.Hour)); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	if len(n.events) != 1 || len(photos.photos) != 1 {
+		t.Fatalf("sent %d events and %d photos, want 1 of each", len(n.events), len(photos.photos))
+	}
+	img, err := png.Decode(bytes.NewReader(photos.photos[0]))
+	if err != nil || img.Bounds().Dx() != chartWidth {
+		t.Errorf("chart is not an %dpx wide PNG: %v", chartWidth, err)
+	}
+
+	// Without samples in the period there is no chart
+	s = NewScheduler(Config{Period: Daily, Chart: true}, source, n, photos)
+	s.Send(context.Background(), day)
+	if len(n.events) != 2 || len(photos.photos) != 1 {
+		t.Errorf("sent %d events and %d photos, want a second event without a chart", len(n.events), len(photos.photos))
+	}
+}
@@ -0,0 +1,105 @@
+package execution
+
+import (
+	"context"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"time"
+)
+
+// Side of an order
+type Side string
+
+const (
+	Buy  Side = "BUY"
+	Sell Side = "SELL"
+)
+
+// OrderType selects how an order is triggered and priced
+type OrderType string
+
+const (
+	Market OrderType = "MARKET"
+	Limit  OrderType = "LIMIT"
+	Stop   OrderType = "STOP"
+)
+
+// OrderStatus is the lifecycle state of an order
+type OrderStatus string
+
+const (
+	StatusNew      OrderStatus = "NEW"
+	StatusFilled   OrderStatus = "FILLED"
+	StatusCanceled OrderStatus = "CANCELED"
+	StatusRejected OrderStatus = "REJECTED"
+)
+
+// OrderRequest describes an order to place. Price is used by limit orders and
+// StopPrice by stop orders.
+type OrderRequest struct {
+	Symbol    string
+	Side      Side
+	Type      OrderType
+	Quantity  float64
+	Price     float64
+	StopPrice float64
+}
+
+// Order is an order known to a broker
+type Order struct {
+	ID        string
+	Symbol    string
+	Side      Side
+	Type      OrderType
+	Quantity  float64
+	Price     float64
+	StopPrice float64
+	Status    OrderStatus
+	CreatedAt time.Time
+	FilledAt  time.Time
+	FillPrice float64
+	Fee       float64 // Commission in quote currency
+	Reason    string  // Why the order was rejected, if it was
+}
+
+// Position is an open holding of one symbol
+type Position struct {
+	Symbol     string
+	Quantity   float64
+	EntryPrice float64 // Average entry price
+	OpenedAt   time.Time
+}
+
+// Fill reports an order that was executed
+type Fill struct {
+	Order       Order
+	RealizedPnL float64 // Profit of the closed quantity after fees, 0 for opening fills
+}
+
+// Broker is the order interface shared by the paper and live executors
+type Broker interface {
+	PlaceOrder(ctx context.Context, req OrderRequest) (Order, error)
+	CancelOrder(ctx context.Context, symbol, orderID string) error
+	OpenOrders(ctx context.Context, symbol string) ([]Order, error)
+	Positions(ctx context.Context) ([]Position, error)
+	// Balance returns the free quote currency balance
+	Balance(ctx context.Context) (float64, error)
+}
+
+// CandleFeeder is implemented by brokers that need every closed candle, such as the
+// paper broker which matches its orders against candle highs and lows
+type CandleFeeder interface {
+	OnCandle(c klinesfrombinance.Candle) ([]Fill, error)
+}
+
+// OrderQuerier is implemented by brokers that do not see the candles, such as the live
+// broker, whose orders can fill at any time. Order returns the current state of an order.
+type OrderQuerier interface {
+	Order(ctx context.Context, symbol, orderID string) (Order, error)
+}
+
+// MarketFiller is implemented by brokers that fill market orders only with the next
+// candle, such as the paper broker. FillMarket fills the open market orders of a symbol
+// at price right away, for when no next candle will come.
+type MarketFiller interface {
+	FillMarket(symbol string, price float64, at time.Time) ([]Fill, error)
+}
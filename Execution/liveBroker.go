@@ -0,0 +1,200 @@
+package execution
+
+import (
+	"context"
+	"fmt"
+	binanceclient "learnGoLang/BinanceClient"
+	"strconv"
+	"time"
+)
+
+// LiveBroker places real spot orders on Binance through the signed REST client.
+// Quantities and prices are rounded to the symbol filters before they are sent.
+type LiveBroker struct {
+	client     *binanceclient.Client
+	symbols    map[string]*binanceclient.SymbolInfo
+	quoteAsset string
+}
+
+// NewLiveBroker synchronizes the clock with Binance and loads the trading rules of
+// the given symbols. The first symbol's quote asset is reported by Balance.
+func NewLiveBroker(ctx context.Context, client *binanceclient.Client, symbols ...string) (*LiveBroker, error) {
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("live broker needs at least one symbol")
+	}
+	if err := client.SyncTime(ctx); err != nil {
+		return nil, err
+	}
+	b := &LiveBroker{client: client, symbols: make(map[string]*binanceclient.SymbolInfo)}
+	for _, symbol := range symbols {
+		info, err := client.ExchangeInfo(ctx, symbol)
+		if err != nil {
+			return nil, err
+		}
+		b.symbols[symbol] = info
+	}
+	b.quoteAsset = b.symbols[symbols[0]].QuoteAsset
+	return b, nil
+}
+
+// PlaceOrder sends an order to Binance
+func (b *LiveBroker) PlaceOrder(ctx context.Context, req OrderRequest) (Order, error) {
+	if err := validateRequest(req); err != nil {
+		return Order{}, err
+	}
+	info, ok := b.symbols[req.Symbol]
+	if !ok {
+		return Order{}, fmt.Errorf("symbol %s is not configured for live trading", req.Symbol)
+	}
+
+	params := binanceclient.OrderParams{
+		Symbol:   req.Symbol,
+		Side:     string(req.Side),
+		Quantity: info.Filters.RoundQuantity(req.Quantity),
+	}
+	checkPrice := 0.0
+	switch req.Type {
+	case Market:
+		params.Type = "MARKET"
+	case Limit:
+		params.Type = "LIMIT"
+		params.TimeInForce = "GTC"
+		params.Price = info.Filters.RoundPrice(req.Price)
+		checkPrice = params.Price
+	case Stop:
+		params.Type = "STOP_LOSS"
+		params.StopPrice = info.Filters.RoundPrice(req.StopPrice)
+		checkPrice = params.StopPrice
+	}
+	if err := info.Filters.Check(params.Quantity, checkPrice); err != nil {
+		return Order{}, fmt.Errorf("order rejected by %s filters: %w", req.Symbol, err)
+	}
+
+	resp, err := b.client.PlaceOrder(ctx, params)
+	if err != nil {
+		return Order{}, err
+	}
+	return convertOrder(*resp), nil
+}
+
+// CancelOrder cancels an open order
+func (b *LiveBroker) CancelOrder(ctx context.Context, symbol, orderID string) error {
+	id, err := strconv.ParseInt(orderID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid Binance order ID '%s': %w", orderID, err)
+	}
+	_, err = b.client.CancelOrder(ctx, symbol, id)
+	return err
+}
+
+// Order queries the current state of an order
+func (b *LiveBroker) Order(ctx context.Context, symbol, orderID string) (Order, error) {
+	id, err := strconv.ParseInt(orderID, 10, 64)
+	if err != nil {
+		return Order{}, fmt.Errorf("invalid Binance order ID '%s': %w", orderID, err)
+	}
+	resp, err := b.client.QueryOrder(ctx, symbol, id)
+	if err != nil {
+		return Order{}, err
+	}
+	return convertOrder(*resp), nil
+}
+
+// OpenOrders returns the open orders of a symbol
+func (b *LiveBroker) OpenOrders(ctx context.Context, symbol string) ([]Order, error) {
+	resp, err := b.client.OpenOrders(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	orders := make([]Order, 0, len(resp))
+	for _, o := range resp {
+		orders = append(orders, convertOrder(o))
+	}
+	return orders, nil
+}
+
+// Positions reports the base asset holdings of the configured symbols. Binance does
+// not track entry prices for spot holdings, so EntryPrice is left at 0.
+func (b *LiveBroker) Positions(ctx context.Context) ([]Position, error) {
+	account, err := b.client.Account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var positions []Position
+	for symbol, info := range b.symbols {
+		for _, balance := range account.Balances {
+			qty := balance.Free + balance.Locked
+			if balance.Asset == info.BaseAsset && qty > 0 && qty >= info.Filters.MinQty {
+				positions = append(positions, Position{Symbol: symbol, Quantity: qty})
+			}
+		}
+	}
+	return positions, nil
+}
+
+// Balance returns the free quote asset balance
+func (b *LiveBroker) Balance(ctx context.Context) (float64, error) {
+	account, err := b.client.Account(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, balance := range account.Balances {
+		if balance.Asset == b.quoteAsset {
+			return balance.Free, nil
+		}
+	}
+	return 0, nil
+}
+
+// convertOrder maps a Binance order onto the broker order type
+func convertOrder(o binanceclient.OrderResponse) Order {
+	order := Order{
+		ID:        strconv.FormatInt(o.OrderID, 10),
+		Symbol:    o.Symbol,
+		Side:      Side(o.Side),
+		Quantity:  o.OrigQty,
+		Price:     o.Price,
+		StopPrice: o.StopPrice,
+	}
+	switch o.Type {
+	case "MARKET":
+		order.Type = Market
+	case "LIMIT", "LIMIT_MAKER":
+		order.Type = Limit
+	default:
+		order.Type = Stop
+	}
+	switch o.Status {
+	case "FILLED":
+		order.Status = StatusFilled
+	case "CANCELED", "EXPIRED", "EXPIRED_IN_MATCH":
+		order.Status = StatusCanceled
+		if o.ExecutedQty > 0 {
+			// The executed part is held all the same, so it is reported as a smaller fill
+			order.Status, order.Quantity = StatusFilled, o.ExecutedQty
+		}
+	case "REJECTED":
+		order.Status = StatusRejected
+	default:
+		order.Status = StatusNew
+	}
+
+	created := o.TransactTime
+	if created == 0 {
+		created = o.Time
+	}
+	order.CreatedAt = time.UnixMilli(created)
+	if o.ExecutedQty > 0 {
+		order.FillPrice = o.CummulativeQuoteQty / o.ExecutedQty
+		if order.Status == StatusFilled {
+			order.FilledAt = order.CreatedAt
+			if o.UpdateTime > 0 {
+				order.FilledAt = time.UnixMilli(o.UpdateTime)
+			}
+		}
+	}
+	for _, f := range o.Fills {
+		order.Fee += f.Commission
+	}
+	return order
+}
@@ -0,0 +1,307 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PaperBroker simulates order execution against closed candles. Market orders fill at
+// the open of the next candle, limit and stop orders fill when the candle range reaches
+// their price. State is saved to disk after every change so it survives restarts.
+type PaperBroker struct {
+	mu                sync.Mutex
+	statePath         string
+	commissionPercent float64
+	slippagePoints    float64
+	state             paperState
+}
+
+// paperState is the part of the paper broker persisted to disk
+type paperState struct {
+	Balance     float64              `json:"balance"`
+	Positions   map[string]*Position `json:"positions"`
+	OpenOrders  []Order              `json:"open_orders"`
+	NextOrderID int64                `json:"next_order_id"`
+	RealizedPnL float64              `json:"realized_pnl"`
+	FeesPaid    float64              `json:"fees_paid"`
+}
+
+// NewPaperBroker creates a paper broker. If statePath already holds a saved state it is
+// restored, otherwise the broker starts with initialBalance of quote currency.
+func NewPaperBroker(statePath string, initialBalance, commissionPercent, slippagePoints float64) (*PaperBroker, error) {
+	b := &PaperBroker{
+		statePath:         statePath,
+		commissionPercent: commissionPercent,
+		slippagePoints:    slippagePoints,
+		state: paperState{
+			Balance:   initialBalance,
+			Positions: make(map[string]*Position),
+		},
+	}
+
+	data, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return b, b.save()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read paper state: %w", err)
+	}
+	if err := json.Unmarshal(data, &b.state); err != nil {
+		return nil, fmt.Errorf("failed to parse paper state %s: %w", statePath, err)
+	}
+	if b.state.Positions == nil {
+		b.state.Positions = make(map[string]*Position)
+	}
+	return b, nil
+}
+
+// PlaceOrder queues an order. It is matched against the next candle passed to OnCandle.
+func (b *PaperBroker) PlaceOrder(ctx context.Context, req OrderRequest) (Order, error) {
+	if err := validateRequest(req); err != nil {
+		return Order{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state.NextOrderID++
+	order := Order{
+		ID:        "paper-" + strconv.FormatInt(b.state.NextOrderID, 10),
+		Symbol:    req.Symbol,
+		Side:      req.Side,
+		Type:      req.Type,
+		Quantity:  req.Quantity,
+		Price:     req.Price,
+		StopPrice: req.StopPrice,
+		Status:    StatusNew,
+		CreatedAt: time.Now(),
+	}
+	b.state.OpenOrders = append(b.state.OpenOrders, order)
+	return order, b.save()
+}
+
+// CancelOrder cancels an open order
+func (b *PaperBroker) CancelOrder(ctx context.Context, symbol, orderID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, o := range b.state.OpenOrders {
+		if o.ID == orderID && o.Symbol == symbol {
+			b.state.OpenOrders = append(b.state.OpenOrders[:i], b.state.OpenOrders[i+1:]...)
+			return b.save()
+		}
+	}
+	return fmt.Errorf("order %s not found for %s", orderID, symbol)
+}
+
+// OpenOrders returns the unfilled orders of a symbol, or of every symbol if symbol is empty
+func (b *PaperBroker) OpenOrders(ctx context.Context, symbol string) ([]Order, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var orders []Order
+	for _, o := range b.state.OpenOrders {
+		if symbol == "" || o.Symbol == symbol {
+			orders = append(orders, o)
+		}
+	}
+	return orders, nil
+}
+
+// Positions returns the open positions
+func (b *PaperBroker) Positions(ctx context.Context) ([]Position, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	positions := make([]Position, 0, len(b.state.Positions))
+	for _, p := range b.state.Positions {
+		positions = append(positions, *p)
+	}
+	return positions, nil
+}
+
+// Balance returns the virtual quote currency balance
+func (b *PaperBroker) Balance(ctx context.Context) (float64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state.Balance, nil
+}
+
+// RealizedPnL returns the profit realized by closing fills, after fees
+func (b *PaperBroker) RealizedPnL() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state.RealizedPnL
+}
+
+// OnCandle matches the open orders of the candle's symbol against its range and
+// returns the resulting fills. Orders that trigger but cannot be executed are
+// returned with StatusRejected.
+func (b *PaperBroker) OnCandle(c klinesfrombinance.Candle) ([]Fill, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var fills []Fill
+	remaining := b.state.OpenOrders[:0]
+	for _, o := range b.state.OpenOrders {
+		if o.Symbol != c.Symbol {
+			remaining = append(remaining, o)
+			continue
+		}
+		price, triggered := b.fillPrice(o, c)
+		if !triggered {
+			remaining = append(remaining, o)
+			continue
+		}
+		fills = append(fills, b.execute(o, price, c.Datetime))
+	}
+	b.state.OpenOrders = remaining
+
+	if len(fills) == 0 {
+		return nil, nil
+	}
+	return fills, b.save()
+}
+
+// FillMarket fills the open market orders of the symbol at price, with slippage, as if
+// the next candle opened there. Limit and stop orders stay open.
+func (b *PaperBroker) FillMarket(symbol string, price float64, at time.Time) ([]Fill, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var fills []Fill
+	remaining := b.state.OpenOrders[:0]
+	for _, o := range b.state.OpenOrders {
+		if o.Symbol != symbol || o.Type != Market {
+			remaining = append(remaining, o)
+			continue
+		}
+		fillPrice, _ := b.fillPrice(o, klinesfrombinance.Candle{Open: price, High: price, Low: price})
+		fills = append(fills, b.execute(o, fillPrice, at))
+	}
+	b.state.OpenOrders = remaining
+
+	if len(fills) == 0 {
+		return nil, nil
+	}
+	return fills, b.save()
+}
+
+// fillPrice reports whether the candle triggers the order and at which price it fills
+func (b *PaperBroker) fillPrice(o Order, c klinesfrombinance.Candle) (float64, bool) {
+	switch {
+	case o.Type == Market && o.Side == Buy:
+		return c.Open + b.slippagePoints, true
+	case o.Type == Market && o.Side == Sell:
+		return c.Open - b.slippagePoints, true
+	case o.Type == Limit && o.Side == Buy && c.Low <= o.Price:
+		return math.Min(c.Open, o.Price), true
+	case o.Type == Limit && o.Side == Sell && c.High >= o.Price:
+		return math.Max(c.Open, o.Price), true
+	case o.Type == Stop && o.Side == Buy && c.High >= o.StopPrice:
+		return math.Max(c.Open, o.StopPrice) + b.slippagePoints, true
+	case o.Type == Stop && o.Side == Sell && c.Low <= o.StopPrice:
+		return math.Min(c.Open, o.StopPrice) - b.slippagePoints, true
+	}
+	return 0, false
+}
+
+// execute applies a triggered order to the balance and positions
+func (b *PaperBroker) execute(o Order, price float64, at time.Time) Fill {
+	notional := price * o.Quantity
+	fee := notional * b.commissionPercent / 100
+	position := b.state.Positions[o.Symbol]
+
+	var realized float64
+	switch o.Side {
+	case Buy:
+		if notional+fee > b.state.Balance {
+			o.Status = StatusRejected
+			o.Reason = fmt.Sprintf("insufficient balance: need %.2f, have %.2f", notional+fee, b.state.Balance)
+			return Fill{Order: o}
+		}
+		b.state.Balance -= notional + fee
+		if position == nil {
+			position = &Position{Symbol: o.Symbol, OpenedAt: at}
+			b.state.Positions[o.Symbol] = position
+		}
+		position.EntryPrice = (position.EntryPrice*position.Quantity + notional) / (position.Quantity + o.Quantity)
+		position.Quantity += o.Quantity
+	case Sell:
+		if position == nil || position.Quantity < o.Quantity-1e-12 {
+			o.Status = StatusRejected
+			o.Reason = "sell quantity exceeds the open position"
+			return Fill{Order: o}
+		}
+		b.state.Balance += notional - fee
+		realized = (price-position.EntryPrice)*o.Quantity - fee
+		position.Quantity -= o.Quantity
+		if position.Quantity <= 1e-12 {
+			delete(b.state.Positions, o.Symbol)
+		}
+		b.state.RealizedPnL += realized
+	}
+
+	b.state.FeesPaid += fee
+	o.Status = StatusFilled
+	o.FilledAt = at
+	o.FillPrice = price
+	o.Fee = fee
+	return Fill{Order: o, RealizedPnL: realized}
+}
+
+// save writes the state to a temporary file and renames it over the previous state
+func (b *PaperBroker) save() error {
+	data, err := json.MarshalIndent(b.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode paper state: %w", err)
+	}
+	if dir := filepath.Dir(b.statePath); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create paper state folder: %w", err)
+		}
+	}
+	tmpPath := b.statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write paper state: %w", err)
+	}
+	if err := os.Rename(tmpPath, b.statePath); err != nil {
+		return fmt.Errorf("failed to replace paper state: %w", err)
+	}
+	return nil
+}
+
+// validateRequest checks that an order request is complete for its type
+func validateRequest(req OrderRequest) error {
+	if req.Symbol == "" {
+		return fmt.Errorf("order symbol is empty")
+	}
+	if req.Side != Buy && req.Side != Sell {
+		return fmt.Errorf("invalid order side '%s'", req.Side)
+	}
+	if req.Quantity <= 0 {
+		return fmt.Errorf("order quantity must be positive, got %f", req.Quantity)
+	}
+	switch req.Type {
+	case Market:
+	case Limit:
+		if req.Price <= 0 {
+			return fmt.Errorf("limit order needs a positive price")
+		}
+	case Stop:
+		if req.StopPrice <= 0 {
+			return fmt.Errorf("stop order needs a positive stop price")
+		}
+	default:
+		return fmt.Errorf("invalid order type '%s'", req.Type)
+	}
+	return nil
+}
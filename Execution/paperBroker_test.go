@@ -0,0 +1,119 @@
+package execution
+
+import (
+	"context"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func candle(open, high, low, close float64) klinesfrombinance.Candle {
+	dt := time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC)
+	return klinesfrombinance.Candle{Symbol: "ETHUSDT", Timestamp: dt.UnixMilli(), Datetime: dt, Open: open, High: high, Low: low, Close: close}
+}
+
+func newTestBroker(t *testing.T) (*PaperBroker, string) {
+	path := filepath.Join(t.TempDir(), "paper_state.json")
+	b, err := NewPaperBroker(path, 10000, 0.1, 1)
+	if err != nil {
+		t.Fatalf("NewPaperBroker returned error: %v", err)
+	}
+	return b, path
+}
+
+func TestPaperMarketOrderFillsAtNextOpen(t *testing.T) {
+	ctx := context.Background()
+	b, _ := newTestBroker(t)
+
+	if _, err := b.PlaceOrder(ctx, OrderRequest{Symbol: "ETHUSDT", Side: Buy, Type: Market, Quantity: 2}); err != nil {
+		t.Fatalf("PlaceOrder returned error: %v", err)
+	}
+	fills, err := b.OnCandle(candle(3000, 3010, 2990, 3005))
+	if err != nil || len(fills) != 1 {
+		t.Fatalf("got %d fills (err %v), want 1", len(fills), err)
+	}
+	order := fills[0].Order
+	if order.Status != StatusFilled || order.FillPrice != 3001 {
+		t.Errorf("fill = %+v, want filled at 3001 (open + slippage)", order)
+	}
+	wantFee := 3001 * 2 * 0.001
+	if math.Abs(order.Fee-wantFee) > 1e-9 {
+		t.Errorf("fee = %f, want %f", order.Fee, wantFee)
+	}
+	balance, _ := b.Balance(ctx)
+	if math.Abs(balance-(10000-6002-wantFee)) > 1e-9 {
+		t.Errorf("balance = %f", balance)
+	}
+}
+
+func TestPaperLimitAndStopOrders(t *testing.T) {
+	ctx := context.Background()
+	b, _ := newTestBroker(t)
+
+	b.PlaceOrder(ctx, OrderRequest{Symbol: "ETHUSDT", Side: Buy, Type: Limit, Quantity: 1, Price: 2950})
+	if fills, _ := b.OnCandle(candle(3000, 3010, 2960, 3005)); len(fills) != 0 {
+		t.Fatalf("limit order filled before its price was reached: %+v", fills)
+	}
+	fills, _ := b.OnCandle(candle(3000, 3010, 2940, 2990))
+	if len(fills) != 1 || fills[0].Order.FillPrice != 2950 {
+		t.Fatalf("limit fill = %+v, want price 2950", fills)
+	}
+
+	b.PlaceOrder(ctx, OrderRequest{Symbol: "ETHUSDT", Side: Sell, Type: Stop, Quantity: 1, StopPrice: 2900})
+	// Gap below the stop: the fill happens at the open, not the stop price
+	fills, _ = b.OnCandle(candle(2880, 2890, 2870, 2885))
+	if len(fills) != 1 || fills[0].Order.FillPrice != 2879 {
+		t.Fatalf("stop fill = %+v, want price 2879 (open - slippage)", fills)
+	}
+	if fills[0].RealizedPnL >= 0 {
+		t.Errorf("expected a realized loss, got %f", fills[0].RealizedPnL)
+	}
+	if positions, _ := b.Positions(ctx); len(positions) != 0 {
+		t.Errorf("expected the position to be closed, got %+v", positions)
+	}
+}
+
+func TestPaperRejectsOversizedOrders(t *testing.T) {
+	ctx := context.Background()
+	b, _ := newTestBroker(t)
+
+	b.PlaceOrder(ctx, OrderRequest{Symbol: "ETHUSDT", Side: Buy, Type: Market, Quantity: 10})
+	b.PlaceOrder(ctx, OrderRequest{Symbol: "ETHUSDT", Side: Sell, Type: Market, Quantity: 1})
+	fills, _ := b.OnCandle(candle(3000, 3010, 2990, 3005))
+	if len(fills) != 2 || fills[0].Order.Status != StatusRejected || fills[1].Order.Status != StatusRejected {
+		t.Fatalf("expected both orders to be rejected, got %+v", fills)
+	}
+	if _, err := b.PlaceOrder(ctx, OrderRequest{Symbol: "ETHUSDT", Side: Buy, Type: Limit, Quantity: 1}); err == nil {
+		t.Error("expected an error for a limit order without price")
+	}
+}
+
+func TestPaperStateSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	b, path := newTestBroker(t)
+
+	b.PlaceOrder(ctx, OrderRequest{Symbol: "ETHUSDT", Side: Buy, Type: Market, Quantity: 1})
+	b.OnCandle(candle(3000, 3010, 2990, 3005))
+	b.PlaceOrder(ctx, OrderRequest{Symbol: "ETHUSDT", Side: Sell, Type: Limit, Quantity: 1, Price: 3100})
+
+	restored, err := NewPaperBroker(path, 10000, 0.1, 1)
+	if err != nil {
+		t.Fatalf("NewPaperBroker returned error: %v", err)
+	}
+	want, _ := b.Balance(ctx)
+	if got, _ := restored.Balance(ctx); got != want {
+		t.Errorf("restored balance = %f, want %f", got, want)
+	}
+	if positions, _ := restored.Positions(ctx); len(positions) != 1 || positions[0].Quantity != 1 {
+		t.Errorf("restored positions = %+v", positions)
+	}
+	orders, _ := restored.OpenOrders(ctx, "ETHUSDT")
+	if len(orders) != 1 || orders[0].Price != 3100 {
+		t.Fatalf("restored open orders = %+v", orders)
+	}
+	if err := restored.CancelOrder(ctx, "ETHUSDT", orders[0].ID); err != nil {
+		t.Errorf("CancelOrder returned error: %v", err)
+	}
+}
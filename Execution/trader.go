@@ -0,0 +1,474 @@
+package execution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	notifier "learnGoLang/Notifier"
+	positionmanager "learnGoLang/PositionManager"
+	risk "learnGoLang/Risk"
+	strategy "learnGoLang/Strategy"
+	timeframe "learnGoLang/Timeframe"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// Trader runs a strategy against a broker, the paper and live counterpart of
+// backtest.Run. Every closed candle goes to the broker first, so that orders placed on
+// the previous candle can fill, and is then resampled to the entry timeframe for the
+// strategy and the position manager, which decides the exits as in the backtest. Brokers
+// that do not see the candles, such as the live broker, are asked instead for the state of
+// the orders still waiting for a fill. Entry
+// signals buy the quantity the risk manager sizes them to and exits sell the whole
+// position at market. The brokers trade spot, so short entries are skipped. A paused
+// trader still exits, but skips the entries. The methods are safe for concurrent use, so
+// the trader can be controlled while it runs.
+type Trader struct {
+	// Allocation is the largest fraction of the free balance spent on an entry. The rest
+	// covers fees and slippage.
+	Allocation float64
+	// Notifier receives the trade events, nil sends none
+	Notifier notifier.Notifier
+
+	broker    Broker
+	strategy  strategy.Strategy
+	symbol    string
+	interval  time.Duration // Length of the candles passed to OnCandle
+	resampler *timeframe.Resampler
+	manager   *positionmanager.Manager
+	risk      *risk.Manager
+	entryFee  float64                    // Commission of the open position's entry, for the profit reported to the risk manager
+	pending   map[string]strategy.Signal // Signals of the orders waiting for a fill, by order ID
+
+	mu       sync.Mutex
+	paused   bool
+	last     klinesfrombinance.Candle // Latest closed candle
+	realized float64                  // Profit of the positions closed since the start, after fees
+	trades   []ClosedTrade            // Round trips closed within tradeRetention of the latest one
+}
+
+// tradeRetention is how long the closed trades are kept for Trades, enough for a weekly digest
+const tradeRetention = 8 * 24 * time.Hour
+
+// ClosedTrade is a round trip of the trader
+type ClosedTrade struct {
+	Symbol     string
+	EntryTime  time.Time
+	ExitTime   time.Time
+	Quantity   float64
+	EntryPrice float64
+	ExitPrice  float64
+	Fees       float64 // Commission of the entry and the exit
+	Profit     float64 // After fees
+	Reason     string
+}
+
+// Status is a snapshot of a trader
+type Status struct {
+	Symbol        string
+	Strategy      string
+	Paused        bool
+	Balance       float64    // Free quote currency balance
+	Equity        float64    // Balance plus the positions, the symbol's at LastPrice and the others at their entry price
+	Positions     []Position // Every position the broker holds
+	LastPrice     float64    // Close of the latest candle, 0 before the first one
+	LastCandle    time.Time
+	RealizedPnL   float64 // Profit of the positions closed since the start, after fees
+	UnrealizedPnL float64 // Profit of the symbol's position at LastPrice, before the exit fee
+	PendingOrders int     // Orders of the trader waiting for a fill
+}
+
+// NewTrader creates a trader for one symbol whose candles arrive at baseInterval and
+// whose strategy runs at entryInterval. The risk manager may be shared with the traders
+// of other symbols.
+func NewTrader(broker Broker, strat strategy.Strategy, symbol string, baseInterval, entryInterval time.Duration, positions positionmanager.Config, sizing *risk.Manager) (*Trader, error) {
+	resampler, err := timeframe.NewResampler(baseInterval, entryInterval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up entry timeframe: %w", err)
+	}
+	if err := positions.Validate(); err != nil {
+		return nil, err
+	}
+	return &Trader{
+		Allocation: 0.95,
+		broker:     broker,
+		strategy:   strat,
+		symbol:     symbol,
+		interval:   baseInterval,
+		resampler:  resampler,
+		manager:    positionmanager.NewManager(positions),
+		risk:       sizing,
+		pending:    make(map[string]strategy.Signal),
+	}, nil
+}
+
+// Warmup feeds historical candles to the strategy without trading, so its indicators
+// are ready, and then tells it about a position the broker already holds
+func (t *Trader) Warmup(ctx context.Context, candles []klinesfrombinance.Candle) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, c := range candles {
+		for _, bar := range t.resampler.Add(c) {
+			t.strategy.OnCandle(bar)
+		}
+	}
+	if len(candles) > 0 {
+		t.last = candles[len(candles)-1]
+	}
+
+	position, err := t.position(ctx)
+	if err != nil {
+		return err
+	}
+	if position != nil {
+		// The high-water mark since the entry is unknown, the trailing stop starts from the entry price
+		t.manager.Open(1, position.OpenedAt, position.EntryPrice, 0)
+		t.risk.Opened(t.symbol, position.Quantity*position.EntryPrice)
+		t.strategy.OnFill(strategy.Fill{Action: strategy.EnterLong, Time: position.OpenedAt, Price: position.EntryPrice, Quantity: position.Quantity})
+	}
+	return nil
+}
+
+// OnCandle processes the next closed candle and returns the fills it caused, both the
+// broker's fills of earlier orders and orders that filled as soon as they were placed
+func (t *Trader) OnCandle(ctx context.Context, c klinesfrombinance.Candle) ([]Fill, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last = c
+	var fills []Fill
+	var errs []error
+	if feeder, ok := t.broker.(CandleFeeder); ok {
+		matched, err := feeder.OnCandle(c)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, f := range matched {
+			t.settle(f.Order)
+		}
+		fills = append(fills, matched...)
+	} else if querier, ok := t.broker.(OrderQuerier); ok {
+		polled, err := t.poll(ctx, querier)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		fills = append(fills, polled...)
+	}
+
+	for _, bar := range t.resampler.Add(c) {
+		t.strategy.OnCandle(bar)
+		t.risk.OnCandle(bar)
+		signals := t.strategy.Signals()
+		if exit, ok := t.manager.Check(bar, signals); ok {
+			signals = []strategy.Signal{{Action: strategy.Exit, Time: bar.Datetime, Price: exit.Price, Reason: exit.Reason}}
+		}
+		for len(signals) > 0 {
+			sig := signals[0]
+			signals = signals[1:]
+			log.Printf("%s signal %s at %.2f: %s\n", t.strategy.Name(), sig.Action, sig.Price, sig.Reason)
+			if len(t.pending) > 0 {
+				log.Printf("Skipping the signal, %d order(s) of %s are still waiting for a fill\n", len(t.pending), t.symbol)
+				continue
+			}
+			if t.paused && sig.Action != strategy.Exit {
+				log.Printf("Skipping the signal, trading is paused\n")
+				continue
+			}
+			order, err := t.execute(ctx, sig)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if order.Status == StatusFilled {
+				fills = append(fills, Fill{Order: order})
+			}
+			if sig.Action == strategy.Exit && t.manager.Position() == nil && len(t.pending) == 0 {
+				// Flat again: the strategy may enter on the same bar, as in the backtest
+				signals = entries(t.strategy.Signals())
+			}
+		}
+	}
+	return fills, errors.Join(errs...)
+}
+
+// poll asks the broker for the state of the orders waiting for a fill and settles the
+// ones that are done
+func (t *Trader) poll(ctx context.Context, querier OrderQuerier) ([]Fill, error) {
+	var fills []Fill
+	var errs []error
+	for id := range t.pending {
+		order, err := querier.Order(ctx, t.symbol, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to query order %s: %w", id, err))
+			continue
+		}
+		t.settle(order)
+		if order.Status == StatusFilled {
+			fills = append(fills, Fill{Order: order})
+		}
+	}
+	return fills, errors.Join(errs...)
+}
+
+// execute places the order of a signal. An order that does not fill right away is
+// remembered until the broker reports its fill.
+func (t *Trader) execute(ctx context.Context, sig strategy.Signal) (Order, error) {
+	req := OrderRequest{Symbol: t.symbol, Type: Market}
+	switch sig.Action {
+	case strategy.EnterShort:
+		log.Printf("Short entries are not supported by spot brokers, skipping\n")
+		return Order{}, nil
+	case strategy.EnterLong:
+		if err := t.manager.CheckEntry(1, sig.Price, sig.StopLoss); err != nil {
+			log.Printf("Entry refused: %v\n", err)
+			return Order{}, nil
+		}
+		balance, err := t.broker.Balance(ctx)
+		if err != nil {
+			return Order{}, fmt.Errorf("failed to read balance: %w", err)
+		}
+		equity, err := t.equity(ctx, balance)
+		if err != nil {
+			return Order{}, err
+		}
+		quantity, err := t.risk.Size(risk.Entry{Symbol: t.symbol, Time: sig.Time, Price: sig.Price, StopLoss: t.manager.StopLevel(1, sig.Price, sig.StopLoss), Equity: equity})
+		if errors.Is(err, risk.ErrRefused) {
+			log.Printf("Entry refused: %v\n", err)
+			return Order{}, nil
+		}
+		if err != nil {
+			return Order{}, err
+		}
+		req.Side = Buy
+		req.Quantity = math.Min(quantity, balance*t.Allocation/sig.Price)
+	case strategy.Exit:
+		position, err := t.position(ctx)
+		if err != nil {
+			return Order{}, err
+		}
+		if position == nil {
+			// Nothing to sell, e.g. the position was closed by hand
+			t.manager.Close()
+			t.risk.Closed(t.symbol, sig.Time, 0)
+			t.strategy.OnFill(strategy.Fill{Action: strategy.Exit, Time: sig.Time, Price: sig.Price})
+			return Order{}, nil
+		}
+		req.Side = Sell
+		req.Quantity = position.Quantity
+	default:
+		return Order{}, fmt.Errorf("unknown signal action '%s'", sig.Action)
+	}
+
+	order, err := t.broker.PlaceOrder(ctx, req)
+	if err != nil {
+		return Order{}, fmt.Errorf("failed to place %s order: %w", req.Side, err)
+	}
+	t.pending[order.ID] = sig
+	t.settle(order)
+	return order, nil
+}
+
+// settle tells the position manager and the strategy about a filled order they are
+// waiting for
+func (t *Trader) settle(o Order) {
+	sig, ok := t.pending[o.ID]
+	if !ok || o.Status == StatusNew {
+		return
+	}
+	delete(t.pending, o.ID)
+	if o.Status == StatusRejected {
+		t.notify(notifier.Message(notifier.Warning, fmt.Sprintf("%s order %s of %s rejected: %s", o.Side, o.ID, t.symbol, o.Reason), o.CreatedAt))
+	}
+	if o.Status != StatusFilled {
+		return
+	}
+	if sig.Action == strategy.Exit {
+		trade := ClosedTrade{Symbol: t.symbol, ExitTime: o.FilledAt, Quantity: o.Quantity, ExitPrice: o.FillPrice, Fees: o.Fee + t.entryFee, Reason: sig.Reason}
+		profit := -trade.Fees
+		if pos := t.manager.Position(); pos != nil {
+			trade.EntryTime, trade.EntryPrice = pos.EntryTime, pos.EntryPrice
+			profit += (o.FillPrice - pos.EntryPrice) * o.Quantity
+		}
+		trade.Profit = profit
+		t.manager.Close()
+		t.risk.Closed(t.symbol, o.FilledAt, profit)
+		t.realized += profit
+		t.record(trade)
+		t.notify(notifier.TradeClosed(t.symbol, o.Quantity, o.FillPrice, profit, sig.Reason, o.FilledAt))
+	} else {
+		t.manager.Open(1, o.FilledAt, o.FillPrice, sig.StopLoss)
+		t.risk.Opened(t.symbol, o.Quantity*o.FillPrice)
+		t.entryFee = o.Fee
+		t.notify(notifier.TradeOpened(t.symbol, "long", o.Quantity, o.FillPrice, o.FilledAt))
+	}
+	t.strategy.OnFill(strategy.Fill{Action: sig.Action, Time: o.FilledAt, Price: o.FillPrice, Quantity: o.Quantity})
+}
+
+// Pause stops the entries until Resume, while the exits go on
+func (t *Trader) Pause() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.paused = true
+}
+
+// Resume lets the strategy enter again after Pause
+func (t *Trader) Resume() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.paused = false
+}
+
+// Status reads the balance and the positions from the broker
+func (t *Trader) Status(ctx context.Context) (Status, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := Status{
+		Symbol:        t.symbol,
+		Strategy:      t.strategy.Name(),
+		Paused:        t.paused,
+		LastPrice:     t.last.Close,
+		LastCandle:    t.last.Datetime,
+		RealizedPnL:   t.realized,
+		PendingOrders: len(t.pending),
+	}
+	balance, err := t.broker.Balance(ctx)
+	if err != nil {
+		return Status{}, fmt.Errorf("failed to read balance: %w", err)
+	}
+	positions, err := t.broker.Positions(ctx)
+	if err != nil {
+		return Status{}, fmt.Errorf("failed to read positions: %w", err)
+	}
+	status.Balance, status.Equity, status.Positions = balance, balance, positions
+	for _, p := range positions {
+		price := p.EntryPrice
+		if p.Symbol == t.symbol && status.LastPrice > 0 {
+			price = status.LastPrice
+			status.UnrealizedPnL = (price - p.EntryPrice) * p.Quantity
+		}
+		status.Equity += p.Quantity * price
+	}
+	return status, nil
+}
+
+// Trades returns the round trips closed at or after since. Only the last 8 days are kept.
+func (t *Trader) Trades(since time.Time) []ClosedTrade {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var trades []ClosedTrade
+	for _, trade := range t.trades {
+		if !trade.ExitTime.Before(since) {
+			trades = append(trades, trade)
+		}
+	}
+	return trades
+}
+
+// record adds a closed trade and forgets the ones older than tradeRetention
+func (t *Trader) record(trade ClosedTrade) {
+	t.trades = append(t.trades, trade)
+	cutoff := trade.ExitTime.Add(-tradeRetention)
+	for len(t.trades) > 0 && t.trades[0].ExitTime.Before(cutoff) {
+		t.trades = t.trades[1:]
+	}
+}
+
+// CloseAll pauses the trader, cancels its orders waiting for a fill and sells the
+// position of the symbol at market. The returned order is empty when there was no
+// position.
+func (t *Trader) CloseAll(ctx context.Context) (Order, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closeAll(ctx)
+}
+
+// CloseNow works like CloseAll, but a broker that fills market orders with the next
+// candle, such as the paper broker, fills the sell at the close of the latest candle.
+// It is meant for shutting down, when no next candle will come: a sell left open would
+// only fill after a restart, while the restarted trader already manages the position.
+func (t *Trader) CloseNow(ctx context.Context) (Order, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	order, err := t.closeAll(ctx)
+	filler, ok := t.broker.(MarketFiller)
+	if err != nil || order.Status != StatusNew || !ok || t.last.Close <= 0 {
+		return order, err
+	}
+	fills, err := filler.FillMarket(t.symbol, t.last.Close, t.last.Datetime.Add(t.interval))
+	if err != nil {
+		return order, fmt.Errorf("failed to fill order %s: %w", order.ID, err)
+	}
+	for _, f := range fills {
+		t.settle(f.Order)
+		if f.Order.ID == order.ID {
+			order = f.Order
+		}
+	}
+	return order, nil
+}
+
+// closeAll is CloseAll for callers holding the lock
+func (t *Trader) closeAll(ctx context.Context) (Order, error) {
+	t.paused = true
+	for id := range t.pending {
+		if err := t.broker.CancelOrder(ctx, t.symbol, id); err != nil {
+			return Order{}, fmt.Errorf("failed to cancel order %s: %w", id, err)
+		}
+		delete(t.pending, id)
+	}
+
+	position, err := t.position(ctx)
+	if err != nil || position == nil {
+		return Order{}, err
+	}
+	return t.execute(ctx, strategy.Signal{Action: strategy.Exit, Time: t.last.Datetime, Price: t.last.Close, Reason: "closed by hand"})
+}
+
+// notify sends an event to the notifier, if there is one
+func (t *Trader) notify(e notifier.Event) {
+	if t.Notifier == nil {
+		return
+	}
+	if err := t.Notifier.Notify(context.Background(), e); err != nil {
+		log.Printf("Error sending notification: %v\n", err)
+	}
+}
+
+// entries returns the entry signals
+func entries(signals []strategy.Signal) []strategy.Signal {
+	var result []strategy.Signal
+	for _, sig := range signals {
+		if sig.Action == strategy.EnterLong || sig.Action == strategy.EnterShort {
+			result = append(result, sig)
+		}
+	}
+	return result
+}
+
+// equity returns the free balance plus the broker's positions valued at their entry prices
+func (t *Trader) equity(ctx context.Context, balance float64) (float64, error) {
+	positions, err := t.broker.Positions(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read positions: %w", err)
+	}
+	for _, p := range positions {
+		balance += p.Quantity * p.EntryPrice
+	}
+	return balance, nil
+}
+
+// position returns the broker's position in the symbol, nil if there is none
+func (t *Trader) position(ctx context.Context) (*Position, error) {
+	positions, err := t.broker.Positions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read positions: %w", err)
+	}
+	for _, p := range positions {
+		if p.Symbol == t.symbol && p.Quantity > 0 {
+			return &p, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,367 @@
+package execution
+
+import (
+	"context"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	notifier "learnGoLang/Notifier"
+	positionmanager "learnGoLang/PositionManager"
+	risk "learnGoLang/Risk"
+	strategy "learnGoLang/Strategy"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// scriptedStrategy signals the scripted action on the given bar numbers (counted from 1)
+type scriptedStrategy struct {
+	script map[int]strategy.Action
+	stop   float64 // Structural stop of the entries
+	bars   int
+	last   klinesfrombinance.Candle
+	fills  []strategy.Fill
+}
+
+func (s *scriptedStrategy) Name() string { return "scripted" }
+
+func (s *scriptedStrategy) OnCandle(c klinesfrombinance.Candle) {
+	s.bars++
+	s.last = c
+}
+
+func (s *scriptedStrategy) OnFill(f strategy.Fill) {
+	s.fills = append(s.fills, f)
+}
+
+func (s *scriptedStrategy) Signals() []strategy.Signal {
+	if action, ok := s.script[s.bars]; ok {
+		return []strategy.Signal{{Action: action, Time: s.last.Datetime, Price: s.last.Close, StopLoss: s.stop}}
+	}
+	return nil
+}
+
+// events records the notifications of a trader
+type events []notifier.Event
+
+func (e *events) Notify(ctx context.Context, event notifier.Event) error {
+	*e = append(*e, event)
+	return nil
+}
+
+// queriedBroker is a broker like the live one: it does not see the candles, buys wait
+// for a fill until the test sets one in orders, and sells fill right away
+type queriedBroker struct {
+	orders   map[string]Order // State reported by Order
+	position float64
+}
+
+func (b *queriedBroker) PlaceOrder(ctx context.Context, req OrderRequest) (Order, error) {
+	order := Order{ID: strconv.Itoa(len(b.orders) + 1), Symbol: req.Symbol, Side: req.Side, Type: req.Type, Quantity: req.Quantity, Status: StatusNew}
+	if req.Side == Sell {
+		order.Status, order.FillPrice, b.position = StatusFilled, 3000, 0
+	}
+	b.orders[order.ID] = order
+	return order, nil
+}
+
+func (b *queriedBroker) Order(ctx context.Context, symbol, orderID string) (Order, error) {
+	return b.orders[orderID], nil
+}
+
+func (b *queriedBroker) CancelOrder(ctx context.Context, symbol, orderID string) error { return nil }
+
+func (b *queriedBroker) OpenOrders(ctx context.Context, symbol string) ([]Order, error) {
+	return nil, nil
+}
+
+func (b *queriedBroker) Positions(ctx context.Context) ([]Position, error) {
+	if b.position == 0 {
+		return nil, nil
+	}
+	return []Position{{Symbol: "ETHUSDT", Quantity: b.position}}, nil
+}
+
+func (b *queriedBroker) Balance(ctx context.Context) (float64, error) { return 10000, nil }
+
+// candleAt builds the i-th 15m candle with open = close = price
+func candleAt(i int, price float64) klinesfrombinance.Candle {
+	ts := time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC).UnixMilli() + int64(i)*15*60*1000
+	return klinesfrombinance.NewCandle("ETHUSDT", ts, price, price+5, price-5, price, 1)
+}
+
+func TestTraderExecutesSignalsThroughPaperBroker(t *testing.T) {
+	ctx := context.Background()
+	b, _ := newTestBroker(t)
+	strat := &scriptedStrategy{script: map[int]strategy.Action{1: strategy.EnterLong, 2: strategy.Exit}}
+	// The strategy runs on 30m bars, so bar 1 closes with candle 1 and bar 2 with candle 3
+	trader, err := NewTrader(b, strat, "ETHUSDT", 15*time.Minute, 30*time.Minute, positionmanager.Config{}, risk.NewManager(risk.Config{}))
+	if err != nil {
+		t.Fatalf("NewTrader returned error: %v", err)
+	}
+	var notified events
+	trader.Notifier = &notified
+
+	var fills []Fill
+	for i, price := range []float64{3000, 3010, 3020, 3030, 3040} {
+		f, err := trader.OnCandle(ctx, candleAt(i, price))
+		if err != nil {
+			t.Fatalf("OnCandle %d returned error: %v", i, err)
+		}
+		fills = append(fills, f...)
+	}
+
+	if len(fills) != 2 || fills[0].Order.Side != Buy || fills[1].Order.Side != Sell {
+		t.Fatalf("got fills %+v, want a buy and a sell", fills)
+	}
+	if fills[0].Order.FillPrice != 3021 || fills[1].Order.FillPrice != 3039 {
+		t.Errorf("fill prices %f/%f, want the next opens 3021 and 3039 after slippage", fills[0].Order.FillPrice, fills[1].Order.FillPrice)
+	}
+	if strat.bars != 2 {
+		t.Errorf("strategy saw %d bars, want 2 closed 30m bars", strat.bars)
+	}
+	if len(strat.fills) != 2 || strat.fills[0].Action != strategy.EnterLong || strat.fills[1].Action != strategy.Exit {
+		t.Errorf("strategy was told about %+v", strat.fills)
+	}
+	if positions, _ := b.Positions(ctx); len(positions) != 0 {
+		t.Errorf("positions left open: %+v", positions)
+	}
+	if len(notified) != 2 || notified[0].Type != notifier.EventTradeOpened || notified[1].Type != notifier.EventTradeClosed {
+		t.Errorf("notified %+v, want the opened and the closed trade", notified)
+	}
+}
+
+func TestTraderSkipsShortsAndSyncsPositions(t *testing.T) {
+	ctx := context.Background()
+	b, _ := newTestBroker(t)
+	b.PlaceOrder(ctx, OrderRequest{Symbol: "ETHUSDT", Side: Buy, Type: Market, Quantity: 1})
+	b.OnCandle(candleAt(0, 3000))
+
+	strat := &scriptedStrategy{script: map[int]strategy.Action{2: strategy.EnterShort}}
+	trader, err := NewTrader(b, strat, "ETHUSDT", 15*time.Minute, 15*time.Minute, positionmanager.Config{}, risk.NewManager(risk.Config{}))
+	if err != nil {
+		t.Fatalf("NewTrader returned error: %v", err)
+	}
+	if err := trader.Warmup(ctx, []klinesfrombinance.Candle{candleAt(0, 3000)}); err != nil {
+		t.Fatalf("Warmup returned error: %v", err)
+	}
+	if len(strat.fills) != 1 || strat.fills[0].Action != strategy.EnterLong || strat.fills[0].Quantity != 1 {
+		t.Fatalf("strategy was not told about the open position: %+v", strat.fills)
+	}
+
+	for i := 1; i < 4; i++ {
+		if _, err := trader.OnCandle(ctx, candleAt(i, 3000)); err != nil {
+			t.Fatalf("OnCandle returned error: %v", err)
+		}
+	}
+	if orders, _ := b.OpenOrders(ctx, ""); len(orders) != 0 {
+		t.Errorf("short signal placed orders: %+v", orders)
+	}
+}
+
+func TestTraderClosesPositionsLikeTheBacktest(t *testing.T) {
+	ctx := context.Background()
+	b, _ := newTestBroker(t)
+	b.PlaceOrder(ctx, OrderRequest{Symbol: "ETHUSDT", Side: Buy, Type: Market, Quantity: 1})
+	b.OnCandle(candleAt(0, 3000))
+
+	strat := &scriptedStrategy{}
+	trader, err := NewTrader(b, strat, "ETHUSDT", 15*time.Minute, 15*time.Minute, positionmanager.Config{TrailingStopPct: 1}, risk.NewManager(risk.Config{}))
+	if err != nil {
+		t.Fatalf("NewTrader returned error: %v", err)
+	}
+	if err := trader.Warmup(ctx, []klinesfrombinance.Candle{candleAt(0, 3000)}); err != nil {
+		t.Fatalf("Warmup returned error: %v", err)
+	}
+
+	// The high of 3105 moves the trailing stop to 3073.95, which the low of 3065 crosses
+	var fills []Fill
+	for i, price := range []float64{3100, 3070, 3080} {
+		f, err := trader.OnCandle(ctx, candleAt(i+1, price))
+		if err != nil {
+			t.Fatalf("OnCandle returned error: %v", err)
+		}
+		fills = append(fills, f...)
+	}
+	if len(fills) != 1 || fills[0].Order.Side != Sell {
+		t.Fatalf("got fills %+v, want the sell of the trailing stop", fills)
+	}
+	if len(strat.fills) != 2 || strat.fills[1].Action != strategy.Exit {
+		t.Errorf("strategy was told about %+v", strat.fills)
+	}
+}
+
+func TestTraderRefusesWideStops(t *testing.T) {
+	ctx := context.Background()
+	b, _ := newTestBroker(t)
+	// The structural stop 150 below the close of 3000 is 5% away
+	strat := &scriptedStrategy{script: map[int]strategy.Action{1: strategy.EnterLong}, stop: 2850}
+	trader, err := NewTrader(b, strat, "ETHUSDT", 15*time.Minute, 15*time.Minute, positionmanager.Config{MaxAllowedSLPct: 3}, risk.NewManager(risk.Config{}))
+	if err != nil {
+		t.Fatalf("NewTrader returned error: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := trader.OnCandle(ctx, candleAt(i, 3000)); err != nil {
+			t.Fatalf("OnCandle returned error: %v", err)
+		}
+	}
+	if positions, _ := b.Positions(ctx); len(positions) != 0 || len(strat.fills) != 0 {
+		t.Errorf("entry with a 5%% stop was not refused: positions %+v, fills %+v", positions, strat.fills)
+	}
+}
+
+func TestTraderSizesEntriesWithTheRiskManager(t *testing.T) {
+	ctx := context.Background()
+	b, _ := newTestBroker(t)
+	strat := &scriptedStrategy{script: map[int]strategy.Action{1: strategy.EnterLong, 2: strategy.Exit, 3: strategy.EnterLong}}
+	sizing := risk.NewManager(risk.Config{Sizing: risk.RiskPerTrade, RiskPct: 1, DailyLossLimitPct: 1})
+	trader, err := NewTrader(b, strat, "ETHUSDT", 15*time.Minute, 15*time.Minute, positionmanager.Config{StopLossPct: 2}, sizing)
+	if err != nil {
+		t.Fatalf("NewTrader returned error: %v", err)
+	}
+
+	var fills []Fill
+	for i, price := range []float64{3000, 3000, 2900, 2900, 2900} {
+		f, err := trader.OnCandle(ctx, candleAt(i, price))
+		if err != nil {
+			t.Fatalf("OnCandle %d returned error: %v", i, err)
+		}
+		fills = append(fills, f...)
+	}
+
+	// Risking 1% of 10000 with a stop 60 below 3000 buys 100/60
+	if len(fills) != 2 || fills[0].Order.Side != Buy {
+		t.Fatalf("got fills %+v, want a buy and a sell", fills)
+	}
+	if got := fills[0].Order.Quantity; got < 1.6666 || got > 1.6667 {
+		t.Errorf("bought %f, want 1.6667", got)
+	}
+	// The exit lost more than 1% of the equity, the breaker refuses the second entry
+	if orders, _ := b.OpenOrders(ctx, ""); len(orders) != 0 || len(strat.fills) != 2 {
+		t.Errorf("entry after the daily loss limit was placed: orders %+v, fills %+v", orders, strat.fills)
+	}
+}
+
+func TestTraderPauseAndCloseAll(t *testing.T) {
+	ctx := context.Background()
+	b, _ := newTestBroker(t)
+	strat := &scriptedStrategy{script: map[int]strategy.Action{1: strategy.EnterLong, 4: strategy.EnterLong}}
+	trader, err := NewTrader(b, strat, "ETHUSDT", 15*time.Minute, 15*time.Minute, positionmanager.Config{}, risk.NewManager(risk.Config{}))
+	if err != nil {
+		t.Fatalf("NewTrader returned error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		trader.OnCandle(ctx, candleAt(i, 3000))
+	}
+	status, err := trader.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status returned error: %v", err)
+	}
+	if len(status.Positions) != 1 || status.UnrealizedPnL >= 0 || status.LastPrice != 3000 {
+		t.Fatalf("status %+v, want an open position just below its entry after slippage", status)
+	}
+
+	order, err := trader.CloseAll(ctx)
+	if err != nil || order.Side != Sell {
+		t.Fatalf("CloseAll placed %+v, %v, want a sell order", order, err)
+	}
+	// The sell fills at the next open and the paused trader skips the entry of bar 4
+	for i := 2; i < 5; i++ {
+		trader.OnCandle(ctx, candleAt(i, 3000))
+	}
+	status, _ = trader.Status(ctx)
+	if !status.Paused || len(status.Positions) != 0 || status.RealizedPnL >= 0 {
+		t.Errorf("status %+v, want paused and flat after a loss to fees", status)
+	}
+	trades := trader.Trades(candleAt(0, 3000).Datetime)
+	if len(trades) != 1 || trades[0].Reason != "closed by hand" || trades[0].Profit != status.RealizedPnL || trades[0].Fees <= 0 || trades[0].EntryTime.IsZero() {
+		t.Errorf("trades %+v, want the round trip closed by hand", trades)
+	}
+	if later := trader.Trades(candleAt(10, 3000).Datetime); len(later) != 0 {
+		t.Errorf("Trades returned %d trades closed before since", len(later))
+	}
+
+	trader.Resume()
+	strat.script[6] = strategy.EnterLong
+	trader.OnCandle(ctx, candleAt(5, 3000))
+	trader.OnCandle(ctx, candleAt(6, 3000))
+	if positions, _ := b.Positions(ctx); len(positions) != 1 {
+		t.Errorf("resumed trader did not enter: %+v", positions)
+	}
+}
+
+func TestTraderClosesPaperPositionOnShutdown(t *testing.T) {
+	ctx := context.Background()
+	b, path := newTestBroker(t)
+	strat := &scriptedStrategy{script: map[int]strategy.Action{1: strategy.EnterLong}}
+	trader, err := NewTrader(b, strat, "ETHUSDT", 15*time.Minute, 15*time.Minute, positionmanager.Config{}, risk.NewManager(risk.Config{}))
+	if err != nil {
+		t.Fatalf("NewTrader returned error: %v", err)
+	}
+	for i, price := range []float64{3000, 3000, 3050} {
+		trader.OnCandle(ctx, candleAt(i, price))
+	}
+
+	// No next candle comes, the sell fills at the last close
+	order, err := trader.CloseNow(ctx)
+	if err != nil || order.Status != StatusFilled || order.FillPrice != 3049 || !order.FilledAt.Equal(candleAt(3, 0).Datetime) {
+		t.Fatalf("CloseNow returned %+v, %v, want a sell filled at the last close after slippage", order, err)
+	}
+	if trades := trader.Trades(time.Time{}); len(trades) != 1 || trades[0].ExitPrice != 3049 {
+		t.Errorf("trades %+v, want the round trip closed on shutdown", trades)
+	}
+
+	// After a restart there is neither a position nor an order left to fill
+	restarted, err := NewPaperBroker(path, 10000, 0.1, 1)
+	if err != nil {
+		t.Fatalf("NewPaperBroker returned error: %v", err)
+	}
+	strat = &scriptedStrategy{}
+	trader, _ = NewTrader(restarted, strat, "ETHUSDT", 15*time.Minute, 15*time.Minute, positionmanager.Config{}, risk.NewManager(risk.Config{}))
+	if err := trader.Warmup(ctx, []klinesfrombinance.Candle{candleAt(3, 3050)}); err != nil {
+		t.Fatalf("Warmup returned error: %v", err)
+	}
+	positions, _ := restarted.Positions(ctx)
+	orders, _ := restarted.OpenOrders(ctx, "")
+	if len(positions) != 0 || len(orders) != 0 || len(strat.fills) != 0 {
+		t.Errorf("restarted with positions %+v, orders %+v and strategy fills %+v, want none", positions, orders, strat.fills)
+	}
+	if fills, _ := trader.OnCandle(ctx, candleAt(4, 3060)); len(fills) != 0 {
+		t.Errorf("a stale sell filled after the restart: %+v", fills)
+	}
+}
+
+func TestTraderPollsOrdersWaitingForAFill(t *testing.T) {
+	ctx := context.Background()
+	b := &queriedBroker{orders: make(map[string]Order)}
+	strat := &scriptedStrategy{script: map[int]strategy.Action{1: strategy.EnterLong, 2: strategy.Exit, 3: strategy.Exit}}
+	trader, err := NewTrader(b, strat, "ETHUSDT", 15*time.Minute, 15*time.Minute, positionmanager.Config{}, risk.NewManager(risk.Config{}))
+	if err != nil {
+		t.Fatalf("NewTrader returned error: %v", err)
+	}
+
+	// The buy is still open on bar 2, so the exit of bar 2 is skipped
+	for i := 0; i < 2; i++ {
+		if fills, err := trader.OnCandle(ctx, candleAt(i, 3000)); err != nil || len(fills) != 0 {
+			t.Fatalf("OnCandle %d returned %+v, %v, want no fills", i, fills, err)
+		}
+	}
+	if status, _ := trader.Status(ctx); status.PendingOrders != 1 {
+		t.Fatalf("%d pending orders, want the buy", status.PendingOrders)
+	}
+
+	buy := b.orders["1"]
+	buy.Status, buy.FillPrice, buy.FilledAt = StatusFilled, 3000, candleAt(2, 0).Datetime
+	b.orders["1"], b.position = buy, buy.Quantity
+	fills, err := trader.OnCandle(ctx, candleAt(2, 3000))
+	if err != nil {
+		t.Fatalf("OnCandle returned error: %v", err)
+	}
+	if len(fills) != 2 || fills[0].Order.Side != Buy || fills[1].Order.Side != Sell {
+		t.Fatalf("got fills %+v, want the polled buy and the sell of bar 3", fills)
+	}
+	if len(strat.fills) != 2 || strat.fills[0].Action != strategy.EnterLong || strat.fills[1].Action != strategy.Exit {
+		t.Errorf("strategy was told about %+v", strat.fills)
+	}
+	if status, _ := trader.Status(ctx); status.PendingOrders != 0 || len(trader.Trades(time.Time{})) != 1 {
+		t.Errorf("%d pending orders and trades %+v, want the round trip closed", status.PendingOrders, trader.Trades(time.Time{}))
+	}
+}
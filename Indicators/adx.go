@@ -0,0 +1,146 @@
+package indicators
+
+import (
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"math"
+)
+
+// ADXSeries holds the average directional index and the directional indicators for every candle
+type ADXSeries struct {
+	ADX     []float64
+	PlusDI  []float64
+	MinusDI []float64
+}
+
+// ADXValue is the average directional index of one candle
+type ADXValue struct {
+	ADX     float64
+	PlusDI  float64
+	MinusDI float64
+}
+
+// ADX calculates Wilder's average directional index. The true range and directional
+// movements of candles 1..length are summed and then carried forward with Wilder's
+// smoothing, which gives +DI and -DI from index length. ADX is the average of the first
+// length DX values, at index 2*length-1, and Wilder's smoothing of DX after that.
+func ADX(candles []klinesfrombinance.Candle, length int) ADXSeries {
+	checkLength("ADX", length)
+	out := ADXSeries{ADX: nanSeries(len(candles)), PlusDI: nanSeries(len(candles)), MinusDI: nanSeries(len(candles))}
+	var sumTR, sumPlus, sumMinus, adx float64
+	for i := 1; i < len(candles); i++ {
+		tr := trueRange(candles[i], candles[i-1].Close)
+		plusDM, minusDM := directionalMovement(candles[i-1], candles[i])
+		if i <= length {
+			sumTR += tr
+			sumPlus += plusDM
+			sumMinus += minusDM
+			if i < length {
+				continue
+			}
+		} else {
+			sumTR = sumTR - sumTR/float64(length) + tr
+			sumPlus = sumPlus - sumPlus/float64(length) + plusDM
+			sumMinus = sumMinus - sumMinus/float64(length) + minusDM
+		}
+
+		plusDI, minusDI, dx := directionalIndex(sumTR, sumPlus, sumMinus)
+		out.PlusDI[i], out.MinusDI[i] = plusDI, minusDI
+		switch n := i - length + 1; { // Number of DX values so far
+		case n < length:
+			adx += dx
+		case n == length:
+			adx = (adx + dx) / float64(length)
+			out.ADX[i] = adx
+		default:
+			adx = wilder(adx, dx, length)
+			out.ADX[i] = adx
+		}
+	}
+	return out
+}
+
+// directionalMovement returns +DM and -DM of next. Only the larger of the up and down
+// moves counts, and only if it is positive.
+func directionalMovement(prev, next klinesfrombinance.Candle) (plusDM, minusDM float64) {
+	up := next.High - prev.High
+	down := prev.Low - next.Low
+	if up > down && up > 0 {
+		plusDM = up
+	}
+	if down > up && down > 0 {
+		minusDM = down
+	}
+	return plusDM, minusDM
+}
+
+// directionalIndex converts the smoothed sums to +DI, -DI and DX
+func directionalIndex(sumTR, sumPlus, sumMinus float64) (plusDI, minusDI, dx float64) {
+	if sumTR == 0 {
+		return 0, 0, 0
+	}
+	plusDI = 100 * sumPlus / sumTR
+	minusDI = 100 * sumMinus / sumTR
+	if plusDI+minusDI == 0 {
+		return plusDI, minusDI, 0
+	}
+	return plusDI, minusDI, 100 * math.Abs(plusDI-minusDI) / (plusDI + minusDI)
+}
+
+// ADXStream is the streaming form of ADX
+type ADXStream struct {
+	length   int
+	moves    int
+	prev     klinesfrombinance.Candle
+	started  bool
+	sumTR    float64
+	sumPlus  float64
+	sumMinus float64
+	adx      float64
+}
+
+// NewADXStream creates a streaming average directional index
+func NewADXStream(length int) *ADXStream {
+	checkLength("ADX", length)
+	return &ADXStream{length: length}
+}
+
+// Update adds the next candle and returns the ADX including it
+func (s *ADXStream) Update(c klinesfrombinance.Candle) ADXValue {
+	prev := s.prev
+	s.prev = c
+	none := ADXValue{ADX: math.NaN(), PlusDI: math.NaN(), MinusDI: math.NaN()}
+	if !s.started {
+		s.started = true
+		return none
+	}
+
+	tr := trueRange(c, prev.Close)
+	plusDM, minusDM := directionalMovement(prev, c)
+	s.moves++
+	if s.moves <= s.length {
+		s.sumTR += tr
+		s.sumPlus += plusDM
+		s.sumMinus += minusDM
+		if s.moves < s.length {
+			return none
+		}
+	} else {
+		s.sumTR = s.sumTR - s.sumTR/float64(s.length) + tr
+		s.sumPlus = s.sumPlus - s.sumPlus/float64(s.length) + plusDM
+		s.sumMinus = s.sumMinus - s.sumMinus/float64(s.length) + minusDM
+	}
+
+	plusDI, minusDI, dx := directionalIndex(s.sumTR, s.sumPlus, s.sumMinus)
+	value := ADXValue{ADX: math.NaN(), PlusDI: plusDI, MinusDI: minusDI}
+	switch n := s.moves - s.length + 1; {
+	case n < s.length:
+		s.adx += dx
+	case n == s.length:
+		s.adx = (s.adx + dx) / float64(s.length)
+		value.ADX = s.adx
+	default:
+		s.adx = wilder(s.adx, dx, s.length)
+		value.ADX = s.adx
+	}
+	return value
+}
@@ -0,0 +1,86 @@
+package indicators
+
+import (
+	"fmt"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"math"
+)
+
+// Every indicator comes in two forms: a batch function that returns one value per candle,
+// and a streaming type whose Update takes the candles one at a time and returns the value
+// for that candle. Both forms produce the same numbers. NaN marks the bars before an
+// indicator has seen enough history.
+
+// closes returns the close prices of the candles
+func closes(candles []klinesfrombinance.Candle) []float64 {
+	out := make([]float64, len(candles))
+	for i, c := range candles {
+		out[i] = c.Close
+	}
+	return out
+}
+
+// nanSeries returns n NaN values
+func nanSeries(n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	return out
+}
+
+// checkLength panics on a length that no indicator can be calculated with
+func checkLength(name string, length int) {
+	if length <= 0 {
+		panic(fmt.Sprintf("indicators: %s length must be positive, got %d", name, length))
+	}
+}
+
+// firstValid returns the index of the first value that is not NaN
+func firstValid(values []float64) int {
+	for i, v := range values {
+		if !math.IsNaN(v) {
+			return i
+		}
+	}
+	return len(values)
+}
+
+// window keeps the last values pushed into it, oldest first
+type window struct {
+	values []float64
+	count  int
+}
+
+func newWindow(length int) *window {
+	return &window{values: make([]float64, length)}
+}
+
+// push adds a value and returns the one that fell out of a full window
+func (w *window) push(v float64) (dropped float64, wasFull bool) {
+	i := w.count % len(w.values)
+	dropped, wasFull = w.values[i], w.full()
+	w.values[i] = v
+	w.count++
+	return dropped, wasFull
+}
+
+// full reports whether the window holds length values
+func (w *window) full() bool {
+	return w.count >= len(w.values)
+}
+
+// at returns the j-th oldest value of a full window
+func (w *window) at(j int) float64 {
+	return w.values[(w.count+j)%len(w.values)]
+}
+
+// wilder applies Wilder's smoothing, an EMA with alpha 1/length
+func wilder(prev, v float64, length int) float64 {
+	return (prev*float64(length-1) + v) / float64(length)
+}
+
+// trueRange is the range of a candle extended to the previous close
+func trueRange(c klinesfrombinance.Candle, prevClose float64) float64 {
+	return math.Max(c.High-c.Low, math.Max(math.Abs(c.High-prevClose), math.Abs(c.Low-prevClose)))
+}
@@ -0,0 +1,199 @@
+package indicators
+
+import (
+	"encoding/csv"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"math"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// loadHistory reads the ETHUSDC 15m history shipped in data/
+func loadHistory(t *testing.T) []klinesfrombinance.Candle {
+	t.Helper()
+	file, err := os.Open("../data/ETHUSDC_15m.csv")
+	if err != nil {
+		t.Fatalf("failed to open history: %v", err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read history: %v", err)
+	}
+
+	candles := make([]klinesfrombinance.Candle, 0, len(records)-1)
+	for _, r := range records[1:] {
+		var v [6]float64
+		for i, field := range []string{r[1], r[5], r[6], r[7], r[8], r[9]} {
+			if v[i], err = strconv.ParseFloat(field, 64); err != nil {
+				t.Fatalf("bad field %q: %v", field, err)
+			}
+		}
+		candles = append(candles, klinesfrombinance.NewCandle(r[0], int64(v[0]), v[1], v[2], v[3], v[4], v[5]))
+	}
+	return candles
+}
+
+// streamed feeds the candles one by one into update
+func streamed[T any](candles []klinesfrombinance.Candle, update func(klinesfrombinance.Candle) T) []T {
+	out := make([]T, len(candles))
+	for i, c := range candles {
+		out[i] = update(c)
+	}
+	return out
+}
+
+// pick extracts one field of every streamed value
+func pick[T any](values []T, field func(T) float64) []float64 {
+	out := make([]float64, len(values))
+	for i, v := range values {
+		out[i] = field(v)
+	}
+	return out
+}
+
+// history is every indicator calculated in both forms, computed once for all tests
+type history struct {
+	batch  map[string][]float64
+	stream map[string][]float64
+}
+
+func calculate(candles []klinesfrombinance.Candle) history {
+	macd := MACD(candles, 12, 26, 9)
+	bands := BollingerBands(candles, 20, 2)
+	stoch := Stochastic(candles, 14, 3, 3)
+	adx := ADX(candles, 14)
+	batch := map[string][]float64{
+		"SMA20": SMA(candles, 20), "EMA20": EMA(candles, 20), "WMA20": WMA(candles, 20),
+		"MACD": macd.MACD, "MACDSignal": macd.Signal, "MACDHistogram": macd.Histogram,
+		"RSI14": RSI(candles, 14), "ATR14": ATR(candles, 14),
+		"BBUpper": bands.Upper, "BBMiddle": bands.Middle, "BBLower": bands.Lower,
+		"StochK": stoch.K, "StochD": stoch.D,
+		"ADX14": adx.ADX, "PlusDI": adx.PlusDI, "MinusDI": adx.MinusDI,
+		"VWAP": VWAP(candles), "OBV": OBV(candles),
+	}
+
+	macdStream := streamed(candles, NewMACDStream(12, 26, 9).Update)
+	bandsStream := streamed(candles, NewBollingerStream(20, 2).Update)
+	stochStream := streamed(candles, NewStochasticStream(14, 3, 3).Update)
+	adxStream := streamed(candles, NewADXStream(14).Update)
+	stream := map[string][]float64{
+		"SMA20":         streamed(candles, NewSMAStream(20).Update),
+		"EMA20":         streamed(candles, NewEMAStream(20).Update),
+		"WMA20":         streamed(candles, NewWMAStream(20).Update),
+		"MACD":          pick(macdStream, func(v MACDValue) float64 { return v.MACD }),
+		"MACDSignal":    pick(macdStream, func(v MACDValue) float64 { return v.Signal }),
+		"MACDHistogram": pick(macdStream, func(v MACDValue) float64 { return v.Histogram }),
+		"RSI14":         streamed(candles, NewRSIStream(14).Update),
+		"ATR14":         streamed(candles, NewATRStream(14).Update),
+		"BBUpper":       pick(bandsStream, func(v BandsValue) float64 { return v.Upper }),
+		"BBMiddle":      pick(bandsStream, func(v BandsValue) float64 { return v.Middle }),
+		"BBLower":       pick(bandsStream, func(v BandsValue) float64 { return v.Lower }),
+		"StochK":        pick(stochStream, func(v StochasticValue) float64 { return v.K }),
+		"StochD":        pick(stochStream, func(v StochasticValue) float64 { return v.D }),
+		"ADX14":         pick(adxStream, func(v ADXValue) float64 { return v.ADX }),
+		"PlusDI":        pick(adxStream, func(v ADXValue) float64 { return v.PlusDI }),
+		"MinusDI":       pick(adxStream, func(v ADXValue) float64 { return v.MinusDI }),
+		"VWAP":          streamed(candles, NewVWAPStream().Update),
+		"OBV":           streamed(candles, NewOBVStream().Update),
+	}
+	return history{batch: batch, stream: stream}
+}
+
+func TestStreamingMatchesBatch(t *testing.T) {
+	candles := loadHistory(t)
+	h := calculate(candles)
+	for name, batch := range h.batch {
+		stream := h.stream[name]
+		if len(batch) != len(candles) || len(stream) != len(candles) {
+			t.Errorf("%s: %d batch and %d streamed values for %d candles", name, len(batch), len(stream), len(candles))
+			continue
+		}
+		for i := range batch {
+			if batch[i] != stream[i] && !(math.IsNaN(batch[i]) && math.IsNaN(stream[i])) {
+				t.Errorf("%s[%d]: batch %v, streamed %v", name, i, batch[i], stream[i])
+				break
+			}
+		}
+	}
+}
+
+// The reference values were calculated from data/ETHUSDC_15m.csv with a separate,
+// non-incremental implementation of the same definitions, rounded to 6 decimals.
+func TestReferenceValues(t *testing.T) {
+	candles := loadHistory(t)
+	if len(candles) != 19717 {
+		t.Fatalf("history has %d candles, the reference values are for 19717", len(candles))
+	}
+	h := calculate(candles)
+
+	reference := map[string][3]float64{ // Values at index 100, 10000 and 19716
+		"SMA20":      {3483.665, 1804.647, 4218.152},
+		"EMA20":      {3473.520379, 1805.180365, 4218.9385},
+		"WMA20":      {3478.950857, 1807.750524, 4211.231095},
+		"MACD":       {7.679698, 4.461986, -11.003705},
+		"MACDSignal": {10.362489, 5.098973, -13.227951},
+		"RSI14":      {51.014586, 55.158323, 48.455963},
+		"ATR14":      {14.195252, 6.354554, 19.313081},
+		"BBUpper":    {3510.728857, 1818.607969, 4256.982072},
+		"BBLower":    {3456.601143, 1790.686031, 4179.321928},
+		"StochK":     {44.651074, 50.454305, 72.849558},
+		"StochD":     {46.234361, 60.062759, 64.740874},
+		"ADX14":      {23.348471, 26.569978, 26.492539},
+		"PlusDI":     {26.573366, 21.83642, 17.470613},
+		"MinusDI":    {21.431037, 16.30419, 27.51279},
+		"VWAP":       {3478.019302, 1807.59781, 4239.374408},
+		"OBV":        {91222.8369, -3110033.1291, -748291.1044},
+	}
+	for name, want := range reference {
+		for i, index := range []int{100, 10000, 19716} {
+			if got := h.batch[name][index]; math.Abs(got-want[i]) > 1e-6 {
+				t.Errorf("%s[%d] = %.6f, want %.6f", name, index, got, want[i])
+			}
+		}
+	}
+
+	warmUp := map[string]int{ // Index of the first value that is not NaN
+		"SMA20": 19, "EMA20": 19, "WMA20": 19, "MACD": 25, "MACDSignal": 33, "RSI14": 14, "ATR14": 14,
+		"BBUpper": 19, "StochK": 15, "StochD": 17, "ADX14": 27, "PlusDI": 14, "VWAP": 0, "OBV": 0,
+	}
+	for name, want := range warmUp {
+		if got := firstValid(h.batch[name]); got != want {
+			t.Errorf("%s: first value at %d, want %d", name, got, want)
+		}
+	}
+}
+
+func TestEMASeededWithSMA(t *testing.T) {
+	out := ema([]float64{math.NaN(), 1, 2, 3, 4}, 3)
+	if !math.IsNaN(out[1]) || !math.IsNaN(out[2]) {
+		t.Fatalf("expected NaN before the seed, got %v", out[:3])
+	}
+	if out[3] != 2 {
+		t.Errorf("seed = %f, want 2", out[3])
+	}
+	if out[4] != 3 { // 0.5*4 + 0.5*2
+		t.Errorf("ema[4] = %f, want 3", out[4])
+	}
+}
+
+func TestFlatPrices(t *testing.T) {
+	candles := make([]klinesfrombinance.Candle, 40)
+	for i := range candles {
+		candles[i] = klinesfrombinance.NewCandle("ETHUSDT", int64(i)*15*60*1000, 100, 100, 100, 100, 0)
+	}
+	last := len(candles) - 1
+	if got := RSI(candles, 14)[last]; got != 50 {
+		t.Errorf("RSI of flat prices = %v, want 50", got)
+	}
+	if got := Stochastic(candles, 14, 3, 3).K[last]; got != 50 {
+		t.Errorf("%%K of flat prices = %v, want 50", got)
+	}
+	if got := ADX(candles, 14).ADX[last]; got != 0 {
+		t.Errorf("ADX of flat prices = %v, want 0", got)
+	}
+	if got := VWAP(candles)[last]; !math.IsNaN(got) {
+		t.Errorf("VWAP without volume = %v, want NaN", got)
+	}
+}
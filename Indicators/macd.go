@@ -0,0 +1,60 @@
+package indicators
+
+import klinesfrombinance "learnGoLang/KlinesFromBinanace"
+
+// MACDSeries holds the MACD line, its signal line and their difference for every candle
+type MACDSeries struct {
+	MACD      []float64
+	Signal    []float64
+	Histogram []float64
+}
+
+// MACDValue is the MACD of one candle
+type MACDValue struct {
+	MACD      float64
+	Signal    float64
+	Histogram float64
+}
+
+// MACD calculates the difference of the fast and slow EMA of the closes and the EMA of
+// that difference. The MACD line is NaN until the slow EMA is seeded, the signal and
+// histogram until signalLength MACD values are available.
+func MACD(candles []klinesfrombinance.Candle, fast, slow, signalLength int) MACDSeries {
+	checkLength("MACD fast", fast)
+	checkLength("MACD slow", slow)
+	checkLength("MACD signal", signalLength)
+
+	prices := closes(candles)
+	fastEMA := ema(prices, fast)
+	slowEMA := ema(prices, slow)
+	macd := make([]float64, len(candles))
+	for i := range macd {
+		macd[i] = fastEMA[i] - slowEMA[i]
+	}
+	signal := ema(macd, signalLength)
+	histogram := make([]float64, len(candles))
+	for i := range histogram {
+		histogram[i] = macd[i] - signal[i]
+	}
+	return MACDSeries{MACD: macd, Signal: signal, Histogram: histogram}
+}
+
+// MACDStream is the streaming form of MACD
+type MACDStream struct {
+	fast, slow, signal *emaState
+}
+
+// NewMACDStream creates a streaming MACD
+func NewMACDStream(fast, slow, signalLength int) *MACDStream {
+	checkLength("MACD fast", fast)
+	checkLength("MACD slow", slow)
+	checkLength("MACD signal", signalLength)
+	return &MACDStream{fast: newEMAState(fast), slow: newEMAState(slow), signal: newEMAState(signalLength)}
+}
+
+// Update adds the next candle and returns the MACD including it
+func (s *MACDStream) Update(c klinesfrombinance.Candle) MACDValue {
+	macd := s.fast.add(c.Close) - s.slow.add(c.Close)
+	signal := s.signal.add(macd)
+	return MACDValue{MACD: macd, Signal: signal, Histogram: macd - signal}
+}
@@ -0,0 +1,193 @@
+package indicators
+
+import (
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"math"
+)
+
+// SMA calculates the simple moving average of the close prices
+func SMA(candles []klinesfrombinance.Candle, length int) []float64 {
+	checkLength("SMA", length)
+	return sma(closes(candles), length)
+}
+
+// sma averages the last length values. Leading NaN inputs are skipped.
+func sma(values []float64, length int) []float64 {
+	out := nanSeries(len(values))
+	start := firstValid(values)
+	sum := 0.0
+	for i := start; i < len(values); i++ {
+		sum += values[i]
+		if i-start >= length {
+			sum -= values[i-length]
+		}
+		if i-start >= length-1 {
+			out[i] = sum / float64(length)
+		}
+	}
+	return out
+}
+
+// smaState is the incremental form of sma
+type smaState struct {
+	length int
+	window *window
+	sum    float64
+}
+
+func newSMAState(length int) *smaState {
+	return &smaState{length: length, window: newWindow(length)}
+}
+
+func (s *smaState) add(v float64) float64 {
+	if math.IsNaN(v) {
+		return math.NaN()
+	}
+	s.sum += v
+	if dropped, wasFull := s.window.push(v); wasFull {
+		s.sum -= dropped
+	}
+	if !s.window.full() {
+		return math.NaN()
+	}
+	return s.sum / float64(s.length)
+}
+
+// SMAStream is the streaming form of SMA
+type SMAStream struct {
+	sma *smaState
+}
+
+// NewSMAStream creates a streaming simple moving average
+func NewSMAStream(length int) *SMAStream {
+	checkLength("SMA", length)
+	return &SMAStream{sma: newSMAState(length)}
+}
+
+// Update adds the next candle and returns the average including it
+func (s *SMAStream) Update(c klinesfrombinance.Candle) float64 {
+	return s.sma.add(c.Close)
+}
+
+// EMA calculates the exponential moving average of the close prices, seeded with the
+// simple average of the first length closes
+func EMA(candles []klinesfrombinance.Candle, length int) []float64 {
+	checkLength("EMA", length)
+	return ema(closes(candles), length)
+}
+
+// ema calculates an exponential moving average seeded with the simple average of the
+// first length valid values. Leading NaN inputs are skipped.
+func ema(values []float64, length int) []float64 {
+	out := nanSeries(len(values))
+	alpha := 2 / float64(length+1)
+	sum, count, prev := 0.0, 0, 0.0
+	for i, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		if count < length {
+			sum += v
+			count++
+			if count == length {
+				prev = sum / float64(length)
+				out[i] = prev
+			}
+			continue
+		}
+		prev = alpha*v + (1-alpha)*prev
+		out[i] = prev
+	}
+	return out
+}
+
+// emaState is the incremental form of ema
+type emaState struct {
+	length int
+	alpha  float64
+	count  int
+	sum    float64
+	value  float64
+}
+
+func newEMAState(length int) *emaState {
+	return &emaState{length: length, alpha: 2 / float64(length+1)}
+}
+
+func (e *emaState) add(v float64) float64 {
+	if math.IsNaN(v) {
+		return math.NaN()
+	}
+	if e.count < e.length {
+		e.sum += v
+		e.count++
+		if e.count < e.length {
+			return math.NaN()
+		}
+		e.value = e.sum / float64(e.length)
+		return e.value
+	}
+	e.value = e.alpha*v + (1-e.alpha)*e.value
+	return e.value
+}
+
+// EMAStream is the streaming form of EMA
+type EMAStream struct {
+	ema *emaState
+}
+
+// NewEMAStream creates a streaming exponential moving average
+func NewEMAStream(length int) *EMAStream {
+	checkLength("EMA", length)
+	return &EMAStream{ema: newEMAState(length)}
+}
+
+// Update adds the next candle and returns the average including it
+func (s *EMAStream) Update(c klinesfrombinance.Candle) float64 {
+	return s.ema.add(c.Close)
+}
+
+// WMA calculates the linearly weighted moving average of the close prices. The newest
+// close has weight length, the oldest weight 1.
+func WMA(candles []klinesfrombinance.Candle, length int) []float64 {
+	checkLength("WMA", length)
+	out := nanSeries(len(candles))
+	for i := length - 1; i < len(candles); i++ {
+		sum := 0.0
+		for j := 0; j < length; j++ {
+			sum += float64(j+1) * candles[i-length+1+j].Close
+		}
+		out[i] = sum / wmaDivisor(length)
+	}
+	return out
+}
+
+// wmaDivisor is the sum of the weights 1..length
+func wmaDivisor(length int) float64 {
+	return float64(length*(length+1)) / 2
+}
+
+// WMAStream is the streaming form of WMA
+type WMAStream struct {
+	length int
+	window *window
+}
+
+// NewWMAStream creates a streaming weighted moving average
+func NewWMAStream(length int) *WMAStream {
+	checkLength("WMA", length)
+	return &WMAStream{length: length, window: newWindow(length)}
+}
+
+// Update adds the next candle and returns the average including it
+func (s *WMAStream) Update(c klinesfrombinance.Candle) float64 {
+	s.window.push(c.Close)
+	if !s.window.full() {
+		return math.NaN()
+	}
+	sum := 0.0
+	for j := 0; j < s.length; j++ {
+		sum += float64(j+1) * s.window.at(j)
+	}
+	return sum / wmaDivisor(s.length)
+}
@@ -0,0 +1,171 @@
+package indicators
+
+import (
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"math"
+)
+
+// RSI calculates Wilder's relative strength index of the closes. The first average gain
+// and loss are the simple averages of the first length price changes, later ones use
+// Wilder's smoothing. The first value is at index length.
+func RSI(candles []klinesfrombinance.Candle, length int) []float64 {
+	checkLength("RSI", length)
+	out := nanSeries(len(candles))
+	avgGain, avgLoss := 0.0, 0.0
+	for i := 1; i < len(candles); i++ {
+		gain, loss := priceChange(candles[i-1].Close, candles[i].Close)
+		if i <= length {
+			avgGain += gain
+			avgLoss += loss
+			if i < length {
+				continue
+			}
+			avgGain /= float64(length)
+			avgLoss /= float64(length)
+		} else {
+			avgGain = wilder(avgGain, gain, length)
+			avgLoss = wilder(avgLoss, loss, length)
+		}
+		out[i] = rsi(avgGain, avgLoss)
+	}
+	return out
+}
+
+// priceChange splits the move from prev to next into a gain and a loss, one of them 0
+func priceChange(prev, next float64) (gain, loss float64) {
+	change := next - prev
+	return math.Max(change, 0), math.Max(-change, 0)
+}
+
+// rsi converts average gains and losses to the 0..100 scale, 50 when nothing moved
+func rsi(avgGain, avgLoss float64) float64 {
+	if avgLoss == 0 {
+		if avgGain == 0 {
+			return 50
+		}
+		return 100
+	}
+	return 100 - 100/(1+avgGain/avgLoss)
+}
+
+// RSIStream is the streaming form of RSI
+type RSIStream struct {
+	length    int
+	changes   int
+	prevClose float64
+	avgGain   float64
+	avgLoss   float64
+	started   bool
+}
+
+// NewRSIStream creates a streaming RSI
+func NewRSIStream(length int) *RSIStream {
+	checkLength("RSI", length)
+	return &RSIStream{length: length}
+}
+
+// Update adds the next candle and returns the RSI including it
+func (s *RSIStream) Update(c klinesfrombinance.Candle) float64 {
+	prev := s.prevClose
+	s.prevClose = c.Close
+	if !s.started {
+		s.started = true
+		return math.NaN()
+	}
+
+	gain, loss := priceChange(prev, c.Close)
+	s.changes++
+	if s.changes <= s.length {
+		s.avgGain += gain
+		s.avgLoss += loss
+		if s.changes < s.length {
+			return math.NaN()
+		}
+		s.avgGain /= float64(s.length)
+		s.avgLoss /= float64(s.length)
+	} else {
+		s.avgGain = wilder(s.avgGain, gain, s.length)
+		s.avgLoss = wilder(s.avgLoss, loss, s.length)
+	}
+	return rsi(s.avgGain, s.avgLoss)
+}
+
+// StochasticSeries holds %K and %D for every candle
+type StochasticSeries struct {
+	K []float64
+	D []float64
+}
+
+// StochasticValue is the stochastic oscillator of one candle
+type StochasticValue struct {
+	K float64
+	D float64
+}
+
+// Stochastic calculates the stochastic oscillator: where the close sits in the high-low
+// range of the last period candles, smoothed with an SMA of smoothK candles to get %K.
+// %D is the SMA of %K over smoothD candles. smoothK = 1 gives the fast stochastic.
+func Stochastic(candles []klinesfrombinance.Candle, period, smoothK, smoothD int) StochasticSeries {
+	checkLength("Stochastic period", period)
+	checkLength("Stochastic %K smoothing", smoothK)
+	checkLength("Stochastic %D smoothing", smoothD)
+
+	raw := nanSeries(len(candles))
+	for i := period - 1; i < len(candles); i++ {
+		high, low := candles[i].High, candles[i].Low
+		for j := i - period + 1; j < i; j++ {
+			high = math.Max(high, candles[j].High)
+			low = math.Min(low, candles[j].Low)
+		}
+		raw[i] = stochastic(candles[i].Close, high, low)
+	}
+	k := sma(raw, smoothK)
+	return StochasticSeries{K: k, D: sma(k, smoothD)}
+}
+
+// stochastic places the close in the range on the 0..100 scale, 50 for an empty range
+func stochastic(close, high, low float64) float64 {
+	if high == low {
+		return 50
+	}
+	return 100 * (close - low) / (high - low)
+}
+
+// StochasticStream is the streaming form of Stochastic
+type StochasticStream struct {
+	period int
+	highs  *window
+	lows   *window
+	k, d   *smaState
+}
+
+// NewStochasticStream creates a streaming stochastic oscillator
+func NewStochasticStream(period, smoothK, smoothD int) *StochasticStream {
+	checkLength("Stochastic period", period)
+	checkLength("Stochastic %K smoothing", smoothK)
+	checkLength("Stochastic %D smoothing", smoothD)
+	return &StochasticStream{
+		period: period,
+		highs:  newWindow(period),
+		lows:   newWindow(period),
+		k:      newSMAState(smoothK),
+		d:      newSMAState(smoothD),
+	}
+}
+
+// Update adds the next candle and returns the oscillator including it
+func (s *StochasticStream) Update(c klinesfrombinance.Candle) StochasticValue {
+	s.highs.push(c.High)
+	s.lows.push(c.Low)
+	raw := math.NaN()
+	if s.highs.full() {
+		high, low := c.High, c.Low
+		for j := 0; j < s.period; j++ {
+			high = math.Max(high, s.highs.at(j))
+			low = math.Min(low, s.lows.at(j))
+		}
+		raw = stochastic(c.Close, high, low)
+	}
+	k := s.k.add(raw)
+	return StochasticValue{K: k, D: s.d.add(k)}
+}
@@ -0,0 +1,130 @@
+package indicators
+
+import (
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"math"
+)
+
+// ATR calculates Wilder's average true range. The true range needs the previous close,
+// so the first ATR, at index length, is the simple average of the true ranges of
+// candles 1..length. Later values use Wilder's smoothing.
+func ATR(candles []klinesfrombinance.Candle, length int) []float64 {
+	checkLength("ATR", length)
+	out := nanSeries(len(candles))
+	atr := 0.0
+	for i := 1; i < len(candles); i++ {
+		tr := trueRange(candles[i], candles[i-1].Close)
+		if i <= length {
+			atr += tr
+			if i < length {
+				continue
+			}
+			atr /= float64(length)
+		} else {
+			atr = wilder(atr, tr, length)
+		}
+		out[i] = atr
+	}
+	return out
+}
+
+// ATRStream is the streaming form of ATR
+type ATRStream struct {
+	length    int
+	ranges    int
+	prevClose float64
+	atr       float64
+	started   bool
+}
+
+// NewATRStream creates a streaming average true range
+func NewATRStream(length int) *ATRStream {
+	checkLength("ATR", length)
+	return &ATRStream{length: length}
+}
+
+// Update adds the next candle and returns the ATR including it
+func (s *ATRStream) Update(c klinesfrombinance.Candle) float64 {
+	prev := s.prevClose
+	s.prevClose = c.Close
+	if !s.started {
+		s.started = true
+		return math.NaN()
+	}
+
+	tr := trueRange(c, prev)
+	s.ranges++
+	if s.ranges <= s.length {
+		s.atr += tr
+		if s.ranges < s.length {
+			return math.NaN()
+		}
+		s.atr /= float64(s.length)
+	} else {
+		s.atr = wilder(s.atr, tr, s.length)
+	}
+	return s.atr
+}
+
+// BandsSeries holds the Bollinger Bands for every candle
+type BandsSeries struct {
+	Upper  []float64
+	Middle []float64
+	Lower  []float64
+}
+
+// BandsValue is the Bollinger Bands of one candle
+type BandsValue struct {
+	Upper  float64
+	Middle float64
+	Lower  float64
+}
+
+// BollingerBands calculates the SMA of the closes and the bands width standard
+// deviations above and below it. The population standard deviation of the last length
+// closes is used, as most charting tools do.
+func BollingerBands(candles []klinesfrombinance.Candle, length int, width float64) BandsSeries {
+	checkLength("Bollinger Bands", length)
+	prices := closes(candles)
+	middle := sma(prices, length)
+	upper := nanSeries(len(candles))
+	lower := nanSeries(len(candles))
+	for i := length - 1; i < len(candles); i++ {
+		sumSquares := 0.0
+		for _, v := range prices[i-length+1 : i+1] {
+			d := v - middle[i]
+			sumSquares += d * d
+		}
+		deviation := math.Sqrt(sumSquares / float64(length))
+		upper[i] = middle[i] + width*deviation
+		lower[i] = middle[i] - width*deviation
+	}
+	return BandsSeries{Upper: upper, Middle: middle, Lower: lower}
+}
+
+// BollingerStream is the streaming form of BollingerBands
+type BollingerStream struct {
+	width float64
+	sma   *smaState
+}
+
+// NewBollingerStream creates streaming Bollinger Bands
+func NewBollingerStream(length int, width float64) *BollingerStream {
+	checkLength("Bollinger Bands", length)
+	return &BollingerStream{width: width, sma: newSMAState(length)}
+}
+
+// Update adds the next candle and returns the bands including it
+func (s *BollingerStream) Update(c klinesfrombinance.Candle) BandsValue {
+	middle := s.sma.add(c.Close)
+	if math.IsNaN(middle) {
+		return BandsValue{Upper: math.NaN(), Middle: middle, Lower: math.NaN()}
+	}
+	sumSquares := 0.0
+	for j := 0; j < s.sma.length; j++ {
+		d := s.sma.window.at(j) - middle
+		sumSquares += d * d
+	}
+	deviation := math.Sqrt(sumSquares / float64(s.sma.length))
+	return BandsValue{Upper: middle + s.width*deviation, Middle: middle, Lower: middle - s.width*deviation}
+}
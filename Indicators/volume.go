@@ -0,0 +1,106 @@
+package indicators
+
+import (
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"math"
+)
+
+// millisPerDay is the length of a VWAP session
+const millisPerDay = 24 * 60 * 60 * 1000
+
+// VWAP calculates the volume weighted average of the typical price (high+low+close)/3.
+// It restarts with every UTC day, like the session VWAP on Binance charts, and is NaN
+// while the session has no volume.
+func VWAP(candles []klinesfrombinance.Candle) []float64 {
+	out := nanSeries(len(candles))
+	var priceVolume, volume float64
+	for i, c := range candles {
+		if i == 0 || session(c) != session(candles[i-1]) {
+			priceVolume, volume = 0, 0
+		}
+		priceVolume += typicalPrice(c) * c.Volume
+		volume += c.Volume
+		if volume > 0 {
+			out[i] = priceVolume / volume
+		}
+	}
+	return out
+}
+
+// session returns the UTC day of the candle
+func session(c klinesfrombinance.Candle) int64 {
+	return c.Timestamp / millisPerDay
+}
+
+func typicalPrice(c klinesfrombinance.Candle) float64 {
+	return (c.High + c.Low + c.Close) / 3
+}
+
+// VWAPStream is the streaming form of VWAP
+type VWAPStream struct {
+	session     int64
+	priceVolume float64
+	volume      float64
+	started     bool
+}
+
+// NewVWAPStream creates a streaming VWAP
+func NewVWAPStream() *VWAPStream {
+	return &VWAPStream{}
+}
+
+// Update adds the next candle and returns the VWAP of its session including it
+func (s *VWAPStream) Update(c klinesfrombinance.Candle) float64 {
+	if !s.started || session(c) != s.session {
+		s.started, s.session = true, session(c)
+		s.priceVolume, s.volume = 0, 0
+	}
+	s.priceVolume += typicalPrice(c) * c.Volume
+	s.volume += c.Volume
+	if s.volume == 0 {
+		return math.NaN()
+	}
+	return s.priceVolume / s.volume
+}
+
+// OBV calculates the on-balance volume: starting from 0 on the first candle, the volume
+// is added when the close rises and subtracted when it falls
+func OBV(candles []klinesfrombinance.Candle) []float64 {
+	out := make([]float64, len(candles))
+	for i := 1; i < len(candles); i++ {
+		out[i] = out[i-1] + obvChange(candles[i-1].Close, candles[i])
+	}
+	return out
+}
+
+// obvChange is the signed volume of c
+func obvChange(prevClose float64, c klinesfrombinance.Candle) float64 {
+	switch {
+	case c.Close > prevClose:
+		return c.Volume
+	case c.Close < prevClose:
+		return -c.Volume
+	}
+	return 0
+}
+
+// OBVStream is the streaming form of OBV
+type OBVStream struct {
+	prevClose float64
+	obv       float64
+	started   bool
+}
+
+// NewOBVStream creates a streaming on-balance volume
+func NewOBVStream() *OBVStream {
+	return &OBVStream{}
+}
+
+// Update adds the next candle and returns the OBV including it
+func (s *OBVStream) Update(c klinesfrombinance.Candle) float64 {
+	if s.started {
+		s.obv += obvChange(s.prevClose, c)
+	}
+	s.started, s.prevClose = true, c.Close
+	return s.obv
+}
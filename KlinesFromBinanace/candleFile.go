@@ -0,0 +1,148 @@
+package klinesfrombinance
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+)
+
+// csvHeader is the column layout of the candle history files
+var csvHeader = []string{"symbol", "timestamp", "datetime", "date", "hour", "open", "high", "low", "close", "volume"}
+
+// candleRecord formats a candle as one CSV row
+func candleRecord(c Candle) []string {
+	return []string{
+		c.Symbol,
+		strconv.FormatInt(c.Timestamp, 10),
+		c.Datetime.Format("2006-01-02 15:04:05"),
+		c.Date,
+		strconv.Itoa(c.Hour),
+		strconv.FormatFloat(c.Open, 'f', 8, 64),
+		strconv.FormatFloat(c.High, 'f', 8, 64),
+		strconv.FormatFloat(c.Low, 'f', 8, 64),
+		strconv.FormatFloat(c.Close, 'f', 8, 64),
+		strconv.FormatFloat(c.Volume, 'f', 8, 64),
+	}
+}
+
+// writeCandles writes the header (if requested) and the candles, reporting the first error
+func writeCandles(w io.Writer, candles []Candle, header bool) error {
+	writer := csv.NewWriter(w)
+	if header {
+		if err := writer.Write(csvHeader); err != nil {
+			return fmt.Errorf("failed to write CSV header: %w", err)
+		}
+	}
+	for _, c := range candles {
+		if err := writer.Write(candleRecord(c)); err != nil {
+			return fmt.Errorf("failed to write candle %d: %w", c.Timestamp, err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("error flushing CSV writer: %w", err)
+	}
+	return nil
+}
+
+// writeCandlesCSV replaces the file at path with the candles. The rows go to a temporary
+// file in the same folder which is synced to disk and then renamed over the original,
+// so a crash or a full disk leaves either the old or the new file, never half of one.
+func writeCandlesCSV(path string, candles []Candle) (err error) {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary CSV: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := writeCandles(tmp, candles, true); err != nil {
+		return err
+	}
+	// CreateTemp makes the file private, keep the mode of the file it replaces instead
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return fmt.Errorf("failed to set mode of temporary CSV: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync temporary CSV: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary CSV: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	syncDir(dir)
+	return nil
+}
+
+// appendCandlesCSV adds candles to the end of an existing CSV file and syncs it. If the
+// append fails the file is truncated back to its previous size. It returns false without
+// writing anything when the file does not end with a newline or its columns are not in
+// the csvHeader order the rows are written in, the caller must rewrite it then.
+func appendCandlesCSV(path string, candles []Candle) (bool, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return false, fmt.Errorf("failed to open CSV for appending: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to stat CSV: %w", err)
+	}
+	size := info.Size()
+	if size == 0 {
+		return false, nil
+	}
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, size-1); err != nil {
+		return false, fmt.Errorf("failed to read the end of the CSV: %w", err)
+	}
+	if last[0] != '\n' {
+		return false, nil
+	}
+	header, err := csv.NewReader(io.NewSectionReader(file, 0, size)).Read()
+	if err != nil || !slices.Equal(header, csvHeader) {
+		return false, nil
+	}
+
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		return false, fmt.Errorf("failed to seek to the end of the CSV: %w", err)
+	}
+	err = writeCandles(file, candles, false)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		if truncErr := file.Truncate(size); truncErr != nil {
+			return false, fmt.Errorf("failed to append to %s: %w (and restoring its size failed: %v)", path, err, truncErr)
+		}
+		return false, fmt.Errorf("failed to append to %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// syncDir flushes a directory entry change such as a rename. Not every platform supports
+// syncing directories, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
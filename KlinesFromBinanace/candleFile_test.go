@@ -0,0 +1,167 @@
+package klinesfrombinance
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var firstHour = time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+func hourlyCandles(from int64, n int) []Candle {
+	candles := make([]Candle, n)
+	for i := range candles {
+		candles[i] = NewCandle("ETHUSDT", from+int64(i)*time.Hour.Milliseconds(), 100, 101, 99, 100.5, 10)
+	}
+	return candles
+}
+
+func TestWriteCandlesCSVReplacesAtomically(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ETHUSDT_1h.csv")
+	if err := writeCandlesCSV(path, hourlyCandles(firstHour, 3)); err != nil {
+		t.Fatalf("writeCandlesCSV returned error: %v", err)
+	}
+	if err := writeCandlesCSV(path, hourlyCandles(firstHour, 5)); err != nil {
+		t.Fatalf("writeCandlesCSV returned error: %v", err)
+	}
+
+	candles, _, err := parseCSV(path, ParseOptions{})
+	if err != nil {
+		t.Fatalf("parseCSV returned error: %v", err)
+	}
+	if len(candles) != 5 || candles[4].Timestamp != firstHour+4*time.Hour.Milliseconds() {
+		t.Errorf("read back %d candles: %+v", len(candles), candles)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected only the CSV in %s, found %d entries", dir, len(entries))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat CSV: %v", err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("rewritten CSV has mode %v, want 0644", info.Mode().Perm())
+	}
+
+	// A file with another mode keeps it
+	os.Chmod(path, 0664)
+	if err := writeCandlesCSV(path, hourlyCandles(firstHour, 6)); err != nil {
+		t.Fatalf("writeCandlesCSV returned error: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0664 {
+		t.Errorf("rewritten CSV has mode %v (%v), want the previous 0664", info, err)
+	}
+}
+
+func TestWriteCandlesCSVReportsErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "ETHUSDT_1h.csv")
+	if err := writeCandlesCSV(path, hourlyCandles(firstHour, 1)); err == nil {
+		t.Error("expected an error for a folder that does not exist")
+	}
+}
+
+func TestAppendCandlesCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ETHUSDT_1h.csv")
+	if err := writeCandlesCSV(path, hourlyCandles(firstHour, 2)); err != nil {
+		t.Fatalf("writeCandlesCSV returned error: %v", err)
+	}
+	appended, err := appendCandlesCSV(path, hourlyCandles(firstHour+2*time.Hour.Milliseconds(), 2))
+	if err != nil || !appended {
+		t.Fatalf("appendCandlesCSV = %t, %v", appended, err)
+	}
+	candles, _, err := parseCSV(path, ParseOptions{})
+	if err != nil || len(candles) != 4 {
+		t.Fatalf("parseCSV = %d candles, %v; want 4", len(candles), err)
+	}
+
+	// A file without a trailing newline must be rewritten instead
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, bytes.TrimRight(data, "\n"), 0644)
+	appended, err = appendCandlesCSV(path, hourlyCandles(firstHour+4*time.Hour.Milliseconds(), 1))
+	if err != nil || appended {
+		t.Errorf("appendCandlesCSV = %t, %v; want false without error", appended, err)
+	}
+
+	// So must a file whose columns are in another order
+	reordered := "timestamp,symbol,datetime,date,hour,open,high,low,close,volume\n" +
+		"1737072000000,ETHUSDT,2025-01-17 00:00:00,2025-01-17,0,100,102,99,101,1\n"
+	os.WriteFile(path, []byte(reordered), 0644)
+	appended, err = appendCandlesCSV(path, hourlyCandles(firstHour+time.Hour.Milliseconds(), 1))
+	if err != nil || appended {
+		t.Errorf("appendCandlesCSV = %t, %v; want false for a reordered header", appended, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != reordered {
+		t.Error("appendCandlesCSV wrote to a file with reordered columns")
+	}
+	// The store rewrites it in the csvHeader order instead
+	store, err := OpenCSVStore(path, "ETHUSDT", "1h", RejectBadRows)
+	if err != nil {
+		t.Fatalf("OpenCSVStore returned error: %v", err)
+	}
+	if err := store.Append(context.Background(), hourlyCandles(firstHour+time.Hour.Milliseconds(), 1)); err != nil {
+		t.Fatalf("Append returned error: %v", err)
+	}
+	candles, _, err = parseCSV(path, ParseOptions{})
+	if err != nil || len(candles) != 2 || candles[0].Timestamp != firstHour || candles[1].Symbol != "ETHUSDT" {
+		t.Errorf("parseCSV after appending to a reordered file = %+v, %v", candles, err)
+	}
+}
+
+func TestUpdateAppendsNewCandlesWithoutRewriting(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Hour).UnixMilli()
+	start := now - 10*time.Hour.Milliseconds()
+	path := filepath.Join(t.TempDir(), "ETHUSDT_1h.csv")
+	if err := writeCandlesCSV(path, hourlyCandles(start, 5)); err != nil {
+		t.Fatalf("writeCandlesCSV returned error: %v", err)
+	}
+	before, _ := os.ReadFile(path)
+
+	server, _ := newKlinesStandIn(t, func(call int, w http.ResponseWriter, r *http.Request) {
+		startTime, _ := strconv.ParseInt(r.URL.Query().Get("startTime"), 10, 64)
+		if call > 1 {
+			fmt.Fprint(w, "[]")
+			return
+		}
+		fmt.Fprint(w, klinesJSON(startTime, 3))
+	})
+
+	candles, err := updateCSV(server.URL, path, NewRateLimiter(6000), RejectBadRows)
+	if err != nil {
+		t.Fatalf("updateCSV returned error: %v", err)
+	}
+	if len(candles) != 8 {
+		t.Errorf("returned %d candles, want 8", len(candles))
+	}
+	after, _ := os.ReadFile(path)
+	if !bytes.HasPrefix(after, before) {
+		t.Error("the existing rows were rewritten instead of appended to")
+	}
+	saved, _, err := parseCSV(path, ParseOptions{})
+	if err != nil || len(saved) != 8 {
+		t.Errorf("parseCSV = %d candles, %v; want 8", len(saved), err)
+	}
+}
+
+func TestMergeCandlesRewritesOverlaps(t *testing.T) {
+	existing := hourlyCandles(firstHour, 3)
+	merged, appendOnly := MergeCandles(existing, hourlyCandles(firstHour+time.Hour.Milliseconds(), 3))
+	if appendOnly {
+		t.Error("overlapping candles reported as append-only")
+	}
+	if len(merged) != 4 {
+		t.Errorf("merged %d candles, want 4", len(merged))
+	}
+	for i := 1; i < len(merged); i++ {
+		if merged[i].Timestamp <= merged[i-1].Timestamp {
+			t.Fatalf("merged candles not sorted: %+v", merged)
+		}
+	}
+}
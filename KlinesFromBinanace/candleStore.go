@@ -0,0 +1,141 @@
+package klinesfrombinance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// CandleStore persists the candle history of one symbol and interval
+type CandleStore interface {
+	// Range returns the candles opened between from and to (Unix milliseconds, inclusive)
+	// in timestamp order
+	Range(ctx context.Context, from, to int64) ([]Candle, error)
+	// Append stores candles, replacing stored candles with the same timestamp
+	Append(ctx context.Context, candles []Candle) error
+	// LastTimestamp returns the open time of the newest candle, or 0 if the store is empty
+	LastTimestamp(ctx context.Context) (int64, error)
+	// Gaps returns the missing intervals between the oldest and the newest candle
+	Gaps(ctx context.Context) ([]Gap, error)
+	// KnownGaps returns the gaps recorded as unrecoverable by SetKnownGaps
+	KnownGaps(ctx context.Context) ([]Gap, error)
+	SetKnownGaps(ctx context.Context, gaps []Gap) error
+	Close() error
+}
+
+// AllCandles returns every candle of a store
+func AllCandles(ctx context.Context, store CandleStore) ([]Candle, error) {
+	return store.Range(ctx, 0, math.MaxInt64)
+}
+
+// GapInterval returns the candle length used to search for gaps, or 0 for intervals
+// such as "1M" that have no fixed length
+func GapInterval(interval string) time.Duration {
+	d, err := IntervalDuration(interval)
+	if err != nil || interval == "1M" {
+		return 0
+	}
+	return d
+}
+
+// CSVStore keeps the history in one CSV file. The file is loaded into memory when the
+// store is opened. Candles after the last one are appended to the file, anything else
+// rewrites it atomically.
+type CSVStore struct {
+	path     string
+	interval time.Duration
+	candles  []Candle
+}
+
+// OpenCSVStore loads the CSV history at path, which does not need to exist yet. Invalid
+// rows are handled according to policy. If rows were dropped, or the file holds duplicates
+// or rows out of order, it is rewritten cleaned up.
+func OpenCSVStore(path, symbol, interval string, policy BadRowPolicy) (*CSVStore, error) {
+	candles, dropped, err := parseCSV(path, ParseOptions{Symbol: symbol, Policy: policy})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error reading existing CSV: %w", err)
+	}
+
+	// Duplicates and rows out of order are dropped by sorting, but the file must be rewritten
+	report := CheckIntegrity(candles, 0)
+	s := &CSVStore{path: path, interval: GapInterval(interval)}
+	s.candles, _ = MergeCandles(nil, candles)
+	if len(dropped) == 0 && len(report.Duplicates) == 0 && len(report.OutOfOrder) == 0 {
+		return s, nil
+	}
+
+	fmt.Printf("Cleaning up %s: %d invalid, %d duplicate and %d out of order rows.\n",
+		path, len(dropped), len(report.Duplicates), len(report.OutOfOrder))
+	if err := writeCandlesCSV(path, s.candles); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Range returns the candles opened between from and to
+func (s *CSVStore) Range(ctx context.Context, from, to int64) ([]Candle, error) {
+	start := sort.Search(len(s.candles), func(i int) bool { return s.candles[i].Timestamp >= from })
+	end := sort.Search(len(s.candles), func(i int) bool { return s.candles[i].Timestamp > to })
+	if start >= end {
+		return nil, nil
+	}
+	return append([]Candle(nil), s.candles[start:end]...), nil
+}
+
+// Append adds candles to the file, only writing the new rows when they all come after the last candle
+func (s *CSVStore) Append(ctx context.Context, candles []Candle) error {
+	if len(candles) == 0 {
+		return nil
+	}
+	merged, appendOnly := MergeCandles(s.candles, candles)
+	if appendOnly {
+		appended, err := appendCandlesCSV(s.path, candles)
+		if err != nil {
+			return err
+		}
+		if appended {
+			s.candles = merged
+			return nil
+		}
+	}
+
+	// Rewrite the whole file when the history changed in the middle or cannot be appended to
+	if err := writeCandlesCSV(s.path, merged); err != nil {
+		return err
+	}
+	s.candles = merged
+	return nil
+}
+
+// LastTimestamp returns the open time of the newest candle
+func (s *CSVStore) LastTimestamp(ctx context.Context) (int64, error) {
+	if len(s.candles) == 0 {
+		return 0, nil
+	}
+	return s.candles[len(s.candles)-1].Timestamp, nil
+}
+
+// Gaps returns the missing intervals of the history
+func (s *CSVStore) Gaps(ctx context.Context) ([]Gap, error) {
+	return CheckIntegrity(s.candles, s.interval).Gaps, nil
+}
+
+// KnownGaps reads the gap report next to the CSV file
+func (s *CSVStore) KnownGaps(ctx context.Context) ([]Gap, error) {
+	return ReadGapReport(GapReportPath(s.path))
+}
+
+// SetKnownGaps replaces the gap report next to the CSV file
+func (s *CSVStore) SetKnownGaps(ctx context.Context, gaps []Gap) error {
+	return WriteGapReport(GapReportPath(s.path), gaps, s.interval)
+}
+
+// Close releases the loaded candles, the file is always up to date
+func (s *CSVStore) Close() error {
+	s.candles = nil
+	return nil
+}
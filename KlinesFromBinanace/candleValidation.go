@@ -0,0 +1,151 @@
+package klinesfrombinance
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BadRowPolicy decides what parseCSV does with rows that fail parsing or validation
+type BadRowPolicy string
+
+const (
+	RejectBadRows     BadRowPolicy = "reject"     // Fail the whole file
+	SkipBadRows       BadRowPolicy = "skip"       // Drop the rows with a warning
+	QuarantineBadRows BadRowPolicy = "quarantine" // Drop the rows and save them next to the file
+)
+
+// ParseBadRowPolicy converts a BAD_ROW_POLICY setting, an empty value means reject
+func ParseBadRowPolicy(s string) (BadRowPolicy, error) {
+	switch policy := BadRowPolicy(strings.ToLower(s)); policy {
+	case "":
+		return RejectBadRows, nil
+	case RejectBadRows, SkipBadRows, QuarantineBadRows:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown bad row policy '%s' (expected reject, skip or quarantine)", s)
+}
+
+// ParseOptions controls how parseCSV treats the rows of a history file
+type ParseOptions struct {
+	Symbol string       // Expected symbol of every row, empty accepts any
+	Policy BadRowPolicy // Defaults to RejectBadRows
+}
+
+// RowError is a CSV row that could not be parsed or failed validation. Line is the line
+// of the file, Column the 1-based column of the bad field or 0 if the row as a whole is wrong.
+type RowError struct {
+	Line   int
+	Column int
+	Field  string
+	Record []string
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	if e.Column == 0 {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d, column %d (%s): %v", e.Line, e.Column, e.Field, e.Err)
+}
+
+func (e *RowError) Unwrap() error { return e.Err }
+
+// maxReportedRows limits how many bad rows a rejected file lists in its error
+const maxReportedRows = 10
+
+// rejectError joins the errors of the bad rows of a rejected file
+func rejectError(filePath string, bad []*RowError) error {
+	errs := make([]error, 0, maxReportedRows+1)
+	for i, rowErr := range bad {
+		if i == maxReportedRows {
+			errs = append(errs, fmt.Errorf("... and %d more bad rows", len(bad)-maxReportedRows))
+			break
+		}
+		errs = append(errs, rowErr)
+	}
+	return fmt.Errorf("%s has %d invalid rows: %w", filePath, len(bad), errors.Join(errs...))
+}
+
+// validateCandle checks a parsed candle for values that cannot come from the exchange.
+// It returns the name of the offending column, or an empty string for a valid candle.
+func validateCandle(c Candle, symbol string) (string, error) {
+	if symbol != "" && c.Symbol != symbol {
+		return "symbol", fmt.Errorf("symbol %s does not match the expected %s", c.Symbol, symbol)
+	}
+	prices := []struct {
+		column string
+		value  float64
+	}{{"open", c.Open}, {"high", c.High}, {"low", c.Low}, {"close", c.Close}}
+	for _, p := range prices {
+		if math.IsNaN(p.value) || math.IsInf(p.value, 0) || p.value <= 0 {
+			return p.column, fmt.Errorf("price %g must be a positive number", p.value)
+		}
+	}
+	if c.High < math.Max(c.Open, c.Close) {
+		return "high", fmt.Errorf("high %g is below max(open, close) %g", c.High, math.Max(c.Open, c.Close))
+	}
+	if c.Low > math.Min(c.Open, c.Close) {
+		return "low", fmt.Errorf("low %g is above min(open, close) %g", c.Low, math.Min(c.Open, c.Close))
+	}
+	if math.IsNaN(c.Volume) || math.IsInf(c.Volume, 0) || c.Volume < 0 {
+		return "volume", fmt.Errorf("volume %g must be a non-negative number", c.Volume)
+	}
+
+	// The datetime column is written in the local time of the machine that downloaded the
+	// candle, so it must differ from the timestamp by a valid time zone offset
+	offset := c.Datetime.Sub(time.UnixMilli(c.Timestamp).UTC())
+	if offset%(15*time.Minute) != 0 || offset < -14*time.Hour || offset > 14*time.Hour {
+		return "datetime", fmt.Errorf("datetime %s does not match timestamp %d", c.Datetime.Format("2006-01-02 15:04:05"), c.Timestamp)
+	}
+	if date := c.Datetime.Format("2006-01-02"); c.Date != date {
+		return "date", fmt.Errorf("date %s does not match datetime %s", c.Date, c.Datetime.Format("2006-01-02 15:04:05"))
+	}
+	if c.Hour != c.Datetime.Hour() {
+		return "hour", fmt.Errorf("hour %d does not match datetime %s", c.Hour, c.Datetime.Format("2006-01-02 15:04:05"))
+	}
+	return "", nil
+}
+
+// quarantinePath returns the file collecting the bad rows of a history file,
+// e.g. data/ETHUSDT_15m_quarantine.csv for data/ETHUSDT_15m.csv
+func quarantinePath(filePath string) string {
+	return strings.TrimSuffix(filePath, filepath.Ext(filePath)) + "_quarantine.csv"
+}
+
+// quarantineRows appends the bad rows to the quarantine file with their line and error,
+// so they can be inspected after they are dropped from the history
+func quarantineRows(filePath string, header []string, bad []*RowError) error {
+	path := quarantinePath(filePath)
+	_, statErr := os.Stat(path)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open quarantine file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if errors.Is(statErr, os.ErrNotExist) {
+		if err := writer.Write(append([]string{"quarantined_at", "line", "error"}, header...)); err != nil {
+			return fmt.Errorf("failed to write quarantine header: %w", err)
+		}
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	for _, rowErr := range bad {
+		record := append([]string{now, strconv.Itoa(rowErr.Line), rowErr.Error()}, rowErr.Record...)
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write quarantined row: %w", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write quarantine file: %w", err)
+	}
+	return file.Sync()
+}
@@ -0,0 +1,136 @@
+package klinesfrombinance
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const validRows = `symbol,timestamp,datetime,date,hour,open,high,low,close,volume
+ETHUSDT,1737072900000,2025-01-17 02:15:00,2025-01-17,2,3310.6,3317.86,3307.8,3314.39,1856.7883
+ETHUSDT,1737073800000,2025-01-17 02:30:00,2025-01-17,2,3314.4,3317.78,3309.61,3314.86,1456.017
+`
+
+const badRows = `ETHUSDT,1737074700000,2025-01-17 02:45:00,2025-01-17,2,abc,3317.78,3309.61,3314.86,1456.017
+ETHUSDT,1737075600000,2025-01-17 03:00:00,2025-01-17,3,3314.4,3310.00,3309.61,3314.86,1456.017
+ETHUSDT,1737076500000,2025-01-17 03:15:00,2025-01-17,3,3314.4
+`
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "ETHUSDT_15m.csv")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseCSVRejectsBadRowsWithLineAndColumn(t *testing.T) {
+	path := writeFile(t, validRows+badRows)
+	_, _, err := parseCSV(path, ParseOptions{Symbol: "ETHUSDT"})
+	if err == nil {
+		t.Fatal("expected the bad rows to be rejected")
+	}
+	for _, want := range []string{"3 invalid rows", "line 4, column 6 (open)", "line 5, column 7 (high)", "line 6: wrong number of fields"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) || rowErr.Line != 4 {
+		t.Errorf("errors.As(RowError) = %+v", rowErr)
+	}
+}
+
+func TestParseCSVSkipsBadRows(t *testing.T) {
+	path := writeFile(t, validRows+badRows)
+	candles, dropped, err := parseCSV(path, ParseOptions{Symbol: "ETHUSDT", Policy: SkipBadRows})
+	if err != nil {
+		t.Fatalf("parseCSV returned error: %v", err)
+	}
+	if len(candles) != 2 || len(dropped) != 3 {
+		t.Errorf("got %d candles and %d dropped rows, want 2 and 3", len(candles), len(dropped))
+	}
+	if _, err := os.Stat(quarantinePath(path)); !os.IsNotExist(err) {
+		t.Error("skip policy must not write a quarantine file")
+	}
+}
+
+func TestParseCSVQuarantinesBadRows(t *testing.T) {
+	path := writeFile(t, validRows+badRows)
+	if _, _, err := parseCSV(path, ParseOptions{Policy: QuarantineBadRows}); err != nil {
+		t.Fatalf("parseCSV returned error: %v", err)
+	}
+	data, err := os.ReadFile(quarantinePath(path))
+	if err != nil {
+		t.Fatalf("quarantine file not written: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "quarantined_at,line,error,symbol") {
+		t.Errorf("unexpected quarantine file:\n%s", data)
+	}
+}
+
+func TestValidateCandle(t *testing.T) {
+	valid := NewCandle("ETHUSDT", firstHour, 100, 102, 99, 101, 5)
+	valid.Datetime = time.UnixMilli(firstHour).UTC().Add(2 * time.Hour) // Written in UTC+2
+	valid.Date, valid.Hour = valid.Datetime.Format("2006-01-02"), valid.Datetime.Hour()
+	if column, err := validateCandle(valid, "ETHUSDT"); err != nil {
+		t.Fatalf("valid candle rejected in %s: %v", column, err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(c *Candle)
+		column string
+	}{
+		{"other symbol", func(c *Candle) { c.Symbol = "BTCUSDT" }, "symbol"},
+		{"zero price", func(c *Candle) { c.Open = 0 }, "open"},
+		{"high below close", func(c *Candle) { c.High = 100.5 }, "high"},
+		{"low above open", func(c *Candle) { c.Low = 100.5 }, "low"},
+		{"negative volume", func(c *Candle) { c.Volume = -1 }, "volume"},
+		{"datetime off by 7 minutes", func(c *Candle) { c.Datetime = c.Datetime.Add(7 * time.Minute) }, "datetime"},
+		{"datetime off by a day", func(c *Candle) { c.Datetime = c.Datetime.Add(24 * time.Hour) }, "datetime"},
+		{"wrong date", func(c *Candle) { c.Date = "2025-01-18" }, "date"},
+		{"wrong hour", func(c *Candle) { c.Hour = 5 }, "hour"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.change(&c)
+			column, err := validateCandle(c, "ETHUSDT")
+			if err == nil || column != tt.column {
+				t.Errorf("validateCandle = %s, %v; want an error in %s", column, err, tt.column)
+			}
+		})
+	}
+}
+
+func TestUpdateDownloadsSkippedRowsAgain(t *testing.T) {
+	hour := time.Hour.Milliseconds()
+	base := time.Now().UTC().Truncate(time.Hour).UnixMilli() - 3*hour
+	url, _ := hourlyStandIn(t, 0)
+
+	path := filepath.Join(t.TempDir(), "ETHUSDT_1h.csv")
+	if err := writeCandlesCSV(path, hourlyCandles(base, 3)); err != nil {
+		t.Fatalf("writeCandlesCSV returned error: %v", err)
+	}
+	// Corrupt the price of the middle candle
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(string(data), "\n")
+	lines[2] = strings.Replace(lines[2], "101.00000000", "1.00000000", 1)
+	os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+
+	if _, err := updateCSV(url, path, nil, RejectBadRows); err == nil {
+		t.Fatal("reject policy accepted a corrupted row")
+	}
+	candles, err := updateCSV(url, path, nil, SkipBadRows)
+	if err != nil {
+		t.Fatalf("updateCSV returned error: %v", err)
+	}
+	if len(candles) != 3 || candles[1].High != 101 {
+		t.Errorf("corrupted candle not replaced: %+v", candles)
+	}
+}
@@ -1,13 +1,14 @@
 package klinesfrombinance
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
-	loadenv "learnGoLang/LoadEnv"
 	"log"
+	"math/rand"
 	"net/http"
 	"os"
 	"sort"
@@ -28,21 +29,25 @@ type Candle struct {
 	Volume    float64
 }
 
-func parseCSV(filePath string) ([]Candle, error) {
+// parseCSV reads a candle history file. Every field is parsed strictly and every candle is
+// validated, rows that fail are handled according to opts.Policy. The dropped rows are
+// returned so that the caller can rewrite the file without them.
+func parseCSV(filePath string, opts ParseOptions) ([]Candle, []*RowError, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
 	reader := csv.NewReader(file)
 	header, err := reader.Read() // Read header
 	if err == io.EOF {           // Empty file
-		return nil, nil
+		return nil, nil, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+		return nil, nil, fmt.Errorf("failed to read CSV header: %w", err)
 	}
+	reader.FieldsPerRecord = len(header)
 
 	// Simple check for expected columns (flexible to order)
 	colMap := make(map[string]int)
@@ -50,60 +55,197 @@ func parseCSV(filePath string) ([]Candle, error) {
 		colMap[colName] = i
 	}
 
-	expectedCols := []string{"symbol", "timestamp", "datetime", "date", "hour", "open", "high", "low", "close", "volume"}
-	for _, ec := range expectedCols {
+	for _, ec := range csvHeader {
 		if _, ok := colMap[ec]; !ok {
-			return nil, fmt.Errorf("missing expected column in CSV: %s", ec)
+			return nil, nil, fmt.Errorf("missing expected column in CSV: %s", ec)
 		}
 	}
 
 	var candles []Candle
+	var bad []*RowError
 	for {
 		record, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("failed to read CSV record: %w", err)
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, fmt.Errorf("failed to read CSV record: %w", err)
+			}
+			bad = append(bad, &RowError{Line: parseErr.StartLine, Record: record, Err: parseErr.Err})
+			continue
 		}
 
-		timestamp, _ := strconv.ParseInt(record[colMap["timestamp"]], 10, 64)
-		datetime, _ := time.Parse("2006-01-02 15:04:05", record[colMap["datetime"]])
-		hour, _ := strconv.Atoi(record[colMap["hour"]])
-		open, _ := strconv.ParseFloat(record[colMap["open"]], 64)
-		high, _ := strconv.ParseFloat(record[colMap["high"]], 64)
-		low, _ := strconv.ParseFloat(record[colMap["low"]], 64)
-		close, _ := strconv.ParseFloat(record[colMap["close"]], 64)
-		volume, _ := strconv.ParseFloat(record[colMap["volume"]], 64)
-
-		candles = append(candles, Candle{
-			Symbol: record[colMap["symbol"]], Timestamp: timestamp, Datetime: datetime,
-			Date: record[colMap["date"]], Hour: hour, Open: open, High: high, Low: low,
-			Close: close, Volume: volume,
-		})
+		line, _ := reader.FieldPos(0)
+		candle, rowErr := parseRecord(record, colMap, line)
+		if rowErr == nil {
+			if column, err := validateCandle(candle, opts.Symbol); err != nil {
+				rowErr = fieldError(record, colMap, line, column, err)
+			}
+		}
+		if rowErr != nil {
+			bad = append(bad, rowErr)
+			continue
+		}
+		candles = append(candles, candle)
 	}
-	return candles, nil
+
+	if len(bad) == 0 {
+		return candles, nil, nil
+	}
+	switch opts.Policy {
+	case SkipBadRows:
+		for _, rowErr := range bad {
+			log.Printf("Skipping invalid row of %s: %v", filePath, rowErr)
+		}
+	case QuarantineBadRows:
+		if err := quarantineRows(filePath, header, bad); err != nil {
+			return nil, nil, err
+		}
+		log.Printf("Moved %d invalid rows of %s to %s", len(bad), filePath, quarantinePath(filePath))
+	default:
+		return nil, nil, rejectError(filePath, bad)
+	}
+	return candles, bad, nil
+}
+
+// parseRecord converts one CSV row, reporting the line and column of the first bad field
+func parseRecord(record []string, colMap map[string]int, line int) (Candle, *RowError) {
+	var c Candle
+	var err error
+
+	c.Symbol = record[colMap["symbol"]]
+	if c.Symbol == "" {
+		return c, fieldError(record, colMap, line, "symbol", errors.New("symbol is empty"))
+	}
+	if c.Timestamp, err = strconv.ParseInt(record[colMap["timestamp"]], 10, 64); err != nil {
+		return c, fieldError(record, colMap, line, "timestamp", err)
+	}
+	if c.Datetime, err = time.Parse("2006-01-02 15:04:05", record[colMap["datetime"]]); err != nil {
+		return c, fieldError(record, colMap, line, "datetime", err)
+	}
+	c.Date = record[colMap["date"]]
+	if c.Hour, err = strconv.Atoi(record[colMap["hour"]]); err != nil {
+		return c, fieldError(record, colMap, line, "hour", err)
+	}
+
+	values := []struct {
+		column string
+		dst    *float64
+	}{{"open", &c.Open}, {"high", &c.High}, {"low", &c.Low}, {"close", &c.Close}, {"volume", &c.Volume}}
+	for _, v := range values {
+		if *v.dst, err = strconv.ParseFloat(record[colMap[v.column]], 64); err != nil {
+			return c, fieldError(record, colMap, line, v.column, err)
+		}
+	}
+	return c, nil
 }
 
-// fetchKlinesFromBinance fetches kline data from Binance API for a given time range
-func fetchKlinesFromBinance(symbol, interval string, startTime, endTime int64) ([]Candle, error) {
+func fieldError(record []string, colMap map[string]int, line int, column string, err error) *RowError {
+	return &RowError{Line: line, Column: colMap[column] + 1, Field: column, Record: record, Err: err}
+}
+
+// Retry settings of klines requests. Variables so that tests can shorten them.
+var (
+	maxFetchAttempts    = 5
+	minRetryBackoff     = 500 * time.Millisecond
+	maxRetryBackoff     = 30 * time.Second
+	fetchRequestTimeout = 15 * time.Second
+)
+
+// fetchError is a failed klines request. retryAfter is set when Binance told us how long to wait.
+type fetchError struct {
+	err        error
+	retryable  bool
+	retryAfter time.Duration
+}
+
+func (e *fetchError) Error() string { return e.err.Error() }
+func (e *fetchError) Unwrap() error { return e.err }
+
+// fetchKlinesFromBinance fetches kline data from Binance API for a given time range.
+// Requests are throttled through limiter if it is not nil. Rate limit answers (429, 418),
+// server errors and network errors are retried with jittered exponential backoff.
+func fetchKlinesFromBinance(ctx context.Context, apiBase, symbol, interval string, startTime, endTime int64, limiter *RateLimiter) ([]Candle, error) {
 	url := fmt.Sprintf("%s?symbol=%s&interval=%s&startTime=%d&endTime=%d&limit=1000",
-		loadenv.BINANCE_API_BASE, symbol, interval, startTime, endTime)
+		apiBase, symbol, interval, startTime, endTime)
+
+	backoff := minRetryBackoff
+	for attempt := 1; ; attempt++ {
+		if limiter != nil {
+			if err := limiter.Wait(ctx, klinesRequestWeight); err != nil {
+				return nil, fmt.Errorf("rate limiter: %w", err)
+			}
+		}
+		candles, err := requestKlines(ctx, url, symbol, limiter)
+		if err == nil {
+			return candles, nil
+		}
+
+		var fetchErr *fetchError
+		if !errors.As(err, &fetchErr) || !fetchErr.retryable || attempt >= maxFetchAttempts {
+			return nil, err
+		}
+		delay := jitter(backoff)
+		if fetchErr.retryAfter > 0 {
+			delay = fetchErr.retryAfter
+			if limiter != nil {
+				limiter.PauseUntil(time.Now().Add(delay)) // Hold back the other downloads too
+			}
+		}
+		log.Printf("Klines request for %s %s failed (attempt %d/%d): %v. Retrying in %s",
+			symbol, interval, attempt, maxFetchAttempts, err, delay)
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// requestKlines performs one klines request and reports the used weight to limiter
+func requestKlines(ctx context.Context, url, symbol string, limiter *RateLimiter) ([]Candle, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, fetchRequestTimeout)
+	defer cancel()
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch from Binance API: %w", err)
+		return nil, fmt.Errorf("failed to build Binance API request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// Network errors and request timeouts are worth retrying, unless the caller gave up
+		return nil, &fetchError{err: fmt.Errorf("failed to fetch from Binance API: %w", err), retryable: ctx.Err() == nil}
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Binance API returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	if limiter != nil {
+		if used, ok := usedWeight(resp.Header); ok {
+			limiter.Observe(used)
+		}
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read Binance API response: %w", err)
+		return nil, &fetchError{err: fmt.Errorf("failed to read Binance API response: %w", err), retryable: ctx.Err() == nil}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot:
+		// 429 is a rate limit warning, 418 an IP ban after ignoring it. Both come with Retry-After.
+		return nil, &fetchError{
+			err:        fmt.Errorf("Binance API returned status %d: %s", resp.StatusCode, string(body)),
+			retryable:  true,
+			retryAfter: retryAfter(resp.Header),
+		}
+	case resp.StatusCode >= 500:
+		return nil, &fetchError{err: fmt.Errorf("Binance API returned status %d: %s", resp.StatusCode, string(body)), retryable: true}
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("Binance API returned status %d: %s", resp.StatusCode, string(body))
 	}
 
 	var rawKlines [][]interface{}
@@ -125,84 +267,119 @@ func fetchKlinesFromBinance(symbol, interval string, startTime, endTime int64) (
 		closePrice, _ := strconv.ParseFloat(kline[4].(string), 64)
 		volume, _ := strconv.ParseFloat(kline[5].(string), 64)
 
-		dt := time.Unix(0, openTime*int64(time.Millisecond))
-
-		candles = append(candles, Candle{
-			Symbol:    symbol,
-			Timestamp: openTime,
-			Datetime:  dt,
-			Date:      dt.Format("2006-01-02"),
-			Hour:      dt.Hour(),
-			Open:      openPrice,
-			High:      highPrice,
-			Low:       lowPrice,
-			Close:     closePrice,
-			Volume:    volume,
-		})
+		candles = append(candles, NewCandle(symbol, openTime, openPrice, highPrice, lowPrice, closePrice, volume))
 	}
 	return candles, nil
 }
 
-// updateHistoricalData fetches and updates CSV with missing data from Binance
-func updateHistoricalData(filePath, symbol, interval string) ([]Candle, error) {
-	fmt.Printf("Updating data: %s pair, %s interval, file: %s\n", symbol, interval, filePath)
+// usedWeight reads the request weight used in the current minute from the response headers
+func usedWeight(header http.Header) (int, bool) {
+	for _, key := range []string{"X-MBX-USED-WEIGHT-1M", "X-MBX-USED-WEIGHT"} {
+		if used, err := strconv.Atoi(header.Get(key)); err == nil {
+			return used, true
+		}
+	}
+	return 0, false
+}
 
-	existingCandles, err := parseCSV(filePath)
-	if err != nil && !os.IsNotExist(err) {
-		return nil, fmt.Errorf("error reading existing CSV: %w", err)
+// retryAfter returns the wait requested by the Retry-After header (in seconds), or 0
+func retryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
 	}
+	return time.Duration(seconds) * time.Second
+}
 
-	var lastTimestamp int64 = 0
-	if len(existingCandles) > 0 {
-		// Sort candles by timestamp to ensure the last one is truly the latest
-		sort.Slice(existingCandles, func(i, j int) bool {
-			return existingCandles[i].Timestamp < existingCandles[j].Timestamp
-		})
-		lastTimestamp = existingCandles[len(existingCandles)-1].Timestamp
-		fmt.Printf("Last data in CSV: %s (timestamp: %d)\n", existingCandles[len(existingCandles)-1].Datetime, lastTimestamp)
-	} else {
-		fmt.Println("NO DATA, STARTING FROM THE BEGINNING (2025 JAN 17)")
-		lastTimestamp = time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC).UnixMilli()
+// jitter returns a random duration between d/2 and d so that parallel retries spread out
+func jitter(d time.Duration) time.Duration {
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// FetchKlines downloads the klines of one symbol and interval between startTime and endTime
+// (Unix milliseconds) from the klines endpoint at apiBase. At most 1000 candles are returned per call.
+func FetchKlines(ctx context.Context, apiBase, symbol, interval string, startTime, endTime int64) ([]Candle, error) {
+	return fetchKlinesFromBinance(ctx, apiBase, symbol, interval, startTime, endTime, nil)
+}
+
+// NewCandle builds a Candle from a kline open time (Unix milliseconds) and its OHLCV values
+func NewCandle(symbol string, openTime int64, open, high, low, close, volume float64) Candle {
+	dt := time.Unix(0, openTime*int64(time.Millisecond))
+	return Candle{
+		Symbol:    symbol,
+		Timestamp: openTime,
+		Datetime:  dt,
+		Date:      dt.Format("2006-01-02"),
+		Hour:      dt.Hour(),
+		Open:      open,
+		High:      high,
+		Low:       low,
+		Close:     close,
+		Volume:    volume,
 	}
+}
 
-	// Calculate the duration of one interval in milliseconds for fetching batches
-	var intervalDuration time.Duration
+// IntervalDuration converts a Binance kline interval such as "15m" or "4h" into a duration
+func IntervalDuration(interval string) (time.Duration, error) {
 	switch interval {
 	case "1m":
-		intervalDuration = time.Minute
+		return time.Minute, nil
 	case "3m":
-		intervalDuration = 3 * time.Minute
+		return 3 * time.Minute, nil
 	case "5m":
-		intervalDuration = 5 * time.Minute
+		return 5 * time.Minute, nil
 	case "15m":
-		intervalDuration = 15 * time.Minute
+		return 15 * time.Minute, nil
 	case "30m":
-		intervalDuration = 30 * time.Minute
+		return 30 * time.Minute, nil
 	case "1h":
-		intervalDuration = time.Hour
+		return time.Hour, nil
 	case "2h":
-		intervalDuration = 2 * time.Hour
+		return 2 * time.Hour, nil
 	case "4h":
-		intervalDuration = 4 * time.Hour
+		return 4 * time.Hour, nil
 	case "6h":
-		intervalDuration = 6 * time.Hour
+		return 6 * time.Hour, nil
 	case "8h":
-		intervalDuration = 8 * time.Hour
+		return 8 * time.Hour, nil
 	case "12h":
-		intervalDuration = 12 * time.Hour
+		return 12 * time.Hour, nil
 	case "1d":
-		intervalDuration = 24 * time.Hour
+		return 24 * time.Hour, nil
 	case "3d":
-		intervalDuration = 3 * 24 * time.Hour
+		return 3 * 24 * time.Hour, nil
 	case "1w":
-		intervalDuration = 7 * 24 * time.Hour
+		return 7 * 24 * time.Hour, nil
 	case "1M":
 		// This is tricky, 1 month duration varies. For fetching, it's safer to use a fixed max batch size.
-		// For '1M', it's best to rethink how 'intervalDurationMillis' is used here, or use smaller intervals.
 		// For now, let's assume it's roughly 30 days for batching purposes, but API handles exact month boundaries.
-		intervalDuration = 30 * 24 * time.Hour // Approximate for batching
+		return 30 * 24 * time.Hour, nil // Approximate for batching
 	default:
-		fmt.Printf("Warning: Unknown interval '%s', assuming 1-hour duration for batch size.\n", interval)
+		return 0, fmt.Errorf("unknown interval '%s'", interval)
+	}
+}
+
+// UpdateStore downloads the candles after the newest one in store, then re-fetches the
+// candles missing in the middle of the history. Without a limiter it pauses briefly
+// between batches instead. If a batch fails the candles downloaded before it are still
+// stored, so the next run continues after them.
+func UpdateStore(ctx context.Context, apiBase string, store CandleStore, symbol, interval string, limiter *RateLimiter) error {
+	lastTimestamp, err := store.LastTimestamp(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading the last stored candle: %w", err)
+	}
+	if lastTimestamp > 0 {
+		fmt.Printf("Last stored data: %s (timestamp: %d)\n", time.UnixMilli(lastTimestamp).Format("2006-01-02 15:04:05"), lastTimestamp)
+	} else {
+		fmt.Println("NO DATA, STARTING FROM THE BEGINNING (2025 JAN 17)")
+		lastTimestamp = time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC).UnixMilli()
+	}
+
+	// Calculate the duration of one interval in milliseconds for fetching batches
+	intervalDuration, err := IntervalDuration(interval)
+	if err != nil {
+		fmt.Printf("Warning: %v, assuming 1-hour duration for batch size.\n", err)
 		intervalDuration = time.Hour
 	}
 	intervalDurationMillis := int64(intervalDuration.Milliseconds())
@@ -211,113 +388,124 @@ func updateHistoricalData(filePath, symbol, interval string) ([]Candle, error) {
 	now := time.Now().UTC()
 	fetchEndTime := now.Truncate(intervalDuration).UnixMilli()
 
-	if lastTimestamp == 0 { // If no existing data, start from the defined START_DATE_STR
-		startTimeObj, err := time.Parse("2025-01-02", "2025-01-02")
-		if err != nil {
-			return nil, fmt.Errorf("invalid START_DATE_STR format: %w", err)
-		}
-		// Adjust start time to be aligned with the interval if necessary
-		lastTimestamp = startTimeObj.Truncate(intervalDuration).UnixMilli()
-		fmt.Printf("Starting date based on START_DATE_STR: %s (timestamp: %d)\n", time.Unix(0, lastTimestamp*int64(time.Millisecond)), lastTimestamp)
-	}
-
 	// Adjust lastTimestamp to be the start of the next candle for fetching
 	fetchStartTime := lastTimestamp + intervalDurationMillis
 
+	var fetchErr error
 	if fetchStartTime >= fetchEndTime {
-		fmt.Println("The file is up to date, no need to download new data.")
-		return existingCandles, nil
+		fmt.Println("The history is up to date, no need to download new data.")
+	} else {
+		var newCandles []Candle
+		newCandles, fetchErr = fetchRange(ctx, apiBase, symbol, interval, fetchStartTime, fetchEndTime, intervalDurationMillis, limiter)
+		switch {
+		case len(newCandles) > 0 && fetchErr != nil:
+			fmt.Printf("Download interrupted, saving the %d new candles fetched so far.\n", len(newCandles))
+		case len(newCandles) > 0:
+			fmt.Printf("Successfully downloaded %d new candles.\n", len(newCandles))
+		default:
+			fmt.Println("No new data downloaded.")
+		}
+		if err := store.Append(ctx, newCandles); err != nil {
+			return errors.Join(fmt.Errorf("error saving candles: %w", err), fetchErr)
+		}
 	}
 
+	// Data-integrity pass: re-fetch the candles missing in the middle of the history
+	_, err = repairStore(ctx, apiBase, store, symbol, interval, limiter)
+	return errors.Join(fetchErr, err)
+}
+
+// fetchRange downloads the candles opened between startTime and endTime in batches of
+// 1000. A batch without candles (e.g. an exchange maintenance window) does not end the
+// download. If a batch fails the candles fetched before it are returned with the error.
+func fetchRange(ctx context.Context, apiBase, symbol, interval string, startTime, endTime, intervalMillis int64, limiter *RateLimiter) ([]Candle, error) {
 	fmt.Printf("Fetching data from Binance: from %s to %s...\n",
-		time.Unix(0, fetchStartTime*int64(time.Millisecond)).Format("2006-01-02 15:04:05"),
-		time.Unix(0, fetchEndTime*int64(time.Millisecond)).Format("2006-01-02 15:04:05"))
+		time.Unix(0, startTime*int64(time.Millisecond)).Format("2006-01-02 15:04:05"),
+		time.Unix(0, endTime*int64(time.Millisecond)).Format("2006-01-02 15:04:05"))
 
-	var newCandles []Candle
+	var candles []Candle
 	// Binance limit is 1000 candles per request. Need to loop for larger ranges.
-	for currentBatchStartTime := fetchStartTime; currentBatchStartTime < fetchEndTime; {
+	for currentBatchStartTime := startTime; currentBatchStartTime < endTime; {
 		// Calculate batchEndTime based on 1000 candles * intervalDuration
-		batchEndTime := currentBatchStartTime + (999 * intervalDurationMillis)
-		if batchEndTime >= fetchEndTime {
-			batchEndTime = fetchEndTime
+		batchEndTime := currentBatchStartTime + (999 * intervalMillis)
+		if batchEndTime >= endTime {
+			batchEndTime = endTime
 		}
 
 		fmt.Printf("Fetching: %s -> %s\n",
 			time.Unix(0, currentBatchStartTime*int64(time.Millisecond)).Format("2006-01-02 15:04:05"),
 			time.Unix(0, batchEndTime*int64(time.Millisecond)).Format("2006-01-02 15:04:05"))
 
-		batchCandles, err := fetchKlinesFromBinance(symbol, interval, currentBatchStartTime, batchEndTime)
+		batchCandles, err := fetchKlinesFromBinance(ctx, apiBase, symbol, interval, currentBatchStartTime, batchEndTime, limiter)
 		if err != nil {
-			return nil, fmt.Errorf("error fetching batch from Binance: %w", err)
+			// Keep what was downloaded so far, the next run continues after it
+			return candles, fmt.Errorf("error fetching batch from Binance: %w", err)
 		}
-		newCandles = append(newCandles, batchCandles...)
-
-		if len(batchCandles) == 0 { // No more data in this range
-			break
+		candles = append(candles, batchCandles...)
+
+		if len(batchCandles) == 0 {
+			// Nothing in this range, continue with the next one
+			currentBatchStartTime = batchEndTime + intervalMillis
+		} else {
+			// Move to the start of the next candle after the last fetched candle
+			currentBatchStartTime = batchCandles[len(batchCandles)-1].Timestamp + intervalMillis
+		}
+		if limiter == nil {
+			if err := sleepContext(ctx, 100*time.Millisecond); err != nil { // Be nice to the API
+				return candles, err
+			}
 		}
-		// Move to the start of the next candle after the last fetched candle
-		currentBatchStartTime = batchCandles[len(batchCandles)-1].Timestamp + intervalDurationMillis
-		time.Sleep(100 * time.Millisecond) // Be nice to the API
 	}
+	return candles, nil
+}
 
-	if len(newCandles) == 0 {
-		fmt.Println("No new data downloaded.")
-		return existingCandles, nil
+// sleepContext waits for d, or returns the error of ctx as soon as it is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
+}
 
-	fmt.Printf("Successfully downloaded %d new candles.\n", len(newCandles))
-
-	// Append new candles to existing ones
-	allCandles := append(existingCandles, newCandles...)
+// MergeCandles combines the sorted existing candles with newly downloaded ones, dropping
+// duplicates and sorting by timestamp. It reports whether the new candles are sorted, unique
+// and all come strictly after the existing ones, in which case they can simply be appended.
+func MergeCandles(existing, fresh []Candle) ([]Candle, bool) {
+	appendOnly := len(existing) > 0
+	last := int64(0)
+	if len(existing) > 0 {
+		last = existing[len(existing)-1].Timestamp
+	}
+	for _, c := range fresh {
+		if c.Timestamp <= last {
+			appendOnly = false
+			break
+		}
+		last = c.Timestamp
+	}
+	if appendOnly {
+		return append(existing[:len(existing):len(existing)], fresh...), true
+	}
 
-	// Remove duplicates (if any) and sort
 	uniqueCandlesMap := make(map[int64]Candle)
-	for _, c := range allCandles {
+	for _, c := range existing {
 		uniqueCandlesMap[c.Timestamp] = c
 	}
-	allCandles = make([]Candle, 0, len(uniqueCandlesMap))
+	for _, c := range fresh {
+		uniqueCandlesMap[c.Timestamp] = c
+	}
+	allCandles := make([]Candle, 0, len(uniqueCandlesMap))
 	for _, c := range uniqueCandlesMap {
 		allCandles = append(allCandles, c)
 	}
 	sort.Slice(allCandles, func(i, j int) bool {
 		return allCandles[i].Timestamp < allCandles[j].Timestamp
 	})
-
-	// Write all unique and sorted candles back to the CSV
-	file, err := os.Create(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open CSV for writing: %w", err)
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	writer.Comma = ',' // Use comma as delimiter
-
-	// Write header
-	writer.Write([]string{"symbol", "timestamp", "datetime", "date", "hour", "open", "high", "low", "close", "volume"})
-
-	for _, c := range allCandles {
-		record := []string{
-			c.Symbol,
-			strconv.FormatInt(c.Timestamp, 10),
-			c.Datetime.Format("2006-01-02 15:04:05"),
-			c.Date,
-			strconv.Itoa(c.Hour),
-			strconv.FormatFloat(c.Open, 'f', 8, 64),
-			strconv.FormatFloat(c.High, 'f', 8, 64),
-			strconv.FormatFloat(c.Low, 'f', 8, 64),
-			strconv.FormatFloat(c.Close, 'f', 8, 64),
-			strconv.FormatFloat(c.Volume, 'f', 8, 64),
-		}
-		writer.Write(record)
-	}
-	writer.Flush()
-	if err := writer.Error(); err != nil {
-		return nil, fmt.Errorf("error flushing CSV writer: %w", err)
-	}
-
-	fmt.Printf("CSV file successfully updated: %s\n", filePath)
-	return allCandles, nil
+	return allCandles, false
 }
 
 func CreateDataFolder() error {
@@ -326,14 +514,3 @@ func CreateDataFolder() error {
 	}
 	return nil
 }
-
-func FetchData() ([]Candle, error) {
-	err := CreateDataFolder()
-	if err != nil {
-		log.Fatal(err)
-	} else {
-		log.Println("Data folder created successfully.")
-	}
-	data, err := updateHistoricalData(loadenv.DATA_FILE_PATH, loadenv.SYMBOL, loadenv.BINANCE_INTERVAL)
-	return data, err
-}
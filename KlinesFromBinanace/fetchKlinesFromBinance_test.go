@@ -0,0 +1,188 @@
+package klinesfrombinance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// klinesJSON renders n one-hour klines starting at openTime the way the klines endpoint does
+func klinesJSON(openTime int64, n int) string {
+	rows := make([]string, n)
+	for i := range rows {
+		t := openTime + int64(i)*time.Hour.Milliseconds()
+		rows[i] = fmt.Sprintf(`[%d,"100.0","101.0","99.0","100.5","10.0",%d,"1000.0",5,"4.0","400.0","0"]`,
+			t, t+time.Hour.Milliseconds()-1)
+	}
+	return "[" + strings.Join(rows, ",") + "]"
+}
+
+// newKlinesStandIn serves the klines endpoint, handing each request to respond in turn
+func newKlinesStandIn(t *testing.T, respond func(call int, w http.ResponseWriter, r *http.Request)) (*httptest.Server, *int) {
+	var mu sync.Mutex
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		call := calls
+		mu.Unlock()
+		respond(call, w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func shortRetries(t *testing.T) {
+	minBackoff, maxBackoff := minRetryBackoff, maxRetryBackoff
+	minRetryBackoff, maxRetryBackoff = 5*time.Millisecond, 20*time.Millisecond
+	t.Cleanup(func() { minRetryBackoff, maxRetryBackoff = minBackoff, maxBackoff })
+}
+
+// updateCSV brings the ETHUSDT 1h CSV history at path up to date through UpdateStore, as
+// the candle store of the program does, and returns its candles
+func updateCSV(apiBase, path string, limiter *RateLimiter, policy BadRowPolicy) ([]Candle, error) {
+	ctx := context.Background()
+	store, err := OpenCSVStore(path, "ETHUSDT", "1h", policy)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	updateErr := UpdateStore(ctx, apiBase, store, "ETHUSDT", "1h", limiter)
+	candles, err := AllCandles(ctx, store)
+	return candles, errors.Join(updateErr, err)
+}
+
+func TestFetchRetriesServerErrorsAndObservesWeight(t *testing.T) {
+	shortRetries(t)
+	start := time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC).UnixMilli()
+	server, calls := newKlinesStandIn(t, func(call int, w http.ResponseWriter, r *http.Request) {
+		if call < 3 {
+			http.Error(w, "busy", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("X-MBX-USED-WEIGHT-1M", "5990")
+		fmt.Fprint(w, klinesJSON(start, 3))
+	})
+
+	limiter := NewRateLimiter(6000)
+	candles, err := fetchKlinesFromBinance(context.Background(), server.URL, "ETHUSDT", "1h", start, start+3*time.Hour.Milliseconds(), limiter)
+	if err != nil {
+		t.Fatalf("fetchKlinesFromBinance returned error: %v", err)
+	}
+	if *calls != 3 || len(candles) != 3 {
+		t.Fatalf("calls = %d, candles = %d, want 3 and 3", *calls, len(candles))
+	}
+	if candles[0].Timestamp != start || candles[2].Close != 100.5 {
+		t.Errorf("unexpected candles %+v", candles)
+	}
+
+	// Binance reported 5990 of 6000 used, so 100 weight takes about a second to refill
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx, 100); err == nil {
+		t.Error("limiter ignored the used weight reported by Binance")
+	}
+}
+
+func TestFetchHonorsRetryAfter(t *testing.T) {
+	shortRetries(t)
+	server, calls := newKlinesStandIn(t, func(call int, w http.ResponseWriter, r *http.Request) {
+		if call == 1 {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, `{"code":-1003,"msg":"Too many requests"}`, http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, klinesJSON(0, 1))
+	})
+
+	begin := time.Now()
+	if _, err := fetchKlinesFromBinance(context.Background(), server.URL, "ETHUSDT", "1h", 0, 1, NewRateLimiter(6000)); err != nil {
+		t.Fatalf("fetchKlinesFromBinance returned error: %v", err)
+	}
+	if elapsed := time.Since(begin); elapsed < time.Second {
+		t.Errorf("retried after %s, Retry-After asked for 1s", elapsed)
+	}
+	if *calls != 2 {
+		t.Errorf("calls = %d, want 2", *calls)
+	}
+}
+
+func TestFetchDoesNotRetryClientErrors(t *testing.T) {
+	shortRetries(t)
+	server, calls := newKlinesStandIn(t, func(call int, w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"code":-1121,"msg":"Invalid symbol."}`, http.StatusBadRequest)
+	})
+
+	_, err := fetchKlinesFromBinance(context.Background(), server.URL, "NOPE", "1h", 0, 1, nil)
+	if err == nil || !strings.Contains(err.Error(), "Invalid symbol") {
+		t.Fatalf("err = %v, want the Binance error message", err)
+	}
+	if *calls != 1 {
+		t.Errorf("calls = %d, want 1", *calls)
+	}
+}
+
+func TestFetchGivesUpAfterMaxAttempts(t *testing.T) {
+	shortRetries(t)
+	server, calls := newKlinesStandIn(t, func(call int, w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	})
+
+	if _, err := fetchKlinesFromBinance(context.Background(), server.URL, "ETHUSDT", "1h", 0, 1, nil); err == nil {
+		t.Fatal("expected an error")
+	}
+	if *calls != maxFetchAttempts {
+		t.Errorf("calls = %d, want %d", *calls, maxFetchAttempts)
+	}
+}
+
+func TestUpdateKeepsBatchesDownloadedBeforeAFailure(t *testing.T) {
+	shortRetries(t)
+	server, _ := newKlinesStandIn(t, func(call int, w http.ResponseWriter, r *http.Request) {
+		if call > 1 {
+			http.Error(w, `{"code":-1100,"msg":"Illegal characters"}`, http.StatusBadRequest)
+			return
+		}
+		startTime, _ := strconv.ParseInt(r.URL.Query().Get("startTime"), 10, 64)
+		fmt.Fprint(w, klinesJSON(startTime, 5))
+	})
+
+	path := filepath.Join(t.TempDir(), "ETHUSDT_1h.csv")
+	candles, err := updateCSV(server.URL, path, NewRateLimiter(6000), RejectBadRows)
+	if err == nil {
+		t.Fatal("expected the failed batch to be reported")
+	}
+	if len(candles) != 5 {
+		t.Errorf("returned %d candles, want 5", len(candles))
+	}
+	saved, _, err := parseCSV(path, ParseOptions{})
+	if err != nil {
+		t.Fatalf("parseCSV returned error: %v", err)
+	}
+	if len(saved) != 5 {
+		t.Errorf("saved %d candles, want the 5 downloaded before the failure", len(saved))
+	}
+}
+
+func TestSleepContextStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	if err := sleepContext(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("sleepContext returned %v, want context.Canceled", err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("sleepContext waited %s after the cancel", waited)
+	}
+	if err := sleepContext(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("sleepContext returned %v, want nil", err)
+	}
+}
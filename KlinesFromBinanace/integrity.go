@@ -0,0 +1,251 @@
+package klinesfrombinance
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Gap is a run of missing candles. From and To are the open times (Unix milliseconds)
+// of the first and the last missing candle.
+type Gap struct {
+	From int64
+	To   int64
+}
+
+// Missing returns the number of candles missing in the gap, or 0 for an unknown interval
+func (g Gap) Missing(interval time.Duration) int {
+	if interval <= 0 {
+		return 0
+	}
+	return int((g.To-g.From)/interval.Milliseconds()) + 1
+}
+
+func (g Gap) String() string {
+	return fmt.Sprintf("%s -> %s",
+		time.UnixMilli(g.From).UTC().Format("2006-01-02 15:04:05"),
+		time.UnixMilli(g.To).UTC().Format("2006-01-02 15:04:05"))
+}
+
+// IntegrityReport lists the problems found in a candle series
+type IntegrityReport struct {
+	Gaps       []Gap
+	Duplicates []int64 // Timestamps found more than once
+	OutOfOrder []int64 // Timestamps earlier than the row before them
+}
+
+// Clean reports whether no problem was found
+func (r IntegrityReport) Clean() bool {
+	return len(r.Gaps) == 0 && len(r.Duplicates) == 0 && len(r.OutOfOrder) == 0
+}
+
+// CheckIntegrity scans candles in their given order for duplicates, rows out of order and
+// missing intervals. Gaps are searched only if interval is positive, "1M" candles have no
+// fixed length so callers should pass 0 for them.
+func CheckIntegrity(candles []Candle, interval time.Duration) IntegrityReport {
+	var report IntegrityReport
+	seen := make(map[int64]bool, len(candles))
+	for i, c := range candles {
+		if seen[c.Timestamp] {
+			report.Duplicates = append(report.Duplicates, c.Timestamp)
+		}
+		seen[c.Timestamp] = true
+		if i > 0 && c.Timestamp < candles[i-1].Timestamp {
+			report.OutOfOrder = append(report.OutOfOrder, c.Timestamp)
+		}
+	}
+	if interval <= 0 {
+		return report
+	}
+
+	sorted, _ := MergeCandles(nil, candles)
+	step := interval.Milliseconds()
+	for i := 1; i < len(sorted); i++ {
+		if prev, next := sorted[i-1].Timestamp, sorted[i].Timestamp; next-prev > step {
+			report.Gaps = append(report.Gaps, Gap{From: prev + step, To: next - step})
+		}
+	}
+	return report
+}
+
+// fetchGaps downloads the candles of the missing ranges. If a request fails the candles
+// fetched before it are returned with the error.
+func fetchGaps(ctx context.Context, apiBase, symbol, interval string, gaps []Gap, limiter *RateLimiter) ([]Candle, error) {
+	intervalDuration, err := IntervalDuration(interval)
+	if err != nil {
+		return nil, err
+	}
+	step := intervalDuration.Milliseconds()
+
+	var fetched []Candle
+	for _, g := range gaps {
+		for start := g.From; start <= g.To; {
+			end := start + 999*step
+			if end > g.To {
+				end = g.To
+			}
+			if limiter == nil && len(fetched) > 0 {
+				if err := sleepContext(ctx, 100*time.Millisecond); err != nil { // Be nice to the API
+					return fetched, err
+				}
+			}
+			batch, err := fetchKlinesFromBinance(ctx, apiBase, symbol, interval, start, end, limiter)
+			if err != nil {
+				return fetched, fmt.Errorf("error re-fetching gap %s: %w", g, err)
+			}
+			for _, c := range batch {
+				if c.Timestamp >= start && c.Timestamp <= end {
+					fetched = append(fetched, c)
+				}
+			}
+			start = end + step
+		}
+	}
+	return fetched, nil
+}
+
+// repairStore runs the integrity pass of UpdateStore. Gaps the store already knows to be
+// unrecoverable are not requested again. It returns the number of candles filled in.
+func repairStore(ctx context.Context, apiBase string, store CandleStore, symbol, interval string, limiter *RateLimiter) (int, error) {
+	intervalDuration := GapInterval(interval)
+	if intervalDuration == 0 {
+		return 0, nil // Months differ in length, gaps cannot be computed
+	}
+	gaps, err := store.Gaps(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error searching for gaps: %w", err)
+	}
+	known, err := store.KnownGaps(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var missing []Gap
+	for _, g := range gaps {
+		if !containsGap(known, g) {
+			missing = append(missing, g)
+		}
+	}
+	if len(missing) == 0 {
+		if len(gaps) != len(known) {
+			// Some known gaps were filled in after all, keep the report in sync
+			return 0, store.SetKnownGaps(ctx, gaps)
+		}
+		return 0, nil
+	}
+
+	fmt.Printf("Found %d gaps in %s %s, re-fetching the missing candles...\n", len(missing), symbol, interval)
+	fetched, fetchErr := fetchGaps(ctx, apiBase, symbol, interval, missing, limiter)
+	if err := store.Append(ctx, fetched); err != nil {
+		return 0, errors.Join(fmt.Errorf("error saving re-fetched candles: %w", err), fetchErr)
+	}
+	if fetchErr != nil {
+		// Not every gap was tried, so the remaining ones are not known to be unrecoverable
+		return len(fetched), fetchErr
+	}
+
+	fmt.Printf("Filled %d missing candles.\n", len(fetched))
+	remaining, err := store.Gaps(ctx)
+	if err != nil {
+		return len(fetched), fmt.Errorf("error searching for gaps: %w", err)
+	}
+	for _, g := range remaining {
+		if !containsGap(known, g) {
+			fmt.Printf("Unrecoverable gap in %s %s: %s (%d candles missing)\n", symbol, interval, g, g.Missing(intervalDuration))
+		}
+	}
+	return len(fetched), store.SetKnownGaps(ctx, remaining)
+}
+
+func containsGap(gaps []Gap, g Gap) bool {
+	for _, known := range gaps {
+		if known == g {
+			return true
+		}
+	}
+	return false
+}
+
+// GapReportPath returns the file listing the unrecoverable gaps of a history file,
+// e.g. data/ETHUSDT_15m_gaps.csv for data/ETHUSDT_15m.csv
+func GapReportPath(filePath string) string {
+	return strings.TrimSuffix(filePath, filepath.Ext(filePath)) + "_gaps.csv"
+}
+
+// ReadGapReport reads a gap report, a missing file means no known gaps
+func ReadGapReport(path string) ([]Gap, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gap report: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	if _, err := reader.Read(); err != nil { // Header
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read gap report header: %w", err)
+	}
+	var gaps []Gap
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return gaps, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gap report: %w", err)
+		}
+		from, fromErr := strconv.ParseInt(record[0], 10, 64)
+		to, toErr := strconv.ParseInt(record[1], 10, 64)
+		if err := errors.Join(fromErr, toErr); err != nil {
+			return nil, fmt.Errorf("invalid gap in %s: %w", path, err)
+		}
+		gaps = append(gaps, Gap{From: from, To: to})
+	}
+}
+
+// WriteGapReport replaces the gap report with gaps, or removes it when there are none
+func WriteGapReport(path string, gaps []Gap, interval time.Duration) error {
+	if len(gaps) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove gap report: %w", err)
+		}
+		return nil
+	}
+
+	var sb strings.Builder
+	writer := csv.NewWriter(&sb)
+	writer.Write([]string{"from", "to", "missing", "from_datetime", "to_datetime"})
+	for _, g := range gaps {
+		writer.Write([]string{
+			strconv.FormatInt(g.From, 10),
+			strconv.FormatInt(g.To, 10),
+			strconv.Itoa(g.Missing(interval)),
+			time.UnixMilli(g.From).UTC().Format("2006-01-02 15:04:05"),
+			time.UnixMilli(g.To).UTC().Format("2006-01-02 15:04:05"),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to encode gap report: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write gap report: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace gap report: %w", err)
+	}
+	return nil
+}
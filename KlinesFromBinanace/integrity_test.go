@@ -0,0 +1,135 @@
+package klinesfrombinance
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	hour := time.Hour.Milliseconds()
+	candles := hourlyCandles(firstHour, 6)
+	// Rows: 0, 1, 1, 3, 2, 5 -> hour 1 duplicated, hour 2 out of order, hour 4 missing
+	candles = []Candle{candles[0], candles[1], candles[1], candles[3], candles[2], candles[5]}
+
+	report := CheckIntegrity(candles, time.Hour)
+	if len(report.Duplicates) != 1 || report.Duplicates[0] != firstHour+hour {
+		t.Errorf("duplicates = %v", report.Duplicates)
+	}
+	if len(report.OutOfOrder) != 1 || report.OutOfOrder[0] != firstHour+2*hour {
+		t.Errorf("out of order = %v", report.OutOfOrder)
+	}
+	want := Gap{From: firstHour + 4*hour, To: firstHour + 4*hour}
+	if len(report.Gaps) != 1 || report.Gaps[0] != want {
+		t.Errorf("gaps = %v, want [%v]", report.Gaps, want)
+	}
+	if report.Clean() {
+		t.Error("report with problems reported as clean")
+	}
+	if !CheckIntegrity(hourlyCandles(firstHour, 10), time.Hour).Clean() {
+		t.Error("contiguous series reported problems")
+	}
+}
+
+// hourlyStandIn serves every requested hour except the ones in maintenance and the still open one
+func hourlyStandIn(t *testing.T, maintenance int64) (url string, requested func() [][2]int64) {
+	var mu sync.Mutex
+	var ranges [][2]int64
+	server, _ := newKlinesStandIn(t, func(call int, w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.ParseInt(r.URL.Query().Get("startTime"), 10, 64)
+		end, _ := strconv.ParseInt(r.URL.Query().Get("endTime"), 10, 64)
+		mu.Lock()
+		ranges = append(ranges, [2]int64{start, end})
+		mu.Unlock()
+
+		open := time.Now().UTC().Truncate(time.Hour).UnixMilli()
+		var rows []string
+		for ts := start; ts <= end && ts < open; ts += time.Hour.Milliseconds() {
+			if ts != maintenance {
+				rows = append(rows, strings.TrimSuffix(strings.TrimPrefix(klinesJSON(ts, 1), "["), "]"))
+			}
+		}
+		fmt.Fprint(w, "["+strings.Join(rows, ",")+"]")
+	})
+	return server.URL, func() [][2]int64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([][2]int64(nil), ranges...)
+	}
+}
+
+func TestUpdateRepairsGapsAndReportsUnrecoverableOnes(t *testing.T) {
+	shortRetries(t)
+	hour := time.Hour.Milliseconds()
+	base := time.Now().UTC().Truncate(time.Hour).UnixMilli() - 7*hour
+	maintenance := base + 4*hour
+	url, requested := hourlyStandIn(t, maintenance)
+
+	all := hourlyCandles(base, 7)
+	path := filepath.Join(t.TempDir(), "ETHUSDT_1h.csv")
+	if err := writeCandlesCSV(path, []Candle{all[0], all[1], all[2], all[5], all[6]}); err != nil {
+		t.Fatalf("writeCandlesCSV returned error: %v", err)
+	}
+
+	candles, err := updateCSV(url, path, nil, RejectBadRows)
+	if err != nil {
+		t.Fatalf("updateCSV returned error: %v", err)
+	}
+	if len(candles) != 6 {
+		t.Fatalf("got %d candles, want 6 (hour 3 repaired, hour 4 unrecoverable)", len(candles))
+	}
+	saved, _, err := parseCSV(path, ParseOptions{})
+	if err != nil || len(saved) != 6 {
+		t.Fatalf("parseCSV = %d candles, %v; want 6", len(saved), err)
+	}
+
+	gaps, err := ReadGapReport(GapReportPath(path))
+	if err != nil {
+		t.Fatalf("readGapReport returned error: %v", err)
+	}
+	if len(gaps) != 1 || gaps[0] != (Gap{From: maintenance, To: maintenance}) {
+		t.Fatalf("gap report = %v, want the maintenance hour", gaps)
+	}
+
+	// The known gap is not requested again on the next run
+	before := len(requested())
+	if _, err := updateCSV(url, path, nil, RejectBadRows); err != nil {
+		t.Fatalf("second updateCSV returned error: %v", err)
+	}
+	for _, r := range requested()[before:] {
+		if r[0] <= maintenance && maintenance <= r[1] {
+			t.Errorf("known gap requested again: %v", r)
+		}
+	}
+}
+
+func TestUpdateRewritesDuplicatesAndOutOfOrderRows(t *testing.T) {
+	hour := time.Hour.Milliseconds()
+	base := time.Now().UTC().Truncate(time.Hour).UnixMilli() - 3*hour
+	url, _ := hourlyStandIn(t, 0)
+
+	all := hourlyCandles(base, 3)
+	path := filepath.Join(t.TempDir(), "ETHUSDT_1h.csv")
+	if err := writeCandlesCSV(path, []Candle{all[1], all[0], all[1], all[2]}); err != nil {
+		t.Fatalf("writeCandlesCSV returned error: %v", err)
+	}
+	if _, err := updateCSV(url, path, nil, RejectBadRows); err != nil {
+		t.Fatalf("updateCSV returned error: %v", err)
+	}
+	saved, _, err := parseCSV(path, ParseOptions{})
+	if err != nil {
+		t.Fatalf("parseCSV returned error: %v", err)
+	}
+	if !CheckIntegrity(saved, time.Hour).Clean() || len(saved) != 3 {
+		t.Errorf("file not cleaned up: %+v", saved)
+	}
+	if _, err := os.Stat(GapReportPath(path)); !os.IsNotExist(err) {
+		t.Errorf("unexpected gap report: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package klinesfrombinance
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Request weight of one klines call (Binance charges 2 for any limit up to 1000)
+const klinesRequestWeight = 2
+
+// RateLimiter is a token bucket over Binance request weight. One limiter can be
+// shared by every downloader so that together they stay under the per-minute budget.
+type RateLimiter struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	perSec   float64
+	last     time.Time
+	// pausedUntil holds every Wait back after Binance answered 429 or 418
+	pausedUntil time.Time
+}
+
+// NewRateLimiter creates a limiter allowing weightPerMinute request weight per minute
+func NewRateLimiter(weightPerMinute int) *RateLimiter {
+	capacity := float64(weightPerMinute)
+	return &RateLimiter{
+		capacity: capacity,
+		tokens:   capacity,
+		perSec:   capacity / 60,
+		last:     time.Now(),
+	}
+}
+
+// Wait blocks until weight can be spent or ctx is cancelled
+func (l *RateLimiter) Wait(ctx context.Context, weight int) error {
+	if float64(weight) > l.capacity {
+		return fmt.Errorf("request weight %d exceeds the limiter capacity %.0f", weight, l.capacity)
+	}
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.refill(now)
+		var wait time.Duration
+		switch {
+		case now.Before(l.pausedUntil):
+			wait = l.pausedUntil.Sub(now)
+		case l.tokens >= float64(weight):
+			l.tokens -= float64(weight)
+			l.mu.Unlock()
+			return nil
+		default:
+			wait = time.Duration((float64(weight) - l.tokens) / l.perSec * float64(time.Second))
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Observe lowers the remaining budget to what Binance reports as unused in the current
+// minute (the X-MBX-USED-WEIGHT-1M header), which also counts other clients of the same IP
+func (l *RateLimiter) Observe(usedWeight int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(time.Now())
+	remaining := l.capacity - float64(usedWeight)
+	if remaining < 0 {
+		remaining = 0
+	}
+	if remaining < l.tokens {
+		l.tokens = remaining
+	}
+}
+
+// PauseUntil makes every Wait block until t, as requested by a Retry-After header
+func (l *RateLimiter) PauseUntil(t time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t.After(l.pausedUntil) {
+		l.pausedUntil = t
+	}
+}
+
+// refill adds the tokens earned since the last call, the caller must hold mu
+func (l *RateLimiter) refill(now time.Time) {
+	l.tokens += now.Sub(l.last).Seconds() * l.perSec
+	if l.tokens > l.capacity {
+		l.tokens = l.capacity
+	}
+	l.last = now
+}
@@ -0,0 +1,42 @@
+package klinesfrombinance
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterSpendsAndRefills(t *testing.T) {
+	limiter := NewRateLimiter(600) // 10 weight per second
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 300; i++ {
+		if err := limiter.Wait(ctx, 2); err != nil {
+			t.Fatalf("Wait returned error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("spending the initial budget took %s", elapsed)
+	}
+
+	// The bucket is empty: the next 2 weight take about 200ms to refill
+	start = time.Now()
+	if err := limiter.Wait(ctx, 2); err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Wait returned after %s, expected it to block for the refill", elapsed)
+	}
+}
+
+func TestRateLimiterHonorsContext(t *testing.T) {
+	limiter := NewRateLimiter(60)
+	limiter.Wait(context.Background(), 60)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx, 10); err == nil {
+		t.Error("expected Wait to fail once the context expired")
+	}
+}
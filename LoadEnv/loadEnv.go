@@ -1,187 +1,463 @@
 package loadenv
 
 import (
-	"log"
+	"errors"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
-// Global variables for strategy parameters
-var (
-	FAST_LENGTH             int
-	SLOW_LENGTH             int
-	SIGNAL_LENGTH           int
-	TREND_TF_HOURS          int
-	ENTRY_TF_MINUTES        int
-	STOP_LOSS_PCT           float64
-	TAKE_PROFIT_PCT         float64
-	TRAILING_STOP_PCT       float64
-	MAX_ALLOWED_SL_PCT      float64
-	MIN_MACD_STRENGTH       float64
-	REQUIRE_CONFIRMATION    bool
-	COMMISSION_PERCENT      float64
-	SLIPPAGE_POINTS         float64
-	MAX_POSITION_HOLD_HOURS int
-	OUTPUT_FILE_NAME        string
-	ENABLE_SHORT_TRADES     bool
-)
+// Config holds every setting of one bot instance
+type Config struct {
+	Strategy  StrategyConfig
+	Exchange  ExchangeConfig
+	Data      DataConfig
+	Execution ExecutionConfig
+	Risk      RiskConfig
+	Telegram  TelegramConfig
+	Notify    NotifyConfig
+	Digest    DigestConfig
+}
 
-// Global variables for Binance API and WebSocket constants
-var (
-	BINANCE_API_BASE   string
-	BINANCE_INTERVAL   string
-	SYMBOL             string
-	WEBSOCKET_URL      string
-	BINANCE_API_KEY    string
-	BINANCE_SECRET_KEY string
-)
+// StrategyConfig holds the strategy parameters
+type StrategyConfig struct {
+	Name                 string // Registered strategy selected by STRATEGY
+	FastLength           int
+	SlowLength           int
+	SignalLength         int
+	TrendTFHours         int
+	EntryTFMinutes       int
+	StopLossPct          float64
+	TakeProfitPct        float64
+	TrailingStopPct      float64
+	MaxAllowedSLPct      float64
+	SwingStopBars        int // Bars searched for the swing low or high placing the stop of an entry, 0 uses STOP_LOSS_PCT
+	MinMACDStrength      float64
+	RequireConfirmation  bool
+	CommissionPercent    float64
+	SlippagePoints       float64
+	MaxPositionHoldHours int
+	EnableShortTrades    bool
+	IntrabarPolicy       string // "stop_first", "target_first" or "nearest_first" when a candle hits both the stop and the target
+}
+
+// ExchangeConfig holds the Binance API and WebSocket settings
+type ExchangeConfig struct {
+	APIBase      string
+	Interval     string
+	Symbol       string
+	WebsocketURL string
+	APIKey       string
+	SecretKey    string
+	// WeightPerMinute is the request weight budget shared by all downloads
+	WeightPerMinute int
+}
 
-// Global variables for data paths and start date
-var (
-	START_DATE_STR string
-	DATA_FILE_PATH string
+// DataConfig holds the data paths and start date
+type DataConfig struct {
+	StartDateStr   string
 	StartDate      time.Time
-)
+	FilePath       string
+	OutputFileName string
+	SyncPairs      []string // "SYMBOL:interval" entries updated by the sync command
+	SyncWorkers    int
+	BadRowPolicy   string // "reject", "skip" or "quarantine" invalid rows of history files
+	Store          string // Candle storage backend: "csv", "sqlite" or "parquet"
+}
 
-// Optional: Telegram notification variables
-var (
-	TELEGRAM_BOT_TOKEN string
-	TELEGRAM_CHAT_ID   int64
-)
+// ExecutionConfig selects how orders are executed
+type ExecutionConfig struct {
+	TradingMode         string // "backtest", "paper" or "live"
+	PaperInitialBalance float64
+	PaperStateFile      string
+	CloseOnShutdown     bool // Sell the open position when the service stops
+}
+
+// RiskConfig holds the position sizing and the portfolio limits
+type RiskConfig struct {
+	Sizing               string // "fixed_quote", "fixed_fraction", "risk_per_trade", "volatility_target" or "kelly"
+	FixedQuote           float64
+	EquityFraction       float64
+	RiskPerTradePct      float64
+	ATRLength            int
+	ATRMultiple          float64
+	KellyCap             float64
+	KellyLookback        int
+	MaxPositions         int
+	MaxSymbolExposurePct float64
+	DailyLossLimitPct    float64
+	InitialEquity        float64 // Account size of backtests
+}
 
-// LoadEnv loads environment variables from a .env file and assigns them to global variables.
-func LoadEnv() {
-	// Attempt to load .env from the current directory, or one level up (where main.go might be)
-	err := godotenv.Load() // Loads from ./.env by default
+// TelegramConfig holds the optional Telegram notification settings
+type TelegramConfig struct {
+	BotToken string
+	ChatID   int64   // Chat the notifications go to, the first of ChatIDs
+	ChatIDs  []int64 // Chats whose commands the bot accepts
+
+	APIEndpoint     string // Base URL of the Bot API, a local stand-in in tests
+	ParseMode       string // "plain", "markdownv2" or "html" formatting of the alerts
+	CoalesceSeconds int    // Alerts within this many seconds are sent as one message, 0 sends each at once
+	MaxRetries      int    // Retries of a failed request before the message is given up
+}
+
+// NotifyConfig holds the notification settings besides Telegram. Every backend whose
+// destination is set receives the events.
+type NotifyConfig struct {
+	MinSeverity   string // "info", "warning" or "error"
+	QueueSize     int    // Events waiting per backend before new ones are dropped
+	WebhookURL    string
+	WebhookFormat string // "json", "slack" or "discord"
+	SMTPAddr      string // host:port of the mail server
+	SMTPUsername  string
+	SMTPPassword  string
+	EmailFrom     string
+	EmailTo       []string
+	File          string // JSONL file the events are appended to
+}
+
+// DigestConfig schedules the performance digests sent through the notifications
+type DigestConfig struct {
+	Period        string // "off", "daily" or "weekly"
+	Time          string // HH:MM in UTC
+	Weekday       string // Day of the weekly digest
+	Chart         bool   // Attach an equity chart to the Telegram digest
+	SampleMinutes int    // How often the equity is sampled for the chart and the drawdown
+}
+
+// Load reads the configuration from the .env file at path. An empty path looks for .env
+// in the current directory and then one level up (where main.go might be). Variables
+// already set in the process environment take precedence over the file. Every
+// validation problem is reported at once in the returned error.
+func Load(path string) (Config, error) {
+	values, err := readEnvFile(path)
 	if err != nil {
-		log.Printf("Could not load .env file from current directory, trying parent: %v", err)
-		err = godotenv.Load("../.env") // Try loading from parent directory
-		if err != nil {
-			log.Fatalf("Error loading .env file from current or parent directory: %v", err)
-		}
+		return Config{}, err
 	}
+	p := &parser{values: values}
+
+	var cfg Config
 
 	// Strategy Parameters
-	FAST_LENGTH = mustParseInt("FAST_LENGTH")
-	SLOW_LENGTH = mustParseInt("SLOW_LENGTH")
-	SIGNAL_LENGTH = mustParseInt("SIGNAL_LENGTH")
-	TREND_TF_HOURS = mustParseInt("TREND_TF_HOURS")
-	ENTRY_TF_MINUTES = mustParseInt("ENTRY_TF_MINUTES")
-	MAX_POSITION_HOLD_HOURS = mustParseInt("MAX_POSITION_HOLD_HOURS")
+	cfg.Strategy.Name = p.optionalString("STRATEGY", "macd")
+	cfg.Strategy.FastLength = p.requiredInt("FAST_LENGTH")
+	cfg.Strategy.SlowLength = p.requiredInt("SLOW_LENGTH")
+	cfg.Strategy.SignalLength = p.requiredInt("SIGNAL_LENGTH")
+	cfg.Strategy.TrendTFHours = p.requiredInt("TREND_TF_HOURS")
+	cfg.Strategy.EntryTFMinutes = p.requiredInt("ENTRY_TF_MINUTES")
+	cfg.Strategy.MaxPositionHoldHours = p.requiredInt("MAX_POSITION_HOLD_HOURS")
+
+	cfg.Strategy.StopLossPct = p.requiredFloat("STOP_LOSS_PCT")
+	cfg.Strategy.TakeProfitPct = p.requiredFloat("TAKE_PROFIT_PCT")
+	cfg.Strategy.TrailingStopPct = p.requiredFloat("TRAILING_STOP_PCT")
+	cfg.Strategy.MaxAllowedSLPct = p.requiredFloat("MAX_ALLOWED_SL_PCT")
+	cfg.Strategy.MinMACDStrength = p.requiredFloat("MIN_MACD_STRENGTH")
+	cfg.Strategy.SlippagePoints = p.requiredFloat("SLIPPAGE_POINTS")
+	cfg.Strategy.CommissionPercent = p.optionalFloat("COMMISSION_PERCENT", 0)
+	cfg.Strategy.EnableShortTrades = p.optionalBool("ENABLE_SHORT_TRADES", false)
+	cfg.Strategy.RequireConfirmation = p.optionalBool("REQUIRE_CONFIRMATION", false)
+	cfg.Strategy.IntrabarPolicy = p.optionalString("INTRABAR_POLICY", "stop_first")
+	cfg.Strategy.SwingStopBars = p.optionalInt("SWING_STOP_BARS", 0)
 
-	STOP_LOSS_PCT = mustParseFloat("STOP_LOSS_PCT")
-	TAKE_PROFIT_PCT = mustParseFloat("TAKE_PROFIT_PCT")
-	TRAILING_STOP_PCT = mustParseFloat("TRAILING_STOP_PCT")
-	MAX_ALLOWED_SL_PCT = mustParseFloat("MAX_ALLOWED_SL_PCT")
-	MIN_MACD_STRENGTH = mustParseFloat("MIN_MACD_STRENGTH")
-	SLIPPAGE_POINTS = mustParseFloat("SLIPPAGE_POINTS")
+	// Binance API and WebSocket Constants
+	cfg.Exchange.APIBase = p.requiredString("BINANCE_API_BASE")
+	cfg.Exchange.Interval = p.requiredString("BINANCE_INTERVAL")
+	cfg.Exchange.Symbol = p.requiredString("SYMBOL")
+	cfg.Exchange.WebsocketURL = p.requiredString("WEBSOCKET_URL")
+	cfg.Exchange.APIKey = p.get("BINANCE_API_KEY")
+	cfg.Exchange.SecretKey = p.get("BINANCE_SECRET_KEY")
+	cfg.Exchange.WeightPerMinute = p.optionalInt("BINANCE_WEIGHT_PER_MINUTE", 6000)
 
-	OUTPUT_FILE_NAME = os.Getenv("OUTPUT_FILE_NAME")
-	if OUTPUT_FILE_NAME == "" {
-		log.Fatal("OUTPUT_FILE_NAME not set in .env")
+	// Data paths and start date
+	cfg.Data.OutputFileName = p.requiredString("OUTPUT_FILE_NAME")
+	cfg.Data.StartDateStr = p.optionalString("START_DATE_STR", "2020-01-02 15:04:05")
+	cfg.Data.FilePath = p.optionalString("DATA_FILE_PATH", "data/ETHUSDC_15m.csv")
+	startDate, err := time.Parse("2006-01-02 15:04:05", cfg.Data.StartDateStr)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("invalid value for START_DATE_STR: %w", err))
 	}
+	cfg.Data.StartDate = startDate
+	cfg.Data.SyncPairs = p.optionalList("SYNC_PAIRS")
+	cfg.Data.SyncWorkers = p.optionalInt("SYNC_WORKERS", 4)
+	cfg.Data.BadRowPolicy = p.optionalString("BAD_ROW_POLICY", "reject")
+	cfg.Data.Store = p.optionalString("DATA_STORE", "csv")
 
-	// Binance API and WebSocket Constants
-	BINANCE_API_BASE = os.Getenv("BINANCE_API_BASE")
-	if BINANCE_API_BASE == "" {
-		log.Fatal("BINANCE_API_BASE not set in .env")
+	// Execution mode
+	cfg.Execution.TradingMode = p.optionalString("TRADING_MODE", "backtest")
+	cfg.Execution.PaperInitialBalance = p.optionalFloat("PAPER_INITIAL_BALANCE", 10000)
+	cfg.Execution.PaperStateFile = p.optionalString("PAPER_STATE_FILE", "data/paper_state.json")
+	cfg.Execution.CloseOnShutdown = p.optionalBool("CLOSE_POSITIONS_ON_SHUTDOWN", false)
+
+	// Position sizing and portfolio limits
+	cfg.Risk.Sizing = p.optionalString("POSITION_SIZING", "fixed_fraction")
+	cfg.Risk.FixedQuote = p.optionalFloat("SIZING_FIXED_QUOTE", 100)
+	cfg.Risk.EquityFraction = p.optionalFloat("SIZING_EQUITY_FRACTION", 1)
+	cfg.Risk.RiskPerTradePct = p.optionalFloat("RISK_PER_TRADE_PCT", 1)
+	cfg.Risk.ATRLength = p.optionalInt("SIZING_ATR_LENGTH", 14)
+	cfg.Risk.ATRMultiple = p.optionalFloat("SIZING_ATR_MULTIPLE", 2)
+	cfg.Risk.KellyCap = p.optionalFloat("KELLY_CAP", 0.25)
+	cfg.Risk.KellyLookback = p.optionalInt("KELLY_LOOKBACK", 50)
+	cfg.Risk.MaxPositions = p.optionalInt("MAX_CONCURRENT_POSITIONS", 0)
+	cfg.Risk.MaxSymbolExposurePct = p.optionalFloat("MAX_SYMBOL_EXPOSURE_PCT", 0)
+	cfg.Risk.DailyLossLimitPct = p.optionalFloat("DAILY_LOSS_LIMIT_PCT", 0)
+	cfg.Risk.InitialEquity = p.optionalFloat("BACKTEST_INITIAL_EQUITY", 10000)
+
+	// Telegram Notification (Optional)
+	cfg.Telegram.BotToken = p.get("TELEGRAM_BOT_TOKEN")
+	cfg.Telegram.ChatIDs = p.optionalInt64List("TELEGRAM_CHAT_ID")
+	if len(cfg.Telegram.ChatIDs) > 0 {
+		cfg.Telegram.ChatID = cfg.Telegram.ChatIDs[0]
 	}
-	BINANCE_INTERVAL = os.Getenv("BINANCE_INTERVAL")
-	if BINANCE_INTERVAL == "" {
-		log.Fatal("BINANCE_INTERVAL not set in .env")
+	cfg.Telegram.APIEndpoint = p.optionalString("TELEGRAM_API_ENDPOINT", "https://api.telegram.org")
+	cfg.Telegram.ParseMode = p.optionalString("TELEGRAM_PARSE_MODE", "html")
+	cfg.Telegram.CoalesceSeconds = p.optionalInt("TELEGRAM_COALESCE_SECONDS", 2)
+	cfg.Telegram.MaxRetries = p.optionalInt("TELEGRAM_MAX_RETRIES", 5)
+
+	// Notification backends (Optional)
+	cfg.Notify.MinSeverity = p.optionalString("NOTIFY_MIN_SEVERITY", "info")
+	cfg.Notify.QueueSize = p.optionalInt("NOTIFY_QUEUE_SIZE", 100)
+	cfg.Notify.WebhookURL = p.get("NOTIFY_WEBHOOK_URL")
+	cfg.Notify.WebhookFormat = p.optionalString("NOTIFY_WEBHOOK_FORMAT", "json")
+	cfg.Notify.SMTPAddr = p.get("NOTIFY_SMTP_ADDR")
+	cfg.Notify.SMTPUsername = p.get("NOTIFY_SMTP_USERNAME")
+	cfg.Notify.SMTPPassword = p.get("NOTIFY_SMTP_PASSWORD")
+	cfg.Notify.EmailFrom = p.get("NOTIFY_EMAIL_FROM")
+	cfg.Notify.EmailTo = p.optionalList("NOTIFY_EMAIL_TO")
+	cfg.Notify.File = p.get("NOTIFY_FILE")
+
+	// Performance digest (Optional)
+	cfg.Digest.Period = strings.ToLower(p.optionalString("DIGEST_PERIOD", "off"))
+	cfg.Digest.Time = p.optionalString("DIGEST_TIME", "00:00")
+	cfg.Digest.Weekday = p.optionalString("DIGEST_WEEKDAY", "monday")
+	cfg.Digest.Chart = p.optionalBool("DIGEST_CHART", false)
+	cfg.Digest.SampleMinutes = p.optionalInt("DIGEST_SAMPLE_MINUTES", 15)
+
+	p.errs = append(p.errs, cfg.validate()...)
+	if len(p.errs) > 0 {
+		return cfg, fmt.Errorf("invalid configuration: %w", errors.Join(p.errs...))
 	}
-	SYMBOL = os.Getenv("SYMBOL")
-	if SYMBOL == "" {
-		log.Fatal("SYMBOL not set in .env")
+	return cfg, nil
+}
+
+// validate checks the relations between settings that parsed successfully
+func (cfg Config) validate() []error {
+	var errs []error
+	if cfg.Strategy.CommissionPercent < 0 || cfg.Strategy.CommissionPercent >= 100 {
+		errs = append(errs, fmt.Errorf("invalid value for COMMISSION_PERCENT: %g (expected 0 <= value < 100)", cfg.Strategy.CommissionPercent))
 	}
-	WEBSOCKET_URL = os.Getenv("WEBSOCKET_URL")
-	if WEBSOCKET_URL == "" {
-		log.Fatal("WEBSOCKET_URL not set in .env")
+	if cfg.Strategy.SlippagePoints < 0 {
+		errs = append(errs, fmt.Errorf("invalid value for SLIPPAGE_POINTS: %g (must not be negative)", cfg.Strategy.SlippagePoints))
 	}
-
-	BINANCE_API_KEY = os.Getenv("BINANCE_API_KEY")
-	BINANCE_SECRET_KEY = os.Getenv("BINANCE_SECRET_KEY")
-
-	// Global variables for data paths and start date
-	START_DATE_STR = os.Getenv("START_DATE_STR")
-	if START_DATE_STR == "" {
-		START_DATE_STR = "2020-01-02 15:04:05" // Default if not set
+	if cfg.Strategy.SwingStopBars < 0 {
+		errs = append(errs, fmt.Errorf("invalid value for SWING_STOP_BARS: %d (must not be negative)", cfg.Strategy.SwingStopBars))
 	}
-	DATA_FILE_PATH = os.Getenv("DATA_FILE_PATH")
-	if DATA_FILE_PATH == "" {
-		DATA_FILE_PATH = "data/ETHUSDC_15m.csv" // Default if not set
+	if cfg.Exchange.WeightPerMinute <= 0 {
+		errs = append(errs, fmt.Errorf("invalid value for BINANCE_WEIGHT_PER_MINUTE: %d (must be positive)", cfg.Exchange.WeightPerMinute))
 	}
-	var parseErr error
-	StartDate, parseErr = time.Parse("2006-01-02 15:04:05", START_DATE_STR)
-
-	if parseErr != nil {
-		log.Fatalf("Error parsing START_DATE_STR from .env: %v", parseErr)
+	if cfg.Data.SyncWorkers <= 0 {
+		errs = append(errs, fmt.Errorf("invalid value for SYNC_WORKERS: %d (must be positive)", cfg.Data.SyncWorkers))
+	}
+	switch cfg.Data.BadRowPolicy {
+	case "reject", "skip", "quarantine":
+	default:
+		errs = append(errs, fmt.Errorf("invalid value for BAD_ROW_POLICY: %s (expected reject, skip or quarantine)", cfg.Data.BadRowPolicy))
+	}
+	switch cfg.Data.Store {
+	case "csv", "sqlite", "parquet":
+	default:
+		errs = append(errs, fmt.Errorf("invalid value for DATA_STORE: %s (expected csv, sqlite or parquet)", cfg.Data.Store))
+	}
+	if cfg.Notify.QueueSize <= 0 {
+		errs = append(errs, fmt.Errorf("invalid value for NOTIFY_QUEUE_SIZE: %d (must be positive)", cfg.Notify.QueueSize))
+	}
+	if cfg.Telegram.CoalesceSeconds < 0 {
+		errs = append(errs, fmt.Errorf("invalid value for TELEGRAM_COALESCE_SECONDS: %d (must not be negative)", cfg.Telegram.CoalesceSeconds))
+	}
+	if cfg.Telegram.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("invalid value for TELEGRAM_MAX_RETRIES: %d (must not be negative)", cfg.Telegram.MaxRetries))
+	}
+	switch cfg.Digest.Period {
+	case "off", "daily", "weekly":
+	default:
+		errs = append(errs, fmt.Errorf("invalid value for DIGEST_PERIOD: %s (expected off, daily or weekly)", cfg.Digest.Period))
+	}
+	if cfg.Digest.SampleMinutes <= 0 {
+		errs = append(errs, fmt.Errorf("invalid value for DIGEST_SAMPLE_MINUTES: %d (must be positive)", cfg.Digest.SampleMinutes))
+	}
+	if cfg.Risk.InitialEquity <= 0 {
+		errs = append(errs, fmt.Errorf("invalid value for BACKTEST_INITIAL_EQUITY: %g (must be positive)", cfg.Risk.InitialEquity))
+	}
+	switch cfg.Execution.TradingMode {
+	case "backtest", "paper":
+	case "live":
+		if cfg.Exchange.APIKey == "" || cfg.Exchange.SecretKey == "" {
+			errs = append(errs, errors.New("BINANCE_API_KEY and BINANCE_SECRET_KEY must be set for live trading"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid value for TRADING_MODE: %s (expected backtest, paper or live)", cfg.Execution.TradingMode))
 	}
+	return errs
+}
 
-	// Telegram Notification (Optional)
-	telegramChatIDStr := os.Getenv("TELEGRAM_CHAT_ID")
-	if telegramChatIDStr != "" {
-		var err error
-		TELEGRAM_CHAT_ID, err = strconv.ParseInt(telegramChatIDStr, 10, 64)
+// readEnvFile returns the variables of the .env file at path, or of ./.env or ../.env
+// when path is empty
+func readEnvFile(path string) (map[string]string, error) {
+	if path != "" {
+		values, err := godotenv.Read(path)
 		if err != nil {
-			log.Fatalf("Invalid value for TELEGRAM_CHAT_ID in .env: %v", err)
+			return nil, fmt.Errorf("error loading %s: %w", path, err)
 		}
+		return values, nil
+	}
+	values, err := godotenv.Read() // Reads ./.env by default
+	if err == nil {
+		return values, nil
+	}
+	values, parentErr := godotenv.Read("../.env") // Try the parent directory
+	if parentErr != nil {
+		return nil, fmt.Errorf("error loading .env file from current or parent directory: %w", errors.Join(err, parentErr))
+	}
+	return values, nil
+}
+
+// parser looks up variables and collects every parse error instead of stopping at the first
+type parser struct {
+	values map[string]string
+	errs   []error
+}
+
+// get returns a variable from the process environment, or from the .env file if it is not set there
+func (p *parser) get(key string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return p.values[key]
+}
+
+func (p *parser) requiredString(key string) string {
+	s := p.get(key)
+	if s == "" {
+		p.errs = append(p.errs, fmt.Errorf("%s not set in .env", key))
 	}
+	return s
+}
 
-	telegramBotToken := os.Getenv("TELEGRAM_BOT_TOKEN")
-	if telegramBotToken != "" {
-		TELEGRAM_BOT_TOKEN = telegramBotToken
+func (p *parser) optionalString(key, fallback string) string {
+	if s := p.get(key); s != "" {
+		return s
 	}
+	return fallback
 }
 
-// Helper functions to parse environment variables or fatal error
-func mustParseInt(key string) int {
-	s := os.Getenv(key)
+func (p *parser) requiredInt(key string) int {
+	s := p.requiredString(key)
 	if s == "" {
-		log.Fatalf("%s not set in .env", key)
+		return 0
 	}
 	v, err := strconv.Atoi(s)
 	if err != nil {
-		log.Fatalf("Invalid value for %s in .env: %v", key, err)
+		p.errs = append(p.errs, fmt.Errorf("invalid value for %s: %w", key, err))
 	}
 	return v
 }
 
-func mustParseInt64(key string) int64 {
-	s := os.Getenv(key)
+func (p *parser) optionalInt(key string, fallback int) int {
+	s := p.get(key)
 	if s == "" {
-		log.Fatalf("%s not set in .env", key)
+		return fallback
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("invalid value for %s: %w", key, err))
+	}
+	return v
+}
+
+// optionalList splits a comma separated variable, dropping empty entries
+func (p *parser) optionalList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(p.get(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func (p *parser) optionalInt64(key string, fallback int64) int64 {
+	s := p.get(key)
+	if s == "" {
+		return fallback
 	}
 	v, err := strconv.ParseInt(s, 10, 64)
 	if err != nil {
-		log.Fatalf("Invalid value for %s in .env: %v", key, err)
+		p.errs = append(p.errs, fmt.Errorf("invalid value for %s: %w", key, err))
 	}
 	return v
 }
 
-func mustParseFloat(key string) float64 {
-	s := os.Getenv(key)
+// optionalInt64List parses a comma separated list of integers
+func (p *parser) optionalInt64List(key string) []int64 {
+	var list []int64
+	for _, item := range p.optionalList(key) {
+		v, err := strconv.ParseInt(item, 10, 64)
+		if err != nil {
+			p.errs = append(p.errs, fmt.Errorf("invalid value for %s: %w", key, err))
+			continue
+		}
+		list = append(list, v)
+	}
+	return list
+}
+
+func (p *parser) requiredFloat(key string) float64 {
+	s := p.requiredString(key)
 	if s == "" {
-		log.Fatalf("%s not set in .env", key)
+		return 0
 	}
 	v, err := strconv.ParseFloat(s, 64)
 	if err != nil {
-		log.Fatalf("Invalid value for %s in .env: %v", key, err)
+		p.errs = append(p.errs, fmt.Errorf("invalid value for %s: %w", key, err))
+	}
+	return v
+}
+
+func (p *parser) optionalFloat(key string, fallback float64) float64 {
+	s := p.get(key)
+	if s == "" {
+		return fallback
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("invalid value for %s: %w", key, err))
+	}
+	return v
+}
+
+func (p *parser) optionalBool(key string, fallback bool) bool {
+	s := p.get(key)
+	if s == "" {
+		return fallback
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("invalid value for %s: %w", key, err))
 	}
 	return v
 }
 
-func mustParseBool(key string) bool {
-	s := os.Getenv(key)
+func (p *parser) requiredBool(key string) bool {
+	s := p.requiredString(key)
 	if s == "" {
-		log.Fatalf("%s not set in .env", key)
+		return false
 	}
 	v, err := strconv.ParseBool(s)
 	if err != nil {
-		log.Fatalf("Invalid value for %s in .env: %v", key, err)
+		p.errs = append(p.errs, fmt.Errorf("invalid value for %s: %w", key, err))
 	}
 	return v
 }
@@ -2,13 +2,16 @@ package loadenv
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
 
-func TestLoadEnv(t *testing.T) {
+func TestLoad(t *testing.T) {
 	// Create a temporary .env file for testing
-	envContent := `	FAST_LENGTH=12
+	envContent := `	STRATEGY=rsi
+					FAST_LENGTH=12
 					SLOW_LENGTH=26
 					SIGNAL_LENGTH=9
 					TREND_TF_HOURS=4
@@ -20,7 +23,11 @@ func TestLoadEnv(t *testing.T) {
 					MAX_ALLOWED_SL_PCT=3.0
 					MIN_MACD_STRENGTH=0.001
 					SLIPPAGE_POINTS=1.0
-					ENABLE_SHORT_TRADES=false
+					ENABLE_SHORT_TRADES=true
+					REQUIRE_CONFIRMATION=true
+					INTRABAR_POLICY=nearest_first
+					SWING_STOP_BARS=10
+					COMMISSION_PERCENT=0.1
 					OUTPUT_FILE_NAME=test_output.csv
 					BINANCE_API_BASE=https://api.binance.com
 					BINANCE_INTERVAL=15m
@@ -31,17 +38,34 @@ func TestLoadEnv(t *testing.T) {
 					START_DATE_STR=2020-01-01 00:00:00
 					DATA_FILE_PATH=test_data.csv
 					TELEGRAM_BOT_TOKEN=test_bot_token
-					TELEGRAM_CHAT_ID=123456789`
+					TELEGRAM_CHAT_ID=123456789, -100200300
+					TELEGRAM_PARSE_MODE=markdownv2
+					DIGEST_PERIOD=Weekly
+					CLOSE_POSITIONS_ON_SHUTDOWN=true
+					SYNC_PAIRS=ETHUSDT:15m, BTCUSDT:1h
+					SYNC_WORKERS=2
+					BAD_ROW_POLICY=quarantine
+					DATA_STORE=sqlite
+					POSITION_SIZING=risk_per_trade
+					RISK_PER_TRADE_PCT=0.5
+					MAX_CONCURRENT_POSITIONS=3
+					DAILY_LOSS_LIMIT_PCT=4
+					NOTIFY_WEBHOOK_URL=https://hooks.slack.com/services/T000/B000/XXX
+					NOTIFY_WEBHOOK_FORMAT=slack
+					NOTIFY_EMAIL_TO=ops@example.com, oncall@example.com`
 
 	// Write temporary .env file
-	err := os.WriteFile(".env", []byte(envContent), 0644)
+	path := filepath.Join(t.TempDir(), ".env")
+	err := os.WriteFile(path, []byte(envContent), 0644)
 	if err != nil {
 		t.Fatalf("Failed to create test .env file: %v", err)
 	}
-	defer os.Remove(".env") // Clean up after test
 
-	// Call LoadEnv
-	LoadEnv()
+	// Call Load
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
 
 	// Test integer values
 	tests := []struct {
@@ -49,12 +73,17 @@ func TestLoadEnv(t *testing.T) {
 		actual   int
 		expected int
 	}{
-		{"FAST_LENGTH", FAST_LENGTH, 12},
-		{"SLOW_LENGTH", SLOW_LENGTH, 26},
-		{"SIGNAL_LENGTH", SIGNAL_LENGTH, 9},
-		{"TREND_TF_HOURS", TREND_TF_HOURS, 4},
-		{"ENTRY_TF_MINUTES", ENTRY_TF_MINUTES, 15},
-		{"MAX_POSITION_HOLD_HOURS", MAX_POSITION_HOLD_HOURS, 24},
+		{"FAST_LENGTH", cfg.Strategy.FastLength, 12},
+		{"SLOW_LENGTH", cfg.Strategy.SlowLength, 26},
+		{"SIGNAL_LENGTH", cfg.Strategy.SignalLength, 9},
+		{"TREND_TF_HOURS", cfg.Strategy.TrendTFHours, 4},
+		{"ENTRY_TF_MINUTES", cfg.Strategy.EntryTFMinutes, 15},
+		{"MAX_POSITION_HOLD_HOURS", cfg.Strategy.MaxPositionHoldHours, 24},
+		{"SWING_STOP_BARS", cfg.Strategy.SwingStopBars, 10},
+		{"SYNC_WORKERS", cfg.Data.SyncWorkers, 2},
+		{"BINANCE_WEIGHT_PER_MINUTE", cfg.Exchange.WeightPerMinute, 6000},
+		{"MAX_CONCURRENT_POSITIONS", cfg.Risk.MaxPositions, 3},
+		{"SIZING_ATR_LENGTH", cfg.Risk.ATRLength, 14},
 	}
 
 	for _, tt := range tests {
@@ -71,12 +100,17 @@ func TestLoadEnv(t *testing.T) {
 		actual   float64
 		expected float64
 	}{
-		{"STOP_LOSS_PCT", STOP_LOSS_PCT, 2.5},
-		{"TAKE_PROFIT_PCT", TAKE_PROFIT_PCT, 5.0},
-		{"TRAILING_STOP_PCT", TRAILING_STOP_PCT, 1.5},
-		{"MAX_ALLOWED_SL_PCT", MAX_ALLOWED_SL_PCT, 3.0},
-		{"MIN_MACD_STRENGTH", MIN_MACD_STRENGTH, 0.001},
-		{"SLIPPAGE_POINTS", SLIPPAGE_POINTS, 1.0},
+		{"STOP_LOSS_PCT", cfg.Strategy.StopLossPct, 2.5},
+		{"TAKE_PROFIT_PCT", cfg.Strategy.TakeProfitPct, 5.0},
+		{"TRAILING_STOP_PCT", cfg.Strategy.TrailingStopPct, 1.5},
+		{"MAX_ALLOWED_SL_PCT", cfg.Strategy.MaxAllowedSLPct, 3.0},
+		{"MIN_MACD_STRENGTH", cfg.Strategy.MinMACDStrength, 0.001},
+		{"SLIPPAGE_POINTS", cfg.Strategy.SlippagePoints, 1.0},
+		{"COMMISSION_PERCENT", cfg.Strategy.CommissionPercent, 0.1},
+		{"RISK_PER_TRADE_PCT", cfg.Risk.RiskPerTradePct, 0.5},
+		{"DAILY_LOSS_LIMIT_PCT", cfg.Risk.DailyLossLimitPct, 4},
+		{"SIZING_EQUITY_FRACTION", cfg.Risk.EquityFraction, 1},
+		{"BACKTEST_INITIAL_EQUITY", cfg.Risk.InitialEquity, 10000},
 	}
 
 	for _, tt := range floatTests {
@@ -93,16 +127,28 @@ func TestLoadEnv(t *testing.T) {
 		actual   string
 		expected string
 	}{
-		{"OUTPUT_FILE_NAME", OUTPUT_FILE_NAME, "test_output.csv"},
-		{"BINANCE_API_BASE", BINANCE_API_BASE, "https://api.binance.com"},
-		{"BINANCE_INTERVAL", BINANCE_INTERVAL, "15m"},
-		{"SYMBOL", SYMBOL, "ETHUSDT"},
-		{"WEBSOCKET_URL", WEBSOCKET_URL, "wss://stream.binance.com:9443/ws/ethusdt@kline_15m"},
-		{"BINANCE_API_KEY", BINANCE_API_KEY, "test_api_key"},
-		{"BINANCE_SECRET_KEY", BINANCE_SECRET_KEY, "test_secret_key"},
-		{"START_DATE_STR", START_DATE_STR, "2020-01-01 00:00:00"},
-		{"DATA_FILE_PATH", DATA_FILE_PATH, "test_data.csv"},
-		{"TELEGRAM_BOT_TOKEN", TELEGRAM_BOT_TOKEN, "test_bot_token"},
+		{"STRATEGY", cfg.Strategy.Name, "rsi"},
+		{"INTRABAR_POLICY", cfg.Strategy.IntrabarPolicy, "nearest_first"},
+		{"OUTPUT_FILE_NAME", cfg.Data.OutputFileName, "test_output.csv"},
+		{"BINANCE_API_BASE", cfg.Exchange.APIBase, "https://api.binance.com"},
+		{"BINANCE_INTERVAL", cfg.Exchange.Interval, "15m"},
+		{"SYMBOL", cfg.Exchange.Symbol, "ETHUSDT"},
+		{"WEBSOCKET_URL", cfg.Exchange.WebsocketURL, "wss://stream.binance.com:9443/ws/ethusdt@kline_15m"},
+		{"BINANCE_API_KEY", cfg.Exchange.APIKey, "test_api_key"},
+		{"BINANCE_SECRET_KEY", cfg.Exchange.SecretKey, "test_secret_key"},
+		{"START_DATE_STR", cfg.Data.StartDateStr, "2020-01-01 00:00:00"},
+		{"DATA_FILE_PATH", cfg.Data.FilePath, "test_data.csv"},
+		{"BAD_ROW_POLICY", cfg.Data.BadRowPolicy, "quarantine"},
+		{"DATA_STORE", cfg.Data.Store, "sqlite"},
+		{"POSITION_SIZING", cfg.Risk.Sizing, "risk_per_trade"},
+		{"NOTIFY_WEBHOOK_URL", cfg.Notify.WebhookURL, "https://hooks.slack.com/services/T000/B000/XXX"},
+		{"NOTIFY_WEBHOOK_FORMAT", cfg.Notify.WebhookFormat, "slack"},
+		{"NOTIFY_MIN_SEVERITY", cfg.Notify.MinSeverity, "info"},
+		{"TELEGRAM_BOT_TOKEN", cfg.Telegram.BotToken, "test_bot_token"},
+		{"TELEGRAM_PARSE_MODE", cfg.Telegram.ParseMode, "markdownv2"},
+		{"DIGEST_PERIOD", cfg.Digest.Period, "weekly"},
+		{"DIGEST_TIME", cfg.Digest.Time, "00:00"},
+		{"TELEGRAM_API_ENDPOINT", cfg.Telegram.APIEndpoint, "https://api.telegram.org"},
 	}
 
 	for _, tt := range stringTests {
@@ -113,64 +159,104 @@ func TestLoadEnv(t *testing.T) {
 		})
 	}
 
+	// Test list values
+	if len(cfg.Data.SyncPairs) != 2 || cfg.Data.SyncPairs[0] != "ETHUSDT:15m" || cfg.Data.SyncPairs[1] != "BTCUSDT:1h" {
+		t.Errorf("SYNC_PAIRS = %v, want [ETHUSDT:15m BTCUSDT:1h]", cfg.Data.SyncPairs)
+	}
+
+	if len(cfg.Notify.EmailTo) != 2 || cfg.Notify.EmailTo[1] != "oncall@example.com" {
+		t.Errorf("NOTIFY_EMAIL_TO = %v, want [ops@example.com oncall@example.com]", cfg.Notify.EmailTo)
+	}
+
+	// Test boolean values
+	if !cfg.Strategy.EnableShortTrades {
+		t.Error("ENABLE_SHORT_TRADES = false, want true")
+	}
+	if !cfg.Strategy.RequireConfirmation {
+		t.Error("REQUIRE_CONFIRMATION = false, want true")
+	}
+	if !cfg.Execution.CloseOnShutdown {
+		t.Error("CLOSE_POSITIONS_ON_SHUTDOWN = false, want true")
+	}
+
 	// Test parsed date
 	expectedDate, _ := time.Parse("2006-01-02 15:04:05", "2020-01-01 00:00:00")
-	if !StartDate.Equal(expectedDate) {
-		t.Errorf("StartDate = %v, want %v", StartDate, expectedDate)
+	if !cfg.Data.StartDate.Equal(expectedDate) {
+		t.Errorf("StartDate = %v, want %v", cfg.Data.StartDate, expectedDate)
 	}
 
 	// Test Telegram Chat ID
-	if TELEGRAM_CHAT_ID != 123456789 {
-		t.Errorf("TELEGRAM_CHAT_ID = %d, want 123456789", TELEGRAM_CHAT_ID)
+	if cfg.Telegram.ChatID != 123456789 || len(cfg.Telegram.ChatIDs) != 2 || cfg.Telegram.ChatIDs[1] != -100200300 {
+		t.Errorf("TELEGRAM_CHAT_ID = %d %v, want 123456789 and [123456789 -100200300]", cfg.Telegram.ChatID, cfg.Telegram.ChatIDs)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), ".env"))
+	if err == nil {
+		t.Error("expected an error for a missing .env file")
 	}
 }
 
-func TestLoadEnvMissingFile(t *testing.T) {
-	// Ensure no .env file exists
-	os.Remove(".env")
-	os.Remove("../.env")
+func TestLoadReportsEveryError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	err := os.WriteFile(path, []byte("FAST_LENGTH=abc\nSTART_DATE_STR=yesterday\nTRADING_MODE=moon\nENABLE_SHORT_TRADES=maybe\nCOMMISSION_PERCENT=-1\nBAD_ROW_POLICY=ignore\n"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test .env file: %v", err)
+	}
 
-	// This should cause a fatal error, but we can't easily test that
-	// In a real scenario, you might want to modify LoadEnv to return an error
-	// instead of calling log.Fatal for better testability
+	_, err = Load(path)
+	if err == nil {
+		t.Fatal("expected an error for an invalid configuration")
+	}
+	for _, want := range []string{"FAST_LENGTH", "SLOW_LENGTH not set", "SYMBOL not set", "START_DATE_STR", "TRADING_MODE", "ENABLE_SHORT_TRADES", "COMMISSION_PERCENT", "BAD_ROW_POLICY"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s: %v", want, err)
+		}
+	}
+}
+
+func TestParseIntValid(t *testing.T) {
+	p := &parser{values: map[string]string{"TEST_INT": "42"}}
+
+	result := p.requiredInt("TEST_INT")
+	if result != 42 || len(p.errs) != 0 {
+		t.Errorf("requiredInt = %d (errors %v), want 42", result, p.errs)
+	}
 }
 
-func TestMustParseIntValid(t *testing.T) {
-	os.Setenv("TEST_INT", "42")
-	defer os.Unsetenv("TEST_INT")
+func TestParseFloatValid(t *testing.T) {
+	p := &parser{values: map[string]string{"TEST_FLOAT": "3.14"}}
 
-	result := mustParseInt("TEST_INT")
-	if result != 42 {
-		t.Errorf("mustParseInt = %d, want 42", result)
+	result := p.requiredFloat("TEST_FLOAT")
+	if result != 3.14 || len(p.errs) != 0 {
+		t.Errorf("requiredFloat = %f (errors %v), want 3.14", result, p.errs)
 	}
 }
 
-func TestMustParseFloatValid(t *testing.T) {
-	os.Setenv("TEST_FLOAT", "3.14")
-	defer os.Unsetenv("TEST_FLOAT")
+func TestParseBoolValid(t *testing.T) {
+	p := &parser{values: map[string]string{"TEST_BOOL": "true"}}
 
-	result := mustParseFloat("TEST_FLOAT")
-	if result != 3.14 {
-		t.Errorf("mustParseFloat = %f, want 3.14", result)
+	result := p.requiredBool("TEST_BOOL")
+	if result != true || len(p.errs) != 0 {
+		t.Errorf("requiredBool = %t (errors %v), want true", result, p.errs)
 	}
 }
 
-func TestMustParseBoolValid(t *testing.T) {
-	os.Setenv("TEST_BOOL", "true")
-	defer os.Unsetenv("TEST_BOOL")
+func TestParseInt64Valid(t *testing.T) {
+	p := &parser{values: map[string]string{"TEST_INT64": "9223372036854775807"}}
 
-	result := mustParseBool("TEST_BOOL")
-	if result != true {
-		t.Errorf("mustParseBool = %t, want true", result)
+	result := p.optionalInt64("TEST_INT64", 0)
+	if result != 9223372036854775807 || len(p.errs) != 0 {
+		t.Errorf("optionalInt64 = %d (errors %v), want 9223372036854775807", result, p.errs)
 	}
 }
 
-func TestMustParseInt64Valid(t *testing.T) {
-	os.Setenv("TEST_INT64", "9223372036854775807")
-	defer os.Unsetenv("TEST_INT64")
+func TestProcessEnvironmentOverridesFile(t *testing.T) {
+	t.Setenv("TEST_STRING", "from-env")
+	p := &parser{values: map[string]string{"TEST_STRING": "from-file"}}
 
-	result := mustParseInt64("TEST_INT64")
-	if result != 9223372036854775807 {
-		t.Errorf("mustParseInt64 = %d, want 9223372036854775807", result)
+	if got := p.requiredString("TEST_STRING"); got != "from-env" {
+		t.Errorf("requiredString = %s, want from-env", got)
 	}
 }
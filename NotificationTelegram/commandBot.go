@@ -0,0 +1,278 @@
+package sendtelegramnotification
+
+import (
+	"context"
+	"fmt"
+	execution "learnGoLang/Execution"
+	loadenv "learnGoLang/LoadEnv"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// confirmTimeout is how long a confirmation keyboard can be answered
+const confirmTimeout = time.Minute
+
+// Callback data of the confirmation buttons
+const (
+	confirmCloseAll = "closeall"
+	cancelAction    = "cancel"
+)
+
+// Controller is the running trader as the commands see it, implemented by execution.Trader
+type Controller interface {
+	Status(ctx context.Context) (execution.Status, error)
+	Pause()
+	Resume()
+	CloseAll(ctx context.Context) (execution.Order, error)
+}
+
+// botAPI is the part of the Bot API the command bot uses, implemented by tgbotapi.BotAPI
+type botAPI interface {
+	GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel
+	StopReceivingUpdates()
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+}
+
+// confirmation is a destructive action waiting for its button to be pressed
+type confirmation struct {
+	action  string
+	expires time.Time
+}
+
+// confirmationKey identifies the message holding the confirmation keyboard
+type confirmationKey struct {
+	chatID    int64
+	messageID int
+}
+
+// CommandBot answers the Telegram commands that monitor and control a running trader.
+// Only the chats listed in TELEGRAM_CHAT_ID are answered, and /closeall has to be
+// confirmed with an inline keyboard button.
+type CommandBot struct {
+	api      botAPI
+	control  Controller
+	allowed  map[int64]bool
+	settings string // Reply to /config
+
+	mu            sync.Mutex
+	confirmations map[confirmationKey]confirmation
+}
+
+// NewCommandBot connects to the Bot API once, the connection is shared by every reply
+func NewCommandBot(cfg loadenv.Config, control Controller) (*CommandBot, error) {
+	if cfg.Telegram.BotToken == "" || len(cfg.Telegram.ChatIDs) == 0 {
+		return nil, fmt.Errorf("TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID must be set")
+	}
+	api, err := tgbotapi.NewBotAPIWithAPIEndpoint(cfg.Telegram.BotToken, apiEndpoint(cfg.Telegram)+"/bot%s/%s")
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Telegram: %w", err)
+	}
+	log.Printf("Telegram bot @%s is listening for commands", api.Self.UserName)
+	return newCommandBot(api, cfg, control), nil
+}
+
+func newCommandBot(api botAPI, cfg loadenv.Config, control Controller) *CommandBot {
+	allowed := make(map[int64]bool, len(cfg.Telegram.ChatIDs))
+	for _, id := range cfg.Telegram.ChatIDs {
+		allowed[id] = true
+	}
+	return &CommandBot{
+		api:           api,
+		control:       control,
+		allowed:       allowed,
+		settings:      describeConfig(cfg),
+		confirmations: make(map[confirmationKey]confirmation),
+	}
+}
+
+// Run answers the commands until ctx is cancelled
+func (b *CommandBot) Run(ctx context.Context) {
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 30
+	updates := b.api.GetUpdatesChan(u)
+	defer b.api.StopReceivingUpdates()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			b.handle(ctx, update)
+		}
+	}
+}
+
+// handle answers one update
+func (b *CommandBot) handle(ctx context.Context, update tgbotapi.Update) {
+	if q := update.CallbackQuery; q != nil && q.Message != nil {
+		b.handleCallback(ctx, q)
+		return
+	}
+	msg := update.Message
+	if msg == nil || msg.Chat == nil || !msg.IsCommand() {
+		return
+	}
+	chatID := msg.Chat.ID
+	if !b.allowed[chatID] {
+		log.Printf("Ignoring Telegram command /%s from chat %d, it is not in TELEGRAM_CHAT_ID", msg.Command(), chatID)
+		return
+	}
+
+	log.Printf("Telegram command /%s from chat %d", msg.Command(), chatID)
+	switch msg.Command() {
+	case "status":
+		b.replyWithStatus(ctx, chatID, formatStatus)
+	case "positions":
+		b.replyWithStatus(ctx, chatID, formatPositions)
+	case "pnl":
+		b.replyWithStatus(ctx, chatID, formatPnL)
+	case "pause":
+		b.control.Pause()
+		b.reply(chatID, "Trading paused: no new entries, open positions are still managed. /resume to continue.")
+	case "resume":
+		b.control.Resume()
+		b.reply(chatID, "Trading resumed.")
+	case "closeall":
+		b.askConfirmation(chatID, confirmCloseAll, "Close every position at market and pause trading?")
+	case "config":
+		b.reply(chatID, b.settings)
+	default:
+		b.reply(chatID, "Commands: /status, /positions, /pnl, /pause, /resume, /closeall, /config")
+	}
+}
+
+// replyWithStatus answers with the trader status written by format
+func (b *CommandBot) replyWithStatus(ctx context.Context, chatID int64, format func(execution.Status) string) {
+	status, err := b.control.Status(ctx)
+	if err != nil {
+		b.reply(chatID, fmt.Sprintf("Error reading status: %v", err))
+		return
+	}
+	b.reply(chatID, format(status))
+}
+
+// askConfirmation sends a question with Confirm and Cancel buttons and remembers the
+// action until the buttons expire
+func (b *CommandBot) askConfirmation(chatID int64, action, question string) {
+	msg := tgbotapi.NewMessage(chatID, question)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Confirm", action),
+		tgbotapi.NewInlineKeyboardButtonData("Cancel", cancelAction),
+	))
+	sent, err := b.api.Send(msg)
+	if err != nil {
+		log.Printf("Error sending Telegram message: %v", err)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.confirmations[confirmationKey{chatID, sent.MessageID}] = confirmation{action: action, expires: time.Now().Add(confirmTimeout)}
+}
+
+// handleCallback runs or cancels the action of a pressed confirmation button. Every
+// keyboard can be used once.
+func (b *CommandBot) handleCallback(ctx context.Context, q *tgbotapi.CallbackQuery) {
+	chatID, messageID := q.Message.Chat.ID, q.Message.MessageID
+	if !b.allowed[chatID] {
+		log.Printf("Ignoring Telegram button from chat %d, it is not in TELEGRAM_CHAT_ID", chatID)
+		return
+	}
+	if _, err := b.api.Request(tgbotapi.NewCallback(q.ID, "")); err != nil {
+		log.Printf("Error answering Telegram button: %v", err)
+	}
+
+	key := confirmationKey{chatID, messageID}
+	b.mu.Lock()
+	pending, ok := b.confirmations[key]
+	delete(b.confirmations, key)
+	b.mu.Unlock()
+
+	switch {
+	case !ok || time.Now().After(pending.expires):
+		b.edit(chatID, messageID, "This confirmation expired, send the command again.")
+	case q.Data == cancelAction:
+		b.edit(chatID, messageID, "Cancelled.")
+	case q.Data == pending.action && pending.action == confirmCloseAll:
+		log.Printf("Closing every position, confirmed from Telegram chat %d", chatID)
+		order, err := b.control.CloseAll(ctx)
+		switch {
+		case err != nil:
+			b.edit(chatID, messageID, fmt.Sprintf("Error closing positions: %v", err))
+		case order.ID == "":
+			b.edit(chatID, messageID, "Trading paused, there was no position to close.")
+		default:
+			b.edit(chatID, messageID, fmt.Sprintf("Trading paused. Sell order %s of %.6f %s: %s %s",
+				order.ID, order.Quantity, order.Symbol, order.Status, order.Reason))
+		}
+	default:
+		b.edit(chatID, messageID, "Unknown confirmation.")
+	}
+}
+
+// reply sends a plain text message
+func (b *CommandBot) reply(chatID int64, text string) {
+	if _, err := b.api.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		log.Printf("Error sending Telegram message: %v", err)
+	}
+}
+
+// edit replaces the text of a message, which also removes its keyboard
+func (b *CommandBot) edit(chatID int64, messageID int, text string) {
+	if _, err := b.api.Send(tgbotapi.NewEditMessageText(chatID, messageID, text)); err != nil {
+		log.Printf("Error editing Telegram message: %v", err)
+	}
+}
+
+func formatStatus(s execution.Status) string {
+	state := "running"
+	if s.Paused {
+		state = "paused"
+	}
+	lines := []string{
+		fmt.Sprintf("%s %s: %s", s.Strategy, s.Symbol, state),
+		fmt.Sprintf("Balance %.2f, equity %.2f", s.Balance, s.Equity),
+	}
+	if s.LastPrice > 0 {
+		lines = append(lines, fmt.Sprintf("Last price %.2f at %s UTC", s.LastPrice, s.LastCandle.UTC().Format("2006-01-02 15:04")))
+	}
+	lines = append(lines, fmt.Sprintf("%d position(s), %d order(s) waiting for a fill", len(s.Positions), s.PendingOrders))
+	return strings.Join(lines, "\n")
+}
+
+func formatPositions(s execution.Status) string {
+	if len(s.Positions) == 0 {
+		return "No open positions."
+	}
+	lines := make([]string, len(s.Positions))
+	for i, p := range s.Positions {
+		lines[i] = fmt.Sprintf("%s %.6f @ %.2f since %s UTC", p.Symbol, p.Quantity, p.EntryPrice, p.OpenedAt.UTC().Format("2006-01-02 15:04"))
+		if p.Symbol == s.Symbol && s.LastPrice > 0 {
+			lines[i] += fmt.Sprintf(", now %.2f (%+.2f)", s.LastPrice, s.UnrealizedPnL)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatPnL(s execution.Status) string {
+	return fmt.Sprintf("Realized %+.2f, unrealized %+.2f, total %+.2f", s.RealizedPnL, s.UnrealizedPnL, s.RealizedPnL+s.UnrealizedPnL)
+}
+
+// describeConfig writes the trading settings for /config, leaving out the API keys and
+// the bot token
+func describeConfig(cfg loadenv.Config) string {
+	s, r := cfg.Strategy, cfg.Risk
+	return strings.Join([]string{
+		fmt.Sprintf("Mode %s, %s %s", cfg.Execution.TradingMode, cfg.Exchange.Symbol, cfg.Exchange.Interval),
+		fmt.Sprintf("Strategy %s: fast %d, slow %d, signal %d, entry %dm, trend %dh", s.Name, s.FastLength, s.SlowLength, s.SignalLength, s.EntryTFMinutes, s.TrendTFHours),
+		fmt.Sprintf("Stop %.2f%%, target %.2f%%, trailing %.2f%%, max stop %.2f%%, max hold %dh", s.StopLossPct, s.TakeProfitPct, s.TrailingStopPct, s.MaxAllowedSLPct, s.MaxPositionHoldHours),
+		fmt.Sprintf("Sizing %s, max positions %d, max exposure %.2f%%, daily loss limit %.2f%%", r.Sizing, r.MaxPositions, r.MaxSymbolExposurePct, r.DailyLossLimitPct),
+	}, "\n")
+}
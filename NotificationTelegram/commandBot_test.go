@@ -0,0 +1,174 @@
+package sendtelegramnotification
+
+import (
+	"context"
+	execution "learnGoLang/Execution"
+	loadenv "learnGoLang/LoadEnv"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeAPI records what the bot sends instead of calling Telegram
+type fakeAPI struct {
+	sent     []tgbotapi.Chattable
+	answered int
+}
+
+func (f *fakeAPI) GetUpdatesChan(tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel { return nil }
+
+func (f *fakeAPI) StopReceivingUpdates() {}
+
+func (f *fakeAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	f.sent = append(f.sent, c)
+	return tgbotapi.Message{MessageID: len(f.sent)}, nil
+}
+
+func (f *fakeAPI) Request(tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	f.answered++
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+// lastText returns the text of the last message sent or edited
+func (f *fakeAPI) lastText() string {
+	switch m := f.sent[len(f.sent)-1].(type) {
+	case tgbotapi.MessageConfig:
+		return m.Text
+	case tgbotapi.EditMessageTextConfig:
+		return m.Text
+	}
+	return ""
+}
+
+// fakeController counts the control calls
+type fakeController struct {
+	paused bool
+	closed int
+}
+
+func (c *fakeController) Status(context.Context) (execution.Status, error) {
+	return execution.Status{
+		Symbol:        "ETHUSDT",
+		Strategy:      "macd",
+		Paused:        c.paused,
+		Balance:       5000,
+		Equity:        8100,
+		Positions:     []execution.Position{{Symbol: "ETHUSDT", Quantity: 1, EntryPrice: 3000, OpenedAt: time.Date(2025, time.January, 17, 10, 0, 0, 0, time.UTC)}},
+		LastPrice:     3100,
+		RealizedPnL:   -20,
+		UnrealizedPnL: 100,
+	}, nil
+}
+
+func (c *fakeController) Pause()  { c.paused = true }
+func (c *fakeController) Resume() { c.paused = false }
+
+func (c *fakeController) CloseAll(context.Context) (execution.Order, error) {
+	c.closed++
+	c.paused = true
+	return execution.Order{ID: "paper-2", Symbol: "ETHUSDT", Quantity: 1, Status: execution.StatusNew}, nil
+}
+
+const allowedChat = 42
+
+func newTestBot() (*CommandBot, *fakeAPI, *fakeController) {
+	api, control := &fakeAPI{}, &fakeController{}
+	cfg := loadenv.Config{Telegram: loadenv.TelegramConfig{BotToken: "secret-token", ChatIDs: []int64{allowedChat}}}
+	return newCommandBot(api, cfg, control), api, control
+}
+
+// command builds an update carrying a bot command from a chat
+func command(chatID int64, text string) tgbotapi.Update {
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		Chat:     &tgbotapi.Chat{ID: chatID},
+		Text:     text,
+		Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(text)}},
+	}}
+}
+
+// press builds an update of a pressed inline keyboard button
+func press(chatID int64, messageID int, data string) tgbotapi.Update {
+	return tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "callback",
+		Data:    data,
+		Message: &tgbotapi.Message{MessageID: messageID, Chat: &tgbotapi.Chat{ID: chatID}},
+	}}
+}
+
+func TestCommandsAreOnlyAcceptedFromWhitelistedChats(t *testing.T) {
+	bot, api, control := newTestBot()
+	ctx := context.Background()
+
+	bot.handle(ctx, command(7, "/pause"))
+	bot.handle(ctx, command(7, "/status"))
+	if control.paused || len(api.sent) != 0 {
+		t.Fatalf("answered a chat that is not whitelisted: paused %t, sent %d", control.paused, len(api.sent))
+	}
+
+	bot.handle(ctx, command(allowedChat, "/pause"))
+	if !control.paused {
+		t.Error("/pause did not pause trading")
+	}
+	bot.handle(ctx, command(allowedChat, "/resume"))
+	if control.paused {
+		t.Error("/resume did not resume trading")
+	}
+}
+
+func TestStatusCommands(t *testing.T) {
+	bot, api, _ := newTestBot()
+	ctx := context.Background()
+	for _, tt := range []struct {
+		command string
+		want    string
+	}{
+		{"/status", "Balance 5000.00, equity 8100.00"},
+		{"/positions", "ETHUSDT 1.000000 @ 3000.00 since 2025-01-17 10:00 UTC, now 3100.00 (+100.00)"},
+		{"/pnl", "Realized -20.00, unrealized +100.00, total +80.00"},
+		{"/config@robot", "Strategy"},
+		{"/help", "/closeall"},
+	} {
+		bot.handle(ctx, command(allowedChat, tt.command))
+		if got := api.lastText(); !strings.Contains(got, tt.want) {
+			t.Errorf("%s replied %q, want it to contain %q", tt.command, got, tt.want)
+		}
+	}
+	if strings.Contains(bot.settings, "secret-token") {
+		t.Error("/config shows the bot token")
+	}
+}
+
+func TestCloseAllNeedsConfirmation(t *testing.T) {
+	bot, api, control := newTestBot()
+	ctx := context.Background()
+
+	bot.handle(ctx, command(allowedChat, "/closeall"))
+	question := len(api.sent)
+	if control.closed != 0 || api.sent[0].(tgbotapi.MessageConfig).ReplyMarkup == nil {
+		t.Fatalf("/closeall closed %d times without asking first", control.closed)
+	}
+
+	bot.handle(ctx, press(7, question, confirmCloseAll))
+	bot.handle(ctx, press(allowedChat, question+1, confirmCloseAll))
+	if control.closed != 0 {
+		t.Fatal("closed from another chat or an unknown keyboard")
+	}
+
+	bot.handle(ctx, press(allowedChat, question, confirmCloseAll))
+	if control.closed != 1 || !strings.Contains(api.lastText(), "Sell order paper-2") {
+		t.Errorf("confirmation closed %d times and replied %q", control.closed, api.lastText())
+	}
+	// A keyboard works once
+	bot.handle(ctx, press(allowedChat, question, confirmCloseAll))
+	if control.closed != 1 || !strings.Contains(api.lastText(), "expired") {
+		t.Errorf("the keyboard was used twice: closed %d times", control.closed)
+	}
+
+	bot.handle(ctx, command(allowedChat, "/closeall"))
+	bot.handle(ctx, press(allowedChat, len(api.sent), cancelAction))
+	if control.closed != 1 || api.lastText() != "Cancelled." {
+		t.Errorf("cancel closed %d times and replied %q", control.closed, api.lastText())
+	}
+}
@@ -0,0 +1,111 @@
+package sendtelegramnotification
+
+import (
+	"fmt"
+	"html"
+	notifier "learnGoLang/Notifier"
+	"strings"
+	"text/template"
+)
+
+// ParseMode is the Telegram formatting of a message
+type ParseMode string
+
+const (
+	PlainText  ParseMode = ""
+	MarkdownV2 ParseMode = "MarkdownV2"
+	HTML       ParseMode = "HTML"
+)
+
+// ParseParseMode reads TELEGRAM_PARSE_MODE: "plain", "markdownv2" or "html"
+func ParseParseMode(s string) (ParseMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "plain":
+		return PlainText, nil
+	case "markdownv2", "markdown":
+		return MarkdownV2, nil
+	case "html":
+		return HTML, nil
+	}
+	return PlainText, fmt.Errorf("invalid Telegram parse mode '%s' (expected plain, markdownv2 or html)", s)
+}
+
+// markdownV2Special are the characters MarkdownV2 needs escaped outside of code
+const markdownV2Special = "_*[]()~`>#+-=|{}.!\\"
+
+// alertTemplates write the events for a chat. b makes text bold and code monospaced;
+// everything else goes through esc.
+var alertTemplates = map[notifier.EventType]string{
+	notifier.EventTradeOpened: "🟢 {{b (printf \"Opened %v %s\" .Fields.side .Symbol)}}\n" +
+		"Quantity: {{code (printf \"%.6f\" .Fields.quantity)}}\n" +
+		"Price: {{code (printf \"%.2f\" .Fields.price)}}",
+	notifier.EventTradeClosed: "{{if gt .Fields.profit 0.0}}✅{{else}}🔻{{end}} {{b (printf \"Closed %s\" .Symbol)}}\n" +
+		"Quantity: {{code (printf \"%.6f\" .Fields.quantity)}}\n" +
+		"Price: {{code (printf \"%.2f\" .Fields.price)}}\n" +
+		"Profit: {{code (printf \"%+.2f\" .Fields.profit)}}\n" +
+		"Reason: {{esc (print .Fields.reason)}}",
+	notifier.EventDailySummary: "📊 {{b .Message}}\n" +
+		"Trades: {{code (printf \"%v (%v won, %v lost)\" .Fields.trades .Fields.wins .Fields.losses)}}\n" +
+		"Realized PnL: {{code (printf \"%+.2f\" .Fields.realized_pnl)}}\n" +
+		"Unrealized PnL: {{code (printf \"%+.2f\" .Fields.unrealized_pnl)}}\n" +
+		"Fees: {{code (printf \"%.2f\" .Fields.fees)}}\n" +
+		"Largest win: {{code (printf \"%+.2f\" .Fields.largest_win)}}\n" +
+		"Largest loss: {{code (printf \"%+.2f\" .Fields.largest_loss)}}\n" +
+		"Equity: {{code (printf \"%.2f\" .Fields.equity)}}\n" +
+		"Drawdown: {{code (printf \"%.2f%%\" .Fields.drawdown_pct)}}",
+}
+
+// defaultTemplate writes the other events like Event.String
+const defaultTemplate = "{{if .Severity}}{{b (upper .Severity.String)}} {{end}}{{esc .Message}}" +
+	"{{range $k, $v := .Fields}}\n{{esc $k}}: {{esc (print $v)}}{{end}}"
+
+// Format writes an event as a message in the parse mode. Events whose fields do not fit
+// their template are written by the default one.
+func Format(e notifier.Event, mode ParseMode) (string, error) {
+	if text, ok := alertTemplates[e.Type]; ok {
+		if out, err := execute(text, e, mode); err == nil {
+			return out, nil
+		}
+	}
+	return execute(defaultTemplate, e, mode)
+}
+
+// execute runs a template with the functions of the parse mode
+func execute(text string, e notifier.Event, mode ParseMode) (string, error) {
+	esc, b, code := func(s string) string { return s }, func(s string) string { return s }, func(s string) string { return s }
+	switch mode {
+	case MarkdownV2:
+		esc = escapeMarkdownV2
+		b = func(s string) string { return "*" + escapeMarkdownV2(s) + "*" }
+		code = func(s string) string {
+			return "`" + strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(s) + "`"
+		}
+	case HTML:
+		esc = html.EscapeString
+		b = func(s string) string { return "<b>" + html.EscapeString(s) + "</b>" }
+		code = func(s string) string { return "<code>" + html.EscapeString(s) + "</code>" }
+	}
+	tmpl, err := template.New(string(e.Type)).Option("missingkey=error").Funcs(template.FuncMap{
+		"esc": esc, "b": b, "code": code, "upper": strings.ToUpper,
+	}).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid message template: %w", err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, e); err != nil {
+		return "", fmt.Errorf("failed to format %s message: %w", e.Type, err)
+	}
+	return out.String(), nil
+}
+
+// escapeMarkdownV2 escapes the special characters of MarkdownV2
+func escapeMarkdownV2(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(markdownV2Special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,264 @@
+package sendtelegramnotification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	loadenv "learnGoLang/LoadEnv"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxMessageLength is the longest text Telegram accepts in one message
+const MaxMessageLength = 4096
+
+// defaultEndpoint is the public Bot API
+const defaultEndpoint = "https://api.telegram.org"
+
+// maxBackoff caps the delay between retries
+const maxBackoff = time.Minute
+
+// APIError is an unsuccessful Bot API response
+type APIError struct {
+	Code        int
+	Description string
+	RetryAfter  time.Duration // Wait requested by a 429 response
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram error %d: %s", e.Code, e.Description)
+}
+
+// retryable reports whether the request may succeed later: rate limits and server errors
+func (e *APIError) retryable() bool {
+	return e.Code == http.StatusTooManyRequests || e.Code >= 500
+}
+
+// apiResponse is the envelope of every Bot API response
+type apiResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// Client calls the Bot API methods used by the notifications. Failed requests are
+// retried with exponential backoff, waiting as long as a 429 response asks, and the
+// requests are spaced at least MinInterval apart. It never stops the process: every
+// failure is returned as an error.
+type Client struct {
+	MaxRetries  int
+	MinInterval time.Duration // Telegram allows about one message per second in a chat
+	Backoff     time.Duration // First retry delay, doubled on every retry
+
+	endpoint string
+	token    string
+	http     *http.Client
+	sleep    func(ctx context.Context, d time.Duration) error
+
+	mu   sync.Mutex
+	next time.Time // Earliest start of the next request
+}
+
+// NewClient creates a client of the Bot API at TELEGRAM_API_ENDPOINT. It does not connect.
+func NewClient(cfg loadenv.TelegramConfig) *Client {
+	return &Client{
+		MaxRetries:  cfg.MaxRetries,
+		MinInterval: time.Second,
+		Backoff:     time.Second,
+		endpoint:    apiEndpoint(cfg),
+		token:       cfg.BotToken,
+		http:        &http.Client{Timeout: 30 * time.Second},
+		sleep:       sleep,
+	}
+}
+
+// apiEndpoint returns the base URL of the Bot API without a trailing slash
+func apiEndpoint(cfg loadenv.TelegramConfig) string {
+	if cfg.APIEndpoint == "" {
+		return defaultEndpoint
+	}
+	return strings.TrimRight(cfg.APIEndpoint, "/")
+}
+
+// SendMessage sends text to a chat. Plain text longer than MaxMessageLength is split into
+// several messages. Formatted text cannot be cut without breaking its entities, so it
+// must already fit in one message.
+func (c *Client) SendMessage(ctx context.Context, chatID int64, text string, mode ParseMode) error {
+	if length := utf8.RuneCountInString(text); mode != PlainText && length > MaxMessageLength {
+		return fmt.Errorf("%s message of %d characters is longer than %d", mode, length, MaxMessageLength)
+	}
+	for _, part := range split(text, MaxMessageLength) {
+		params := url.Values{"chat_id": {strconv.FormatInt(chatID, 10)}, "text": {part}}
+		if mode != PlainText {
+			params.Set("parse_mode", string(mode))
+		}
+		err := c.do(ctx, "sendMessage", func() (io.Reader, string, error) {
+			return strings.NewReader(params.Encode()), "application/x-www-form-urlencoded", nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendPhoto sends a PNG image with a caption of up to 1024 characters
+func (c *Client) SendPhoto(ctx context.Context, chatID int64, png []byte, caption string, mode ParseMode) error {
+	return c.do(ctx, "sendPhoto", func() (io.Reader, string, error) {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("chat_id", strconv.FormatInt(chatID, 10))
+		form.WriteField("caption", caption)
+		if mode != PlainText {
+			form.WriteField("parse_mode", string(mode))
+		}
+		part, err := form.CreateFormFile("photo", "chart.png")
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to attach photo: %w", err)
+		}
+		part.Write(png)
+		if err := form.Close(); err != nil {
+			return nil, "", fmt.Errorf("failed to attach photo: %w", err)
+		}
+		return &body, form.FormDataContentType(), nil
+	})
+}
+
+// do calls a Bot API method until it succeeds, fails for good or runs out of retries.
+// body builds the request body again for every attempt.
+func (c *Client) do(ctx context.Context, method string, body func() (io.Reader, string, error)) error {
+	delay := c.Backoff
+	for attempt := 0; ; attempt++ {
+		if err := c.wait(ctx); err != nil {
+			return err
+		}
+		err := c.request(ctx, method, body)
+		if err == nil {
+			return nil
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && !apiErr.retryable() {
+			return fmt.Errorf("telegram %s failed: %w", method, err)
+		}
+		if attempt >= c.MaxRetries {
+			return fmt.Errorf("telegram %s failed after %d attempts: %w", method, attempt+1, err)
+		}
+
+		wait := delay
+		if apiErr != nil && apiErr.RetryAfter > 0 {
+			wait = apiErr.RetryAfter
+		}
+		delay = min(2*delay, maxBackoff)
+		log.Printf("Telegram %s failed (%v), retrying in %s", method, err, wait)
+		if err := c.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// request makes one call of a method
+func (c *Client) request(ctx context.Context, method string, body func() (io.Reader, string, error)) error {
+	reader, contentType, err := body()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/bot%s/%s", c.endpoint, c.token, method), reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %s", c.redact(err))
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// The error holds the URL, which holds the token
+		return fmt.Errorf("request failed: %s", c.redact(err))
+	}
+	defer resp.Body.Close()
+
+	var result apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return &APIError{Code: resp.StatusCode, Description: fmt.Sprintf("unreadable response: %v", err)}
+	}
+	if resp.StatusCode != http.StatusOK || !result.OK {
+		code := result.ErrorCode
+		if code == 0 {
+			code = resp.StatusCode
+		}
+		return &APIError{Code: code, Description: result.Description, RetryAfter: time.Duration(result.Parameters.RetryAfter) * time.Second}
+	}
+	return nil
+}
+
+// wait delays the caller until MinInterval has passed since the previous request
+func (c *Client) wait(ctx context.Context) error {
+	c.mu.Lock()
+	start := time.Now()
+	if c.next.After(start) {
+		start = c.next
+	}
+	c.next = start.Add(c.MinInterval)
+	c.mu.Unlock()
+	return c.sleep(ctx, time.Until(start))
+}
+
+// redact removes the bot token from an error message
+func (c *Client) redact(err error) string {
+	if c.token == "" {
+		return err.Error()
+	}
+	return strings.ReplaceAll(err.Error(), c.token, "<token>")
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// split cuts text into parts of at most limit characters, at line breaks where possible
+func split(text string, limit int) []string {
+	var parts []string
+	for utf8.RuneCountInString(text) > limit {
+		cut := runeOffset(text, limit)
+		if i := strings.LastIndex(text[:cut], "\n"); i > 0 {
+			parts = append(parts, text[:i])
+			text = text[i+1:]
+			continue
+		}
+		parts = append(parts, text[:cut])
+		text = text[cut:]
+	}
+	return append(parts, text)
+}
+
+// runeOffset returns the byte offset of the n-th rune of s
+func runeOffset(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
@@ -0,0 +1,281 @@
+package sendtelegramnotification
+
+import (
+	"context"
+	"errors"
+	"io"
+	loadenv "learnGoLang/LoadEnv"
+	notifier "learnGoLang/Notifier"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+var at = time.Date(2025, time.January, 17, 10, 0, 0, 0, time.UTC)
+
+// botServer stands in for the Bot API. It answers with the queued replies first and
+// succeeds once they run out.
+type botServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	replies []func(w http.ResponseWriter)
+	texts   []string
+	modes   []string
+}
+
+func newBotServer(t *testing.T, replies ...func(w http.ResponseWriter)) *botServer {
+	s := &botServer{replies: replies}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bottest-token/sendMessage" {
+			http.NotFound(w, r)
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if len(s.replies) > 0 {
+			reply := s.replies[0]
+			s.replies = s.replies[1:]
+			reply(w)
+			return
+		}
+		s.texts = append(s.texts, r.FormValue("text"))
+		s.modes = append(s.modes, r.FormValue("parse_mode"))
+		w.Write([]byte(`{"ok":true,"result":{"message_id":1}}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func reply(status int, body string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+// newTestClient returns a client of the server that records its waits instead of sleeping
+func newTestClient(s *botServer) (*Client, *[]time.Duration) {
+	c := NewClient(loadenv.TelegramConfig{BotToken: "test-token", ChatID: 42, APIEndpoint: s.URL + "/", MaxRetries: 3})
+	c.MinInterval = 0
+	var waits []time.Duration
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		if d > 0 {
+			waits = append(waits, d)
+		}
+		return ctx.Err()
+	}
+	return c, &waits
+}
+
+func TestClientRetriesWithBackoffAndRetryAfter(t *testing.T) {
+	s := newBotServer(t,
+		reply(http.StatusBadGateway, `{"ok":false,"error_code":502,"description":"Bad Gateway"}`),
+		reply(http.StatusTooManyRequests, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 7","parameters":{"retry_after":7}}`),
+		reply(http.StatusInternalServerError, `not json`),
+	)
+	c, waits := newTestClient(s)
+
+	if err := c.SendMessage(context.Background(), 42, "hello", PlainText); err != nil {
+		t.Fatalf("SendMessage returned error: %v", err)
+	}
+	want := []time.Duration{time.Second, 7 * time.Second, 4 * time.Second}
+	if len(*waits) != len(want) {
+		t.Fatalf("waited %v, want %v", *waits, want)
+	}
+	for i := range want {
+		if (*waits)[i] != want[i] {
+			t.Errorf("wait %d = %s, want %s", i, (*waits)[i], want[i])
+		}
+	}
+	if len(s.texts) != 1 || s.texts[0] != "hello" || s.modes[0] != "" {
+		t.Errorf("delivered %q in modes %q", s.texts, s.modes)
+	}
+}
+
+func TestClientGivesUp(t *testing.T) {
+	failing := reply(http.StatusServiceUnavailable, `{"ok":false,"error_code":503,"description":"Service Unavailable"}`)
+	s := newBotServer(t, failing, failing, failing, failing, failing)
+	c, _ := newTestClient(s)
+	err := c.SendMessage(context.Background(), 42, "hello", PlainText)
+	if err == nil || !strings.Contains(err.Error(), "after 4 attempts") {
+		t.Errorf("SendMessage returned %v, want it to give up after 4 attempts", err)
+	}
+
+	// A rejected message is not retried
+	s = newBotServer(t, reply(http.StatusBadRequest, `{"ok":false,"error_code":400,"description":"Bad Request: can't parse entities"}`))
+	c, waits := newTestClient(s)
+	err = c.SendMessage(context.Background(), 42, "*broken", MarkdownV2)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 400 || len(*waits) != 0 {
+		t.Errorf("SendMessage returned %v after %d waits, want the 400 without retries", err, len(*waits))
+	}
+
+	// An unreachable server does not leak the token
+	c = NewClient(loadenv.TelegramConfig{BotToken: "test-token", APIEndpoint: "http://127.0.0.1:1"})
+	c.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+	if err := c.SendMessage(context.Background(), 42, "hello", PlainText); err == nil || strings.Contains(err.Error(), "test-token") {
+		t.Errorf("SendMessage returned %v, want an error without the token", err)
+	}
+}
+
+func TestClientSpacesRequests(t *testing.T) {
+	s := newBotServer(t)
+	c, waits := newTestClient(s)
+	c.MinInterval = time.Hour
+	c.SendMessage(context.Background(), 42, "one", PlainText)
+	c.SendMessage(context.Background(), 42, "two", PlainText)
+	if len(*waits) != 1 || (*waits)[0] < 59*time.Minute {
+		t.Errorf("waited %v between the messages, want about an hour", *waits)
+	}
+}
+
+func TestSplitLongMessages(t *testing.T) {
+	line := strings.Repeat("é", 3000)
+	parts := split(line+"\n"+line, MaxMessageLength)
+	if len(parts) != 2 || parts[0] != line || parts[1] != line {
+		t.Errorf("split at the line break into %d parts", len(parts))
+	}
+
+	parts = split(strings.Repeat("x", 10000), MaxMessageLength)
+	if len(parts) != 3 || utf8.RuneCountInString(parts[0]) != MaxMessageLength || len(parts[2]) != 10000-2*MaxMessageLength {
+		t.Errorf("split a line without breaks into %d parts", len(parts))
+	}
+
+	s := newBotServer(t)
+	c, _ := newTestClient(s)
+	if err := c.SendMessage(context.Background(), 42, line+"\n"+line, PlainText); err != nil || len(s.texts) != 2 {
+		t.Errorf("sent %d messages (%v), want 2", len(s.texts), err)
+	}
+	// Cutting formatted text could split an entity, which Telegram rejects
+	if err := c.SendMessage(context.Background(), 42, "<b>"+line+"\n"+line+"</b>", HTML); err == nil || len(s.texts) != 2 {
+		t.Errorf("sent a formatted message too long for one message (%v)", err)
+	}
+}
+
+func TestFormatTradeAlerts(t *testing.T) {
+	closed := notifier.TradeClosed("ETH_USDT", 1.5, 3100, -12.5, "stop_loss", at)
+	for _, tt := range []struct {
+		mode ParseMode
+		want string
+	}{
+		{PlainText, "🔻 Closed ETH_USDT\nQuantity: 1.500000\nPrice: 3100.00\nProfit: -12.50\nReason: stop_loss"},
+		{HTML, "🔻 <b>Closed ETH_USDT</b>\nQuantity: <code>1.500000</code>\nPrice: <code>3100.00</code>\nProfit: <code>-12.50</code>\nReason: stop_loss"},
+		{MarkdownV2, "🔻 *Closed ETH\\_USDT*\nQuantity: `1.500000`\nPrice: `3100.00`\nProfit: `-12.50`\nReason: stop\\_loss"},
+	} {
+		got, err := Format(closed, tt.mode)
+		if err != nil || got != tt.want {
+			t.Errorf("Format(%q) = %q, %v, want %q", tt.mode, got, err, tt.want)
+		}
+	}
+
+	opened, _ := Format(notifier.TradeOpened("ETHUSDT", "long", 1.5, 3000, at), HTML)
+	if opened != "🟢 <b>Opened long ETHUSDT</b>\nQuantity: <code>1.500000</code>\nPrice: <code>3000.00</code>" {
+		t.Errorf("Format(opened) = %q", opened)
+	}
+
+	// Other events and trades missing fields use the default template
+	got, _ := Format(notifier.Message(notifier.Error, "balance < 10 & falling", at), HTML)
+	if got != "<b>ERROR</b> balance &lt; 10 &amp; falling" {
+		t.Errorf("Format(message) = %q", got)
+	}
+	got, _ = Format(notifier.Event{Type: notifier.EventTradeOpened, Message: "Opened (manual)"}, MarkdownV2)
+	if got != "Opened \\(manual\\)" {
+		t.Errorf("Format(trade without fields) = %q", got)
+	}
+	if _, err := ParseParseMode("rtf"); err == nil {
+		t.Error("expected an error for an unknown parse mode")
+	}
+}
+
+func TestNotifierCoalescesBursts(t *testing.T) {
+	s := newBotServer(t)
+	n, err := NewNotifier(loadenv.TelegramConfig{BotToken: "test-token", ChatID: 42, APIEndpoint: s.URL, ParseMode: "html", CoalesceSeconds: 3600})
+	if err != nil {
+		t.Fatalf("NewNotifier returned error: %v", err)
+	}
+	n.client.MinInterval = 0
+
+	d := notifier.NewDispatcher(10, notifier.Route{Name: "telegram", Notifier: n})
+	d.Notify(context.Background(), notifier.TradeOpened("ETHUSDT", "long", 1, 3000, at))
+	d.Notify(context.Background(), notifier.TradeOpened("BTCUSDT", "long", 0.1, 60000, at))
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	if len(s.texts) != 1 || !strings.Contains(s.texts[0], "ETHUSDT</b>") || !strings.Contains(s.texts[0], "\n\n🟢 <b>Opened long BTCUSDT") || s.modes[0] != "HTML" {
+		t.Errorf("sent %q in modes %q, want one HTML message with both trades", s.texts, s.modes)
+	}
+}
+
+func TestNotifierSplitsBurstsBetweenEvents(t *testing.T) {
+	s := newBotServer(t)
+	n, err := NewNotifier(loadenv.TelegramConfig{BotToken: "test-token", ChatID: 42, APIEndpoint: s.URL, ParseMode: "html", CoalesceSeconds: 3600})
+	if err != nil {
+		t.Fatalf("NewNotifier returned error: %v", err)
+	}
+	n.client.MinInterval = 0
+
+	long := strings.Repeat("a", 1800)
+	for i := 0; i < 3; i++ {
+		n.Notify(context.Background(), notifier.Message(notifier.Warning, long, at))
+	}
+	// An event too long for one HTML message goes out as plain text
+	n.Notify(context.Background(), notifier.Message(notifier.Warning, strings.Repeat("<", 5000), at))
+	if err := n.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush returned error: %v", err)
+	}
+
+	if len(s.texts) != 4 || strings.Count(s.texts[0], "<b>WARNING</b>") != 2 || strings.Count(s.texts[1], "<b>WARNING</b>") != 1 {
+		t.Fatalf("sent %d messages, want the burst split between the events and the long event in 2 parts", len(s.texts))
+	}
+	if s.modes[0] != "HTML" || s.modes[2] != "" || !strings.HasPrefix(s.texts[2], "[WARNING] <<<") {
+		t.Errorf("sent the parts in modes %q", s.modes)
+	}
+}
+
+func TestNotifierSendsPhotos(t *testing.T) {
+	var caption, filename string
+	var size int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bottest-token/sendPhoto" {
+			http.NotFound(w, r)
+			return
+		}
+		file, header, err := r.FormFile("photo")
+		if err != nil {
+			t.Errorf("no photo in the request: %v", err)
+			return
+		}
+		defer file.Close()
+		data, _ := io.ReadAll(file)
+		caption, filename, size = r.FormValue("caption"), header.Filename, len(data)
+		w.Write([]byte(`{"ok":true,"result":{"message_id":2}}`))
+	}))
+	defer server.Close()
+
+	n, _ := NewNotifier(loadenv.TelegramConfig{BotToken: "test-token", ChatID: 42, APIEndpoint: server.URL})
+	if err := n.SendPhoto(context.Background(), []byte("\x89PNG"), "Equity"); err != nil {
+		t.Fatalf("SendPhoto returned error: %v", err)
+	}
+	if caption != "Equity" || filename != "chart.png" || size != 4 {
+		t.Errorf("sent %s (%d bytes) with caption %q", filename, size, caption)
+	}
+}
+
+func TestFormatDigest(t *testing.T) {
+	summary := notifier.DailySummary("Daily digest of ETHUSDT", map[string]any{
+		"trades": 3, "wins": 2, "losses": 1, "realized_pnl": 260.0, "unrealized_pnl": -40.0, "fees": 9.0,
+		"largest_win": 300.0, "largest_loss": -120.0, "equity": 10290.0, "drawdown_pct": 2.0,
+	}, at)
+	got, err := Format(summary, HTML)
+	want := "📊 <b>Daily digest of ETHUSDT</b>\nTrades: <code>3 (2 won, 1 lost)</code>\nRealized PnL: <code>+260.00</code>\n" +
+		"Unrealized PnL: <code>-40.00</code>\nFees: <code>9.00</code>\nLargest win: <code>+300.00</code>\n" +
+		"Largest loss: <code>-120.00</code>\nEquity: <code>10290.00</code>\nDrawdown: <code>2.00%</code>"
+	if err != nil || got != want {
+		t.Errorf("Format(digest) = %q, %v, want %q", got, err, want)
+	}
+}
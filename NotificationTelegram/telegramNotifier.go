@@ -0,0 +1,135 @@
+package sendtelegramnotification
+
+import (
+	"context"
+	"fmt"
+	loadenv "learnGoLang/LoadEnv"
+	notifier "learnGoLang/Notifier"
+	"log"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// flushTimeout limits how long sending a coalesced burst may take
+const flushTimeout = 2 * time.Minute
+
+// Notifier sends the events to the first chat of TELEGRAM_CHAT_ID, formatted for
+// TELEGRAM_PARSE_MODE. Events arriving within the coalesce window of the first one are
+// sent together in as few messages as fit, so a burst of fills does not run into the
+// rate limit.
+type Notifier struct {
+	client *Client
+	chatID int64
+	mode   ParseMode
+	window time.Duration
+
+	mu      sync.Mutex
+	pending []message
+	timer   *time.Timer
+}
+
+// message is the text of one or more events and its parse mode
+type message struct {
+	text string
+	mode ParseMode
+}
+
+// NewNotifier creates the notifier. It does not connect, so Telegram being unreachable
+// only fails the deliveries.
+func NewNotifier(cfg loadenv.TelegramConfig) (*Notifier, error) {
+	if cfg.BotToken == "" || cfg.ChatID == 0 {
+		return nil, fmt.Errorf("TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID must be set")
+	}
+	mode, err := ParseParseMode(cfg.ParseMode)
+	if err != nil {
+		return nil, err
+	}
+	return &Notifier{
+		client: NewClient(cfg),
+		chatID: cfg.ChatID,
+		mode:   mode,
+		window: time.Duration(cfg.CoalesceSeconds) * time.Second,
+	}, nil
+}
+
+// Notify sends the event, or queues it for the next coalesced message
+func (n *Notifier) Notify(ctx context.Context, e notifier.Event) error {
+	m, err := n.format(e)
+	if err != nil {
+		return err
+	}
+	if n.window <= 0 {
+		return n.client.SendMessage(ctx, n.chatID, m.text, m.mode)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.pending = append(n.pending, m)
+	if n.timer == nil {
+		n.timer = time.AfterFunc(n.window, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+			defer cancel()
+			if err := n.Flush(ctx); err != nil {
+				log.Printf("Error sending Telegram notifications: %v", err)
+			}
+		})
+	}
+	return nil
+}
+
+// format writes the event in the parse mode of the notifier. An event too long for one
+// formatted message is sent as plain text, which can be split anywhere.
+func (n *Notifier) format(e notifier.Event) (message, error) {
+	text, err := Format(e, n.mode)
+	if err != nil {
+		return message{}, err
+	}
+	if n.mode != PlainText && utf8.RuneCountInString(text) > MaxMessageLength {
+		return message{e.String(), PlainText}, nil
+	}
+	return message{text, n.mode}, nil
+}
+
+// Flush sends the queued events, as many per message as fit
+func (n *Notifier) Flush(ctx context.Context) error {
+	n.mu.Lock()
+	pending := n.pending
+	n.pending = nil
+	if n.timer != nil {
+		n.timer.Stop()
+		n.timer = nil
+	}
+	n.mu.Unlock()
+
+	for _, m := range join(pending, MaxMessageLength) {
+		if err := n.client.SendMessage(ctx, n.chatID, m.text, m.mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// join merges consecutive messages of the same parse mode, separated by a blank line,
+// as long as the result stays within limit characters. Messages are never cut, so every
+// formatted part keeps its entities whole.
+func join(messages []message, limit int) []message {
+	var joined []message
+	for _, m := range messages {
+		if last := len(joined) - 1; last >= 0 && joined[last].mode == m.mode &&
+			utf8.RuneCountInString(joined[last].text)+2+utf8.RuneCountInString(m.text) <= limit {
+			joined[last].text += "\n\n" + m.text
+			continue
+		}
+		joined = append(joined, m)
+	}
+	return joined
+}
+
+// SendPhoto sends a PNG image after the queued events
+func (n *Notifier) SendPhoto(ctx context.Context, png []byte, caption string) error {
+	if err := n.Flush(ctx); err != nil {
+		return err
+	}
+	return n.client.SendPhoto(ctx, n.chatID, png, caption, PlainText)
+}
@@ -0,0 +1,142 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Body formats of the webhook notifier
+const (
+	WebhookJSON    = "json"    // The event as JSON
+	WebhookSlack   = "slack"   // {"text": ...}
+	WebhookDiscord = "discord" // {"content": ...}
+)
+
+// WebhookNotifier posts the events to an HTTP endpoint, such as a Slack or Discord
+// incoming webhook
+type WebhookNotifier struct {
+	url    string
+	format string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting to url in the json, slack or discord format
+func NewWebhookNotifier(url, format string) (*WebhookNotifier, error) {
+	format = strings.ToLower(format)
+	switch format {
+	case "":
+		format = WebhookJSON
+	case WebhookJSON, WebhookSlack, WebhookDiscord:
+	default:
+		return nil, fmt.Errorf("invalid webhook format '%s' (expected json, slack or discord)", format)
+	}
+	return &WebhookNotifier{url: url, format: format, client: &http.Client{Timeout: deliveryTimeout}}, nil
+}
+
+// Notify posts the event
+func (n *WebhookNotifier) Notify(ctx context.Context, e Event) error {
+	var payload any = e
+	switch n.format {
+	case WebhookSlack:
+		payload = map[string]string{"text": e.String()}
+	case WebhookDiscord:
+		payload = map[string]string{"content": e.String()}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(text)))
+	}
+	return nil
+}
+
+// EmailNotifier sends every event as a plain text email through an SMTP server
+type EmailNotifier struct {
+	addr string
+	from string
+	to   []string
+	auth smtp.Auth
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewEmailNotifier creates a notifier sending from one address to the given ones through
+// the SMTP server at addr (host:port). Without a username the server is used without
+// authentication.
+func NewEmailNotifier(addr, username, password, from string, to []string) (*EmailNotifier, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP address '%s': %w", addr, err)
+	}
+	if from == "" || len(to) == 0 {
+		return nil, fmt.Errorf("email notifications need a sender and at least one recipient")
+	}
+	n := &EmailNotifier{addr: addr, from: from, to: to, send: smtp.SendMail}
+	if username != "" {
+		n.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return n, nil
+}
+
+// Notify sends the event. net/smtp has no context, the server's timeouts apply.
+func (n *EmailNotifier) Notify(ctx context.Context, e Event) error {
+	subject := fmt.Sprintf("[%s] %s", e.Severity, strings.SplitN(e.Message, "\n", 2)[0])
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		n.from, strings.Join(n.to, ", "), subject, e.Time.Format(time.RFC1123Z), strings.ReplaceAll(e.String(), "\n", "\r\n"))
+	if err := n.send(n.addr, n.auth, n.from, n.to, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// FileNotifier appends every event as one JSON line to a local file
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileNotifier creates a notifier writing to the JSONL file at path
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+// Notify appends the event
+func (n *FileNotifier) Notify(ctx context.Context, e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	file, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open notification file: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write notification file: %w", err)
+	}
+	return nil
+}
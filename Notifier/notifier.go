@@ -0,0 +1,295 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// deliveryTimeout limits how long a notifier may take to deliver one event
+const deliveryTimeout = 30 * time.Second
+
+// Severity of an event, notifiers can be limited to the more severe ones
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Error
+)
+
+var severityNames = map[Severity]string{Info: "info", Warning: "warning", Error: "error"}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// ParseSeverity reads "info", "warning" or "error"
+func ParseSeverity(s string) (Severity, error) {
+	for severity, name := range severityNames {
+		if strings.EqualFold(strings.TrimSpace(s), name) {
+			return severity, nil
+		}
+	}
+	return Info, fmt.Errorf("invalid severity '%s' (expected info, warning or error)", s)
+}
+
+// MarshalText writes the severity by name in JSON
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText reads a severity written by MarshalText
+func (s *Severity) UnmarshalText(text []byte) error {
+	severity, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = severity
+	return nil
+}
+
+// EventType tells what happened
+type EventType string
+
+const (
+	EventTradeOpened  EventType = "trade_opened"
+	EventTradeClosed  EventType = "trade_closed"
+	EventError        EventType = "error"
+	EventDailySummary EventType = "daily_summary"
+	EventMessage      EventType = "message" // Free text, such as service start and stop
+)
+
+// Event is something the bot reports
+type Event struct {
+	Type     EventType      `json:"type"`
+	Severity Severity       `json:"severity"`
+	Time     time.Time      `json:"time"`
+	Symbol   string         `json:"symbol,omitempty"`
+	Message  string         `json:"message"`          // Human readable summary
+	Fields   map[string]any `json:"fields,omitempty"` // Structured details such as the price and the quantity
+}
+
+// String writes the event as text for chats and emails
+func (e Event) String() string {
+	var b strings.Builder
+	if e.Severity > Info {
+		fmt.Fprintf(&b, "[%s] ", strings.ToUpper(e.Severity.String()))
+	}
+	b.WriteString(e.Message)
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "\n%s: %v", k, e.Fields[k])
+	}
+	return b.String()
+}
+
+// TradeOpened reports a filled entry
+func TradeOpened(symbol, side string, quantity, price float64, at time.Time) Event {
+	return Event{
+		Type:    EventTradeOpened,
+		Time:    at,
+		Symbol:  symbol,
+		Message: fmt.Sprintf("Opened %s %s: %.6f @ %.2f", side, symbol, quantity, price),
+		Fields:  map[string]any{"side": side, "quantity": quantity, "price": price},
+	}
+}
+
+// TradeClosed reports a filled exit with its profit after fees
+func TradeClosed(symbol string, quantity, price, profit float64, reason string, at time.Time) Event {
+	return Event{
+		Type:    EventTradeClosed,
+		Time:    at,
+		Symbol:  symbol,
+		Message: fmt.Sprintf("Closed %s: %.6f @ %.2f, profit %+.2f (%s)", symbol, quantity, price, profit, reason),
+		Fields:  map[string]any{"quantity": quantity, "price": price, "profit": profit, "reason": reason},
+	}
+}
+
+// ErrorOccurred reports an error of the bot
+func ErrorOccurred(symbol string, err error, at time.Time) Event {
+	return Event{Type: EventError, Severity: Error, Time: at, Symbol: symbol, Message: err.Error()}
+}
+
+// DailySummary reports the performance of a day
+func DailySummary(message string, fields map[string]any, at time.Time) Event {
+	return Event{Type: EventDailySummary, Time: at, Message: message, Fields: fields}
+}
+
+// Message reports free text
+func Message(severity Severity, message string, at time.Time) Event {
+	return Event{Type: EventMessage, Severity: severity, Time: at, Message: message}
+}
+
+// Notifier delivers events to one destination
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// Flusher is implemented by notifiers that hold events back, such as to coalesce bursts
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// ErrQueueFull is returned when an event was dropped because a notifier fell behind
+var ErrQueueFull = errors.New("notification queue is full")
+
+// Route sends the events of at least MinSeverity to a notifier
+type Route struct {
+	Name        string
+	Notifier    Notifier
+	MinSeverity Severity
+}
+
+// Dispatcher fans the events out to several notifiers in the background. Every route has
+// its own bounded queue and worker, so Notify never waits for a delivery and a slow
+// notifier only delays itself. Events that do not fit in a full queue are dropped.
+type Dispatcher struct {
+	routes  []*route
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+	closed  bool
+	dropped atomic.Int64
+}
+
+// route is a Route with its queue
+type route struct {
+	Route
+	queue chan delivery
+}
+
+// delivery is a queued event, or a function queued by Do
+type delivery struct {
+	event Event
+	run   func(ctx context.Context) error
+}
+
+// NewDispatcher starts a worker per route, each with a queue of queueSize events
+func NewDispatcher(queueSize int, routes ...Route) *Dispatcher {
+	d := &Dispatcher{}
+	for _, r := range routes {
+		rt := &route{Route: r, queue: make(chan delivery, queueSize)}
+		d.routes = append(d.routes, rt)
+		d.wg.Add(1)
+		go d.deliver(rt)
+	}
+	return d
+}
+
+// deliver sends the queued events of a route until the queue is closed
+func (d *Dispatcher) deliver(r *route) {
+	defer d.wg.Done()
+	for job := range r.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+		if job.run != nil {
+			if err := job.run(ctx); err != nil {
+				log.Printf("Error sending through %s: %v", r.Name, err)
+			}
+		} else if err := r.Notifier.Notify(ctx, job.event); err != nil {
+			log.Printf("Error sending %s notification through %s: %v", job.event.Type, r.Name, err)
+		}
+		cancel()
+	}
+}
+
+// Notify queues the event for every route whose severity it reaches. It does not block.
+func (d *Dispatcher) Notify(ctx context.Context, e Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return fmt.Errorf("notifications are closed")
+	}
+	var full []string
+	for _, r := range d.routes {
+		if e.Severity < r.MinSeverity {
+			continue
+		}
+		select {
+		case r.queue <- delivery{event: e}:
+		default:
+			d.dropped.Add(1)
+			full = append(full, r.Name)
+		}
+	}
+	if len(full) > 0 {
+		return fmt.Errorf("%w: %s event dropped for %s", ErrQueueFull, e.Type, strings.Join(full, ", "))
+	}
+	return nil
+}
+
+// Do queues run on the route called name, so it runs after the events queued for that
+// route before it, such as an image that belongs to the message sent just before. It
+// does not block.
+func (d *Dispatcher) Do(name string, run func(ctx context.Context) error) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return fmt.Errorf("notifications are closed")
+	}
+	for _, r := range d.routes {
+		if r.Name != name {
+			continue
+		}
+		select {
+		case r.queue <- delivery{run: run}:
+			return nil
+		default:
+			d.dropped.Add(1)
+			return fmt.Errorf("%w: dropped for %s", ErrQueueFull, name)
+		}
+	}
+	return fmt.Errorf("no notification route called %s", name)
+}
+
+// Dropped returns the number of deliveries dropped by full queues
+func (d *Dispatcher) Dropped() int64 {
+	return d.dropped.Load()
+}
+
+// Close stops accepting events and waits until the queued ones are delivered or ctx ends.
+// Notifiers holding events back are flushed once their queue is empty.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, r := range d.routes {
+			close(r.queue)
+		}
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		for _, r := range d.routes {
+			if f, ok := r.Notifier.(Flusher); ok {
+				if err := f.Flush(ctx); err != nil {
+					log.Printf("Error flushing notifications of %s: %v", r.Name, err)
+				}
+			}
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("notifications not delivered before shutdown: %w", ctx.Err())
+	}
+}
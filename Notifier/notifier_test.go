@@ -0,0 +1,222 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var at = time.Date(2025, time.January, 17, 10, 0, 0, 0, time.UTC)
+
+// recorder keeps the events it was sent. It waits for release first when it has one.
+type recorder struct {
+	mu      sync.Mutex
+	events  []Event
+	release chan struct{}
+}
+
+func (r *recorder) Notify(ctx context.Context, e Event) error {
+	if r.release != nil {
+		<-r.release
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+func TestDispatcherRoutesBySeverity(t *testing.T) {
+	all, errorsOnly := &recorder{}, &recorder{}
+	d := NewDispatcher(10, Route{Name: "all", Notifier: all}, Route{Name: "errors", Notifier: errorsOnly, MinSeverity: Error})
+
+	d.Notify(context.Background(), TradeOpened("ETHUSDT", "long", 1.5, 3000, at))
+	d.Notify(context.Background(), ErrorOccurred("ETHUSDT", errors.New("order rejected"), time.Time{}))
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	if len(all.events) != 2 || len(errorsOnly.events) != 1 || errorsOnly.events[0].Type != EventError {
+		t.Errorf("delivered %d and %d events, want 2 and the error only", len(all.events), len(errorsOnly.events))
+	}
+	if errorsOnly.events[0].Time.IsZero() {
+		t.Error("an event without a time was not stamped")
+	}
+	if err := d.Notify(context.Background(), Message(Info, "late", at)); err == nil {
+		t.Error("a closed dispatcher accepted an event")
+	}
+}
+
+func TestSlowNotifierDoesNotBlock(t *testing.T) {
+	slow, fast := &recorder{release: make(chan struct{})}, &recorder{}
+	d := NewDispatcher(1, Route{Name: "slow", Notifier: slow}, Route{Name: "fast", Notifier: fast})
+
+	// The slow worker holds the first event, the second waits in its queue and the third is dropped
+	var dropped int
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			if err := d.Notify(context.Background(), Message(Info, "tick", at)); errors.Is(err, ErrQueueFull) {
+				dropped++
+			}
+			time.Sleep(10 * time.Millisecond) // Let the fast worker keep up
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Notify blocked on the slow notifier")
+	}
+	if dropped != 1 || d.Dropped() != 1 {
+		t.Errorf("dropped %d events (counted %d), want 1", dropped, d.Dropped())
+	}
+
+	close(slow.release)
+	d.Close(context.Background())
+	if len(fast.events) != 3 || len(slow.events) != 2 {
+		t.Errorf("fast got %d and slow %d events, want 3 and 2", len(fast.events), len(slow.events))
+	}
+}
+
+func TestDispatcherDoRunsAfterQueuedEvents(t *testing.T) {
+	slow := &recorder{release: make(chan struct{})}
+	d := NewDispatcher(10, Route{Name: "slow", Notifier: slow})
+
+	d.Notify(context.Background(), Message(Info, "digest", at))
+	var seen int
+	err := d.Do("slow", func(ctx context.Context) error {
+		slow.mu.Lock()
+		defer slow.mu.Unlock()
+		seen = len(slow.events)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do returned error: %v", err)
+	}
+	if err := d.Do("missing", func(ctx context.Context) error { return nil }); err == nil {
+		t.Error("Do accepted an unknown route")
+	}
+
+	close(slow.release)
+	d.Close(context.Background())
+	if seen != 1 {
+		t.Errorf("the function ran after %d events, want after the queued one", seen)
+	}
+}
+
+func TestWebhookFormats(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		if strings.Contains(string(body), "fail") {
+			http.Error(w, "bad payload", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	event := TradeClosed("ETHUSDT", 1.5, 3100, 148.2, "take_profit", at)
+	for _, tt := range []struct {
+		format string
+		want   string
+	}{
+		{"slack", `{"text":"Closed ETHUSDT: 1.500000 @ 3100.00, profit +148.20 (take_profit)`},
+		{"discord", `{"content":"Closed ETHUSDT`},
+		{"", `{"type":"trade_closed","severity":"info","time":"2025-01-17T10:00:00Z","symbol":"ETHUSDT"`},
+	} {
+		n, err := NewWebhookNotifier(server.URL, tt.format)
+		if err != nil {
+			t.Fatalf("NewWebhookNotifier(%q) returned error: %v", tt.format, err)
+		}
+		if err := n.Notify(context.Background(), event); err != nil {
+			t.Fatalf("%q webhook returned error: %v", tt.format, err)
+		}
+		if !strings.HasPrefix(string(body), tt.want) {
+			t.Errorf("%q webhook posted %s, want it to start with %s", tt.format, body, tt.want)
+		}
+	}
+
+	n, _ := NewWebhookNotifier(server.URL, "json")
+	if err := n.Notify(context.Background(), Message(Warning, "fail", at)); err == nil || !strings.Contains(err.Error(), "bad payload") {
+		t.Errorf("rejected webhook returned %v, want the server's error", err)
+	}
+	if _, err := NewWebhookNotifier(server.URL, "teams"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestEmailNotifier(t *testing.T) {
+	n, err := NewEmailNotifier("smtp.example.com:587", "bot", "secret", "bot@example.com", []string{"ops@example.com"})
+	if err != nil {
+		t.Fatalf("NewEmailNotifier returned error: %v", err)
+	}
+	var sent string
+	n.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		if addr != "smtp.example.com:587" || a == nil || from != "bot@example.com" || len(to) != 1 {
+			t.Errorf("sent through %s from %s to %v", addr, from, to)
+		}
+		sent = string(msg)
+		return nil
+	}
+	if err := n.Notify(context.Background(), ErrorOccurred("ETHUSDT", errors.New("balance unavailable"), at)); err != nil {
+		t.Fatalf("Notify returned error: %v", err)
+	}
+	for _, want := range []string{"Subject: [error] balance unavailable\r\n", "To: ops@example.com\r\n", "\r\n\r\n[ERROR] balance unavailable"} {
+		if !strings.Contains(sent, want) {
+			t.Errorf("email does not contain %q:\n%s", want, sent)
+		}
+	}
+
+	if _, err := NewEmailNotifier("smtp.example.com", "", "", "bot@example.com", []string{"ops@example.com"}); err == nil {
+		t.Error("expected an error for an address without a port")
+	}
+}
+
+func TestFileNotifierWritesJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	n := NewFileNotifier(path)
+	summary := DailySummary("3 trades, +1.2%", map[string]any{"trades": 3}, at)
+	for _, e := range []Event{summary, ErrorOccurred("", errors.New("stream closed"), at)} {
+		if err := n.Notify(context.Background(), e); err != nil {
+			t.Fatalf("Notify returned error: %v", err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open events: %v", err)
+	}
+	defer file.Close()
+	var events []Event
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid line %s: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 2 || events[0].Type != EventDailySummary || events[1].Severity != Error || events[0].Fields["trades"] != 3.0 {
+		t.Errorf("read back %+v", events)
+	}
+}
+
+func TestEventString(t *testing.T) {
+	got := TradeOpened("ETHUSDT", "long", 1.5, 3000, at).String()
+	want := "Opened long ETHUSDT: 1.500000 @ 3000.00\nprice: 3000\nquantity: 1.5\nside: long"
+	if got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if _, err := ParseSeverity("fatal"); err == nil {
+		t.Error("expected an error for an unknown severity")
+	}
+}
@@ -0,0 +1,308 @@
+package optimizer
+
+import (
+	"context"
+	"fmt"
+	backtest "learnGoLang/Backtest"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	loadenv "learnGoLang/LoadEnv"
+	risk "learnGoLang/Risk"
+	strategy "learnGoLang/Strategy"
+	"log"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// parameter is a strategy setting the optimizer can vary
+type parameter struct {
+	integer bool
+	set     func(cfg *loadenv.StrategyConfig, v float64)
+}
+
+// parameters maps the .env names of the tunable settings to their fields
+var parameters = map[string]parameter{
+	"FAST_LENGTH":             {true, func(cfg *loadenv.StrategyConfig, v float64) { cfg.FastLength = int(v) }},
+	"SLOW_LENGTH":             {true, func(cfg *loadenv.StrategyConfig, v float64) { cfg.SlowLength = int(v) }},
+	"SIGNAL_LENGTH":           {true, func(cfg *loadenv.StrategyConfig, v float64) { cfg.SignalLength = int(v) }},
+	"TREND_TF_HOURS":          {true, func(cfg *loadenv.StrategyConfig, v float64) { cfg.TrendTFHours = int(v) }},
+	"MAX_POSITION_HOLD_HOURS": {true, func(cfg *loadenv.StrategyConfig, v float64) { cfg.MaxPositionHoldHours = int(v) }},
+	"MIN_MACD_STRENGTH":       {false, func(cfg *loadenv.StrategyConfig, v float64) { cfg.MinMACDStrength = v }},
+	"STOP_LOSS_PCT":           {false, func(cfg *loadenv.StrategyConfig, v float64) { cfg.StopLossPct = v }},
+	"TAKE_PROFIT_PCT":         {false, func(cfg *loadenv.StrategyConfig, v float64) { cfg.TakeProfitPct = v }},
+	"TRAILING_STOP_PCT":       {false, func(cfg *loadenv.StrategyConfig, v float64) { cfg.TrailingStopPct = v }},
+}
+
+// Range is the list of values one parameter takes
+type Range struct {
+	Name   string
+	Values []float64
+}
+
+// ParseRange parses "NAME=min:max:step", "NAME=v1,v2,v3" or "NAME=value", where NAME is
+// the .env name of the setting, e.g. "FAST_LENGTH=8:16:2"
+func ParseRange(s string) (Range, error) {
+	name, spec, ok := strings.Cut(s, "=")
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if !ok || spec == "" {
+		return Range{}, fmt.Errorf("invalid range '%s', expected NAME=min:max:step or NAME=v1,v2", s)
+	}
+	param, known := parameters[name]
+	if !known {
+		return Range{}, fmt.Errorf("%s cannot be optimized (supported: %s)", name, strings.Join(Parameters(), ", "))
+	}
+
+	r := Range{Name: name}
+	if bounds := strings.Split(spec, ":"); len(bounds) == 3 {
+		var v [3]float64
+		for i, b := range bounds {
+			f, err := strconv.ParseFloat(strings.TrimSpace(b), 64)
+			if err != nil {
+				return Range{}, fmt.Errorf("invalid range '%s': %w", s, err)
+			}
+			v[i] = f
+		}
+		min, max, step := v[0], v[1], v[2]
+		if step <= 0 || max < min {
+			return Range{}, fmt.Errorf("invalid range '%s': need min <= max and a positive step", s)
+		}
+		for i := 0; min+float64(i)*step <= max+step*1e-9; i++ {
+			// Round away the float error of the repeated step, e.g. 0.1+0.2
+			r.Values = append(r.Values, math.Round((min+float64(i)*step)*1e9)/1e9)
+		}
+	} else {
+		for _, field := range strings.Split(spec, ",") {
+			f, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil {
+				return Range{}, fmt.Errorf("invalid range '%s': %w", s, err)
+			}
+			r.Values = append(r.Values, f)
+		}
+	}
+
+	if param.integer {
+		for _, v := range r.Values {
+			if v != math.Trunc(v) {
+				return Range{}, fmt.Errorf("invalid range '%s': %s takes whole numbers", s, name)
+			}
+		}
+	}
+	return r, nil
+}
+
+// Parameters returns the names of the settings that can be optimized
+func Parameters() []string {
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Objective is the metric runs are ranked by
+type Objective string
+
+const (
+	Sharpe       Objective = "sharpe"
+	ProfitFactor Objective = "profit_factor"
+	MaxDrawdown  Objective = "max_drawdown"
+	TotalReturn  Objective = "return"
+)
+
+// ParseObjective validates an objective name
+func ParseObjective(s string) (Objective, error) {
+	switch o := Objective(strings.ToLower(s)); o {
+	case Sharpe, ProfitFactor, MaxDrawdown, TotalReturn:
+		return o, nil
+	}
+	return "", fmt.Errorf("invalid objective '%s' (expected sharpe, profit_factor, max_drawdown or return)", s)
+}
+
+// Score returns the metric the objective ranks by
+func (o Objective) Score(m backtest.Metrics) float64 {
+	switch o {
+	case ProfitFactor:
+		return m.ProfitFactor
+	case MaxDrawdown:
+		return m.MaxDrawdownPct
+	case TotalReturn:
+		return m.TotalReturnPct
+	}
+	return m.Sharpe
+}
+
+// Better reports whether score a ranks above score b. Only the drawdown is minimized.
+func (o Objective) Better(a, b float64) bool {
+	if o == MaxDrawdown {
+		return a < b
+	}
+	return a > b
+}
+
+// Options configures an optimization
+type Options struct {
+	Base      loadenv.StrategyConfig // Settings of the parameters that are not varied
+	Risk      risk.Config            // Position sizing of every backtest
+	Equity    float64                // Initial equity of every backtest
+	Ranges    []Range
+	Random    int   // Number of random combinations to try, 0 tries the whole grid
+	Seed      int64 // Seed of the random search
+	Workers   int   // Backtests run in parallel, defaults to the number of CPUs
+	Objective Objective
+	MinTrades int  // Runs with fewer trades are not ranked
+	Quiet     bool // Do not log the progress
+}
+
+// Run is the outcome of one parameter combination
+type Run struct {
+	Values  []float64 // One value per range, in the order of Options.Ranges
+	Metrics backtest.Metrics
+	Score   float64
+	Rank    int // 1 is the best run, 0 for runs with fewer than MinTrades trades
+}
+
+// Report holds every run of an optimization, best first
+type Report struct {
+	Runs    []Run
+	Skipped int // Combinations the strategy rejected, such as a fast length above the slow one
+}
+
+// Optimize backtests every combination of the ranges, or a random sample of them, over
+// the entry timeframe candles and ranks the runs by the objective
+func Optimize(ctx context.Context, candles []klinesfrombinance.Candle, opts Options) (Report, error) {
+	if len(opts.Ranges) == 0 {
+		return Report{}, fmt.Errorf("no parameter ranges to optimize")
+	}
+	if len(candles) == 0 {
+		return Report{}, fmt.Errorf("no candles to backtest")
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	entryInterval := time.Duration(opts.Base.EntryTFMinutes) * time.Minute
+	start := time.UnixMilli(candles[0].Timestamp).UTC()
+	end := time.UnixMilli(candles[len(candles)-1].Timestamp).UTC().Add(entryInterval)
+
+	combinations := grid(opts.Ranges)
+	if opts.Random > 0 && opts.Random < len(combinations) {
+		rng := rand.New(rand.NewSource(opts.Seed))
+		rng.Shuffle(len(combinations), func(i, j int) { combinations[i], combinations[j] = combinations[j], combinations[i] })
+		combinations = combinations[:opts.Random]
+	}
+
+	runs := make([]*Run, len(combinations))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				runs[i] = backtestCombination(candles, opts, combinations[i], start, end)
+
+				mu.Lock()
+				done++
+				if !opts.Quiet && (done%max(1, len(combinations)/10) == 0 || done == len(combinations)) {
+					log.Printf("[%d/%d] combinations backtested", done, len(combinations))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := range combinations {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return Report{}, err
+	}
+
+	var report Report
+	for _, r := range runs {
+		if r == nil {
+			report.Skipped++
+			continue
+		}
+		report.Runs = append(report.Runs, *r)
+	}
+	rank(report.Runs, opts)
+	return report, nil
+}
+
+// grid returns every combination of the range values
+func grid(ranges []Range) [][]float64 {
+	combinations := [][]float64{nil}
+	for _, r := range ranges {
+		var next [][]float64
+		for _, c := range combinations {
+			for _, v := range r.Values {
+				next = append(next, append(append([]float64(nil), c...), v))
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+// backtestCombination runs one backtest, nil if the strategy rejects the settings
+func backtestCombination(candles []klinesfrombinance.Candle, opts Options, values []float64, start, end time.Time) *Run {
+	cfg := configFor(opts, values)
+	strat, err := strategy.New(cfg)
+	if err != nil {
+		return nil
+	}
+	result, err := backtest.Run(candles, strat, paramsFor(opts, cfg))
+	if err != nil {
+		return nil
+	}
+	metrics := backtest.ComputeMetrics(result, start, end)
+	return &Run{Values: values, Metrics: metrics, Score: opts.Objective.Score(metrics)}
+}
+
+// paramsFor returns the backtest parameters of the settings with the sizing of the options
+func paramsFor(opts Options, cfg loadenv.StrategyConfig) backtest.Params {
+	params := backtest.ParamsFromConfig(cfg)
+	params.Risk = opts.Risk
+	params.InitialEquity = opts.Equity
+	return params
+}
+
+// configFor returns the base settings with the varied parameters set to values
+func configFor(opts Options, values []float64) loadenv.StrategyConfig {
+	cfg := opts.Base
+	for i, r := range opts.Ranges {
+		parameters[r.Name].set(&cfg, values[i])
+	}
+	return cfg
+}
+
+// rank sorts the runs best first and numbers the ones with enough trades
+func rank(runs []Run, opts Options) {
+	qualified := func(r Run) bool { return r.Metrics.Trades >= opts.MinTrades && r.Metrics.Trades > 0 }
+	sort.SliceStable(runs, func(i, j int) bool {
+		if qi, qj := qualified(runs[i]), qualified(runs[j]); qi != qj {
+			return qi
+		}
+		return opts.Objective.Better(runs[i].Score, runs[j].Score)
+	})
+	for i := range runs {
+		if qualified(runs[i]) {
+			runs[i].Rank = i + 1
+		}
+	}
+}
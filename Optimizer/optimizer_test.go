@@ -0,0 +1,205 @@
+package optimizer
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	loadenv "learnGoLang/LoadEnv"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waveCandles builds n 15m candles following two overlapping sine waves
+func waveCandles(n int) []klinesfrombinance.Candle {
+	start := time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC).UnixMilli()
+	candles := make([]klinesfrombinance.Candle, n)
+	for i := range candles {
+		price := 2000 + 100*math.Sin(float64(i)/40) + 10*math.Sin(float64(i)/3)
+		candles[i] = klinesfrombinance.NewCandle("ETHUSDT", start+int64(i)*15*60*1000, price, price+3, price-3, price+1, 1)
+	}
+	return candles
+}
+
+func baseConfig() loadenv.StrategyConfig {
+	return loadenv.StrategyConfig{Name: "macd", FastLength: 12, SlowLength: 26, SignalLength: 9, EntryTFMinutes: 15, CommissionPercent: 0.1}
+}
+
+func mustRange(t *testing.T, s string) Range {
+	t.Helper()
+	r, err := ParseRange(s)
+	if err != nil {
+		t.Fatalf("ParseRange(%q) returned error: %v", s, err)
+	}
+	return r
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"FAST_LENGTH=8:14:2", "[8 10 12 14]"},
+		{"stop_loss_pct=0.1:0.3:0.1", "[0.1 0.2 0.3]"},
+		{"SLOW_LENGTH=20, 26,30", "[20 26 30]"},
+		{"TAKE_PROFIT_PCT=4", "[4]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(mustRange(t, tt.in).Values); got != tt.want {
+			t.Errorf("ParseRange(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"FAST_LENGTH", "SYMBOL=1:2:1", "FAST_LENGTH=8:10:0.5", "STOP_LOSS_PCT=3:1:1", "STOP_LOSS_PCT=a,b"} {
+		if _, err := ParseRange(bad); err == nil {
+			t.Errorf("ParseRange(%q) accepted an invalid range", bad)
+		}
+	}
+}
+
+func TestOptimizeRanksEveryCombination(t *testing.T) {
+	candles := waveCandles(3000)
+	opts := Options{
+		Base:      baseConfig(),
+		Ranges:    []Range{mustRange(t, "FAST_LENGTH=8:16:4"), mustRange(t, "SLOW_LENGTH=12:24:6"), mustRange(t, "STOP_LOSS_PCT=0,1")},
+		Workers:   4,
+		Objective: ProfitFactor,
+		MinTrades: 1,
+	}
+	report, err := Optimize(context.Background(), candles, opts)
+	if err != nil {
+		t.Fatalf("Optimize returned error: %v", err)
+	}
+	// FAST_LENGTH 12 and 16 with SLOW_LENGTH=12 are rejected by the strategy
+	if len(report.Runs) != 14 || report.Skipped != 4 {
+		t.Fatalf("got %d runs and %d skipped, want 14 and 4", len(report.Runs), report.Skipped)
+	}
+	for i, run := range report.Runs {
+		if run.Rank != i+1 {
+			t.Errorf("run %d has rank %d", i, run.Rank)
+		}
+		if i > 0 && opts.Objective.Better(run.Score, report.Runs[i-1].Score) {
+			t.Errorf("run %d (%f) ranks below a worse run (%f)", i, run.Score, report.Runs[i-1].Score)
+		}
+	}
+
+	// The result must not depend on the number of workers
+	opts.Workers = 1
+	serial, _ := Optimize(context.Background(), candles, opts)
+	if fmt.Sprint(serial.Runs) != fmt.Sprint(report.Runs) {
+		t.Error("serial and parallel optimizations differ")
+	}
+}
+
+func TestOptimizeRandomSearch(t *testing.T) {
+	opts := Options{
+		Base:      baseConfig(),
+		Ranges:    []Range{mustRange(t, "FAST_LENGTH=4:10:1"), mustRange(t, "SLOW_LENGTH=20:40:2")},
+		Random:    10,
+		Seed:      7,
+		Objective: MaxDrawdown,
+	}
+	candles := waveCandles(1500)
+	first, err := Optimize(context.Background(), candles, opts)
+	if err != nil {
+		t.Fatalf("Optimize returned error: %v", err)
+	}
+	if len(first.Runs) != 10 {
+		t.Fatalf("got %d runs, want 10", len(first.Runs))
+	}
+	again, _ := Optimize(context.Background(), candles, opts)
+	if fmt.Sprint(first.Runs) != fmt.Sprint(again.Runs) {
+		t.Error("the same seed gave different samples")
+	}
+}
+
+func TestWriteHeatmapKeepsBestScore(t *testing.T) {
+	ranges := []Range{{Name: "FAST_LENGTH", Values: []float64{8, 12}}, {Name: "SLOW_LENGTH", Values: []float64{26}}, {Name: "STOP_LOSS_PCT", Values: []float64{1, 2}}}
+	report := Report{Runs: []Run{
+		{Values: []float64{8, 26, 1}, Score: 1.5, Rank: 1},
+		{Values: []float64{8, 26, 2}, Score: 0.5, Rank: 2},
+		{Values: []float64{12, 26, 1}, Score: 0.2}, // Not ranked
+	}}
+	path := filepath.Join(t.TempDir(), "heatmap.csv")
+	if err := WriteHeatmap(path, ranges, report, "fast_length", "SLOW_LENGTH", Sharpe); err != nil {
+		t.Fatalf("WriteHeatmap returned error: %v", err)
+	}
+
+	file, _ := os.Open(path)
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read heatmap: %v", err)
+	}
+	want := "[[FAST_LENGTH SLOW_LENGTH sharpe] [8 26 1.5000] [12 26 ]]"
+	if fmt.Sprint(rows) != want {
+		t.Errorf("heatmap = %v, want %s", rows, want)
+	}
+
+	if err := WriteHeatmap(path, ranges, report, "FAST_LENGTH", "SIGNAL_LENGTH", Sharpe); err == nil {
+		t.Error("expected an error for an axis that was not optimized")
+	}
+}
+
+func TestWalkForwardStitchesOutOfSampleWindows(t *testing.T) {
+	candles := waveCandles(20 * 96) // 20 days
+	opts := WalkForwardOptions{
+		Options: Options{
+			Base:      baseConfig(),
+			Ranges:    []Range{mustRange(t, "FAST_LENGTH=4,8"), mustRange(t, "SLOW_LENGTH=16,24")},
+			Objective: TotalReturn,
+			MinTrades: 1,
+		},
+		InSample:    6 * 24 * time.Hour,
+		OutOfSample: 2 * 24 * time.Hour,
+	}
+	report, err := WalkForward(context.Background(), candles, opts)
+	if err != nil {
+		t.Fatalf("WalkForward returned error: %v", err)
+	}
+	if len(report.Windows) != 7 {
+		t.Fatalf("got %d windows, want 7", len(report.Windows))
+	}
+
+	equity := 1.0
+	for i, w := range report.Windows {
+		if i > 0 && !w.OutOfSampleStart.Equal(report.Windows[i-1].End) {
+			t.Errorf("window %d starts at %s, not where the previous one ended", i+1, w.OutOfSampleStart)
+		}
+		if w.Values == nil {
+			t.Fatalf("window %d was not traded", i+1)
+		}
+		for _, trade := range w.Trades {
+			if trade.EntryTime.Before(w.OutOfSampleStart) || !trade.ExitTime.Before(w.End) {
+				t.Errorf("window %d trade %s - %s is outside its out-of-sample window", i+1, trade.EntryTime, trade.ExitTime)
+			}
+		}
+		equity *= 1 + w.OutOfSample.TotalReturnPct/100
+	}
+	last := report.Equity[len(report.Equity)-1].Equity
+	if math.Abs(last-equity) > 1e-9 || math.Abs(report.OutOfSample.TotalReturnPct-(equity-1)*100) > 1e-9 {
+		t.Errorf("stitched equity %f and return %f%%, want %f", last, report.OutOfSample.TotalReturnPct, equity)
+	}
+	if len(report.Stability) != 2 || report.Stability[0].Name != "FAST_LENGTH" || report.Stability[0].Changes > 6 {
+		t.Errorf("unexpected stability %+v", report.Stability)
+	}
+
+	opts.InSample = 30 * 24 * time.Hour
+	if _, err := WalkForward(context.Background(), candles, opts); err == nil {
+		t.Error("expected an error when the history is shorter than one window")
+	}
+}
+
+func TestEfficiency(t *testing.T) {
+	day := 24 * time.Hour
+	// 10% in 30 days against 20% in 90 days is 1.5 times the in-sample pace
+	if got := efficiency(10, 30*day, 20, 90*day); math.Abs(got-150) > 1e-9 {
+		t.Errorf("efficiency = %f, want 150", got)
+	}
+	if got := efficiency(10, 30*day, -5, 90*day); !math.IsNaN(got) {
+		t.Errorf("efficiency with a losing in-sample run = %f, want NaN", got)
+	}
+}
@@ -0,0 +1,197 @@
+package optimizer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// timeLayout is the time format of the trade log
+const timeLayout = "2006-01-02 15:04:05"
+
+// OutputPaths returns the results table and heatmap paths next to the trade log,
+// e.g. trades.csv gives trades_optimize.csv and trades_heatmap.csv
+func OutputPaths(outputFileName string) (results, heatmap string) {
+	base := strings.TrimSuffix(outputFileName, filepath.Ext(outputFileName))
+	return base + "_optimize.csv", base + "_heatmap.csv"
+}
+
+// WalkForwardPaths returns the window table and stitched equity curve paths next to the
+// trade log, e.g. trades.csv gives trades_walkforward.csv and trades_walkforward_equity.csv
+func WalkForwardPaths(outputFileName string) (windows, equity string) {
+	base := strings.TrimSuffix(outputFileName, filepath.Ext(outputFileName))
+	return base + "_walkforward.csv", base + "_walkforward_equity.csv"
+}
+
+// WriteResults writes one row per run, best first, with the parameter values and metrics
+func WriteResults(path string, ranges []Range, report Report) error {
+	header := []string{"rank"}
+	for _, r := range ranges {
+		header = append(header, r.Name)
+	}
+	header = append(header, "trades", "win_rate_pct", "total_return_pct", "profit_factor", "max_drawdown_pct", "sharpe", "score")
+
+	rows := [][]string{header}
+	for _, run := range report.Runs {
+		row := []string{""}
+		if run.Rank > 0 {
+			row[0] = strconv.Itoa(run.Rank)
+		}
+		for _, v := range run.Values {
+			row = append(row, formatFloat(v))
+		}
+		m := run.Metrics
+		row = append(row,
+			strconv.Itoa(m.Trades),
+			formatMetric(m.WinRatePct),
+			formatMetric(m.TotalReturnPct),
+			formatMetric(m.ProfitFactor),
+			formatMetric(m.MaxDrawdownPct),
+			formatMetric(m.Sharpe),
+			formatMetric(run.Score),
+		)
+		rows = append(rows, row)
+	}
+	return writeCSV(path, rows)
+}
+
+// WriteHeatmap writes the best ranked score of every (x, y) pair of values, over all
+// values of the other parameters, as x,y,score rows ready to pivot into a heatmap.
+// Pairs without a ranked run are left empty.
+func WriteHeatmap(path string, ranges []Range, report Report, x, y string, objective Objective) error {
+	xi, yi := rangeIndex(ranges, x), rangeIndex(ranges, y)
+	if xi < 0 || yi < 0 || xi == yi {
+		return fmt.Errorf("heatmap axes must be two different optimized parameters, got %s and %s", x, y)
+	}
+
+	type cell struct{ x, y float64 }
+	best := make(map[cell]float64)
+	for _, run := range report.Runs {
+		if run.Rank == 0 {
+			continue
+		}
+		c := cell{run.Values[xi], run.Values[yi]}
+		if score, ok := best[c]; !ok || objective.Better(run.Score, score) {
+			best[c] = run.Score
+		}
+	}
+
+	rows := [][]string{{ranges[xi].Name, ranges[yi].Name, string(objective)}}
+	xs := sortedValues(ranges[xi].Values)
+	ys := sortedValues(ranges[yi].Values)
+	for _, xv := range xs {
+		for _, yv := range ys {
+			value := ""
+			if score, ok := best[cell{xv, yv}]; ok {
+				value = formatMetric(score)
+			}
+			rows = append(rows, []string{formatFloat(xv), formatFloat(yv), value})
+		}
+	}
+	return writeCSV(path, rows)
+}
+
+// WriteWindows writes one row per walk-forward window with its best in-sample parameters
+// and both the in-sample and out-of-sample metrics. Windows that were not traded have
+// empty parameters.
+func WriteWindows(path string, ranges []Range, report WalkForwardReport) error {
+	header := []string{"window", "in_sample_start", "out_of_sample_start", "end"}
+	for _, r := range ranges {
+		header = append(header, r.Name)
+	}
+	header = append(header, "in_sample_trades", "in_sample_return_pct", "in_sample_score",
+		"out_of_sample_trades", "out_of_sample_return_pct", "out_of_sample_max_drawdown_pct", "efficiency_pct")
+
+	rows := [][]string{header}
+	for i, w := range report.Windows {
+		row := []string{
+			strconv.Itoa(i + 1),
+			w.InSampleStart.Format(timeLayout),
+			w.OutOfSampleStart.Format(timeLayout),
+			w.End.Format(timeLayout),
+		}
+		for p := range ranges {
+			value := ""
+			if w.Values != nil {
+				value = formatFloat(w.Values[p])
+			}
+			row = append(row, value)
+		}
+		if w.Values == nil {
+			row = append(row, "", "", "", "", "", "", "")
+		} else {
+			row = append(row,
+				strconv.Itoa(w.InSample.Trades),
+				formatMetric(w.InSample.TotalReturnPct),
+				formatMetric(w.InSampleScore),
+				strconv.Itoa(w.OutOfSample.Trades),
+				formatMetric(w.OutOfSample.TotalReturnPct),
+				formatMetric(w.OutOfSample.MaxDrawdownPct),
+				formatMetric(w.Efficiency),
+			)
+		}
+		rows = append(rows, row)
+	}
+	return writeCSV(path, rows)
+}
+
+// WriteEquity writes the stitched out-of-sample equity curve
+func WriteEquity(path string, report WalkForwardReport) error {
+	rows := [][]string{{"time", "window", "equity"}}
+	for _, p := range report.Equity {
+		rows = append(rows, []string{p.Time.Format(timeLayout), strconv.Itoa(p.Window), strconv.FormatFloat(p.Equity, 'f', 6, 64)})
+	}
+	return writeCSV(path, rows)
+}
+
+func rangeIndex(ranges []Range, name string) int {
+	for i, r := range ranges {
+		if r.Name == strings.ToUpper(name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func sortedValues(values []float64) []float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// formatFloat writes a parameter value in its shortest exact form
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// formatMetric writes a metric with 4 decimals, "inf" for an infinite profit factor and
+// nothing for an undefined one
+func formatMetric(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsNaN(v):
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
+
+func writeCSV(path string, rows [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.WriteAll(rows)
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package optimizer
+
+import (
+	"context"
+	"fmt"
+	backtest "learnGoLang/Backtest"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	strategy "learnGoLang/Strategy"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+// year is the length of a year used to annualize returns
+const year = 365 * 24 * time.Hour
+
+// WalkForwardOptions configures a walk-forward analysis
+type WalkForwardOptions struct {
+	Options
+	InSample    time.Duration // Window the parameters are optimized on
+	OutOfSample time.Duration // Following window they are tested on, also the step the windows roll by
+}
+
+// Window is one in-sample optimization and the out-of-sample test of its best parameters
+type Window struct {
+	InSampleStart    time.Time
+	OutOfSampleStart time.Time
+	End              time.Time
+	Values           []float64 // Best in-sample parameters, nil when no run had enough trades
+	InSample         backtest.Metrics
+	InSampleScore    float64
+	OutOfSample      backtest.Metrics
+	Trades           []backtest.Trade // Out-of-sample trades
+	Efficiency       float64          // Annualized out-of-sample / in-sample return in percent, NaN when undefined
+}
+
+// EquityPoint is the stitched out-of-sample equity at the start of a window or after a trade
+type EquityPoint struct {
+	Time   time.Time
+	Window int
+	Equity float64 // Starts at 1
+}
+
+// Stability describes how much the best value of a parameter moves between windows
+type Stability struct {
+	Name    string
+	Mean    float64
+	StdDev  float64
+	Changes int // Windows whose value differs from the previous window's
+}
+
+// WalkForwardReport holds the windows of a walk-forward analysis and their summary
+type WalkForwardReport struct {
+	Windows     []Window
+	Equity      []EquityPoint
+	OutOfSample backtest.Metrics // Of all out-of-sample trades together
+	Efficiency  float64          // Mean annualized out-of-sample / in-sample return in percent, NaN when undefined
+	Stability   []Stability
+}
+
+// WalkForward optimizes the ranges on a rolling in-sample window and backtests the best
+// parameters on the out-of-sample window right after it. The strategy of each test is
+// warmed up on its in-sample candles, so its indicators are ready when trading starts.
+func WalkForward(ctx context.Context, candles []klinesfrombinance.Candle, opts WalkForwardOptions) (WalkForwardReport, error) {
+	if opts.InSample <= 0 || opts.OutOfSample <= 0 {
+		return WalkForwardReport{}, fmt.Errorf("in-sample and out-of-sample windows must be positive")
+	}
+	if len(candles) == 0 {
+		return WalkForwardReport{}, fmt.Errorf("no candles to backtest")
+	}
+	entryInterval := time.Duration(opts.Base.EntryTFMinutes) * time.Minute
+	first := time.UnixMilli(candles[0].Timestamp).UTC()
+	last := time.UnixMilli(candles[len(candles)-1].Timestamp).UTC().Add(entryInterval)
+
+	var windows []Window
+	for start := first; !start.Add(opts.InSample + opts.OutOfSample).After(last); start = start.Add(opts.OutOfSample) {
+		windows = append(windows, Window{
+			InSampleStart:    start,
+			OutOfSampleStart: start.Add(opts.InSample),
+			End:              start.Add(opts.InSample + opts.OutOfSample),
+			Efficiency:       math.NaN(),
+		})
+	}
+	if len(windows) == 0 {
+		return WalkForwardReport{}, fmt.Errorf("history from %s to %s is shorter than one in-sample and out-of-sample window",
+			first.Format("2006-01-02"), last.Format("2006-01-02"))
+	}
+
+	optimizeOpts := opts.Options
+	optimizeOpts.Quiet = true
+	for i := range windows {
+		w := &windows[i]
+		inSample := between(candles, w.InSampleStart, w.OutOfSampleStart)
+		outOfSample := between(candles, w.OutOfSampleStart, w.End)
+		if len(inSample) == 0 || len(outOfSample) == 0 {
+			log.Printf("[%d/%d] no candles between %s and %s, window skipped", i+1, len(windows),
+				w.InSampleStart.Format("2006-01-02"), w.End.Format("2006-01-02"))
+			continue
+		}
+
+		report, err := Optimize(ctx, inSample, optimizeOpts)
+		if err != nil {
+			return WalkForwardReport{}, err
+		}
+		if len(report.Runs) == 0 || report.Runs[0].Rank != 1 {
+			log.Printf("[%d/%d] no in-sample run up to %s had %d trades, out-of-sample window not traded", i+1, len(windows),
+				w.OutOfSampleStart.Format("2006-01-02"), opts.MinTrades)
+			continue
+		}
+		best := report.Runs[0]
+		w.Values, w.InSample, w.InSampleScore = best.Values, best.Metrics, best.Score
+
+		cfg := configFor(opts.Options, best.Values)
+		strat, err := strategy.New(cfg)
+		if err != nil {
+			return WalkForwardReport{}, err
+		}
+		for _, c := range inSample {
+			strat.OnCandle(c)
+		}
+		result, err := backtest.Run(outOfSample, strat, paramsFor(opts.Options, cfg))
+		if err != nil {
+			return WalkForwardReport{}, err
+		}
+		w.Trades = result.Trades
+		w.OutOfSample = backtest.ComputeMetrics(result, w.OutOfSampleStart, w.End)
+		w.Efficiency = efficiency(w.OutOfSample.TotalReturnPct, opts.OutOfSample, w.InSample.TotalReturnPct, opts.InSample)
+		log.Printf("[%d/%d] out-of-sample %s to %s: %d trades, return %.2f%% (in-sample %.2f%%)", i+1, len(windows),
+			w.OutOfSampleStart.Format("2006-01-02"), w.End.Format("2006-01-02"), w.OutOfSample.Trades,
+			w.OutOfSample.TotalReturnPct, w.InSample.TotalReturnPct)
+	}
+
+	return summarize(windows, opts), nil
+}
+
+// between returns the candles opening in [from, to). The candles are sorted by time.
+func between(candles []klinesfrombinance.Candle, from, to time.Time) []klinesfrombinance.Candle {
+	i := sort.Search(len(candles), func(i int) bool { return candles[i].Timestamp >= from.UnixMilli() })
+	j := sort.Search(len(candles), func(i int) bool { return candles[i].Timestamp >= to.UnixMilli() })
+	return candles[i:j]
+}
+
+// efficiency returns the annualized out-of-sample return relative to the annualized
+// in-sample return, in percent. It is undefined unless the in-sample return is positive.
+func efficiency(outReturn float64, outPeriod time.Duration, inReturn float64, inPeriod time.Duration) float64 {
+	annualizedIn := inReturn * float64(year) / float64(inPeriod)
+	if annualizedIn <= 0 {
+		return math.NaN()
+	}
+	return outReturn * float64(year) / float64(outPeriod) / annualizedIn * 100
+}
+
+// summarize stitches the out-of-sample equity curves and measures the overall efficiency
+// and the parameter stability over the windows that were traded
+func summarize(windows []Window, opts WalkForwardOptions) WalkForwardReport {
+	report := WalkForwardReport{Windows: windows, Efficiency: math.NaN()}
+
+	var all backtest.Result
+	equity := 1.0
+	inReturn, outReturn, traded := 0.0, 0.0, 0
+	for i, w := range windows {
+		report.Equity = append(report.Equity, EquityPoint{Time: w.OutOfSampleStart, Window: i + 1, Equity: equity})
+		for _, t := range w.Trades {
+			equity *= 1 + t.ReturnPct/100
+			report.Equity = append(report.Equity, EquityPoint{Time: t.ExitTime, Window: i + 1, Equity: equity})
+			if t.PnL > 0 {
+				all.Wins++
+			} else {
+				all.Losses++
+			}
+		}
+		all.Trades = append(all.Trades, w.Trades...)
+		if w.Values != nil {
+			inReturn += w.InSample.TotalReturnPct
+			outReturn += w.OutOfSample.TotalReturnPct
+			traded++
+		}
+	}
+	all.TotalReturnPct = (equity - 1) * 100
+	report.OutOfSample = backtest.ComputeMetrics(all, windows[0].OutOfSampleStart, windows[len(windows)-1].End)
+	if traded > 0 {
+		report.Efficiency = efficiency(outReturn/float64(traded), opts.OutOfSample, inReturn/float64(traded), opts.InSample)
+	}
+
+	for p, r := range opts.Ranges {
+		s := Stability{Name: r.Name}
+		var values []float64
+		for _, w := range windows {
+			if w.Values == nil {
+				continue
+			}
+			if len(values) > 0 && w.Values[p] != values[len(values)-1] {
+				s.Changes++
+			}
+			values = append(values, w.Values[p])
+		}
+		for _, v := range values {
+			s.Mean += v / float64(len(values))
+		}
+		for _, v := range values {
+			s.StdDev += (v - s.Mean) * (v - s.Mean) / float64(len(values))
+		}
+		s.StdDev = math.Sqrt(s.StdDev)
+		report.Stability = append(report.Stability, s)
+	}
+	return report
+}
@@ -0,0 +1,262 @@
+package positionmanager
+
+import (
+	"errors"
+	"fmt"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	loadenv "learnGoLang/LoadEnv"
+	strategy "learnGoLang/Strategy"
+	"math"
+	"strings"
+	"time"
+)
+
+// Exit reasons of the positions the manager closes
+const (
+	ExitStopLoss     = "stop_loss"
+	ExitTakeProfit   = "take_profit"
+	ExitTrailingStop = "trailing_stop"
+	ExitSignal       = "signal" // Used when the strategy gives no reason
+	ExitMaxHold      = "max_hold"
+)
+
+// IntrabarPolicy decides which level a candle reached first when its range touched both
+// the stop and the take profit. A candle that opens beyond one of them always takes that one.
+type IntrabarPolicy string
+
+const (
+	StopFirst    IntrabarPolicy = "stop_first"    // Assume the worst case
+	TargetFirst  IntrabarPolicy = "target_first"  // Assume the best case
+	NearestFirst IntrabarPolicy = "nearest_first" // The level closer to the open
+)
+
+// ErrStopTooWide is returned for entries whose stop is further than MaxAllowedSLPct
+var ErrStopTooWide = errors.New("stop loss is further than the allowed distance")
+
+// Config holds the risk settings of the open positions
+type Config struct {
+	StopLossPct          float64
+	TakeProfitPct        float64
+	TrailingStopPct      float64        // 0 disables the trailing stop
+	MaxAllowedSLPct      float64        // Entries with a wider stop are refused, 0 disables the guard
+	MaxPositionHoldHours int            // 0 disables the time-based exit
+	Intrabar             IntrabarPolicy // Empty means StopFirst
+}
+
+// ConfigFromStrategy reads the position settings of the strategy configuration
+func ConfigFromStrategy(cfg loadenv.StrategyConfig) Config {
+	return Config{
+		StopLossPct:          cfg.StopLossPct,
+		TakeProfitPct:        cfg.TakeProfitPct,
+		TrailingStopPct:      cfg.TrailingStopPct,
+		MaxAllowedSLPct:      cfg.MaxAllowedSLPct,
+		MaxPositionHoldHours: cfg.MaxPositionHoldHours,
+		Intrabar:             IntrabarPolicy(strings.ToLower(cfg.IntrabarPolicy)),
+	}
+}
+
+// Validate checks the settings
+func (c Config) Validate() error {
+	if c.StopLossPct < 0 || c.TakeProfitPct < 0 || c.TrailingStopPct < 0 || c.MaxAllowedSLPct < 0 {
+		return fmt.Errorf("stop loss, take profit and trailing stop percentages must not be negative")
+	}
+	if c.MaxPositionHoldHours < 0 {
+		return fmt.Errorf("MAX_POSITION_HOLD_HOURS must not be negative")
+	}
+	if c.MaxAllowedSLPct > 0 && c.StopLossPct > c.MaxAllowedSLPct {
+		return fmt.Errorf("STOP_LOSS_PCT %g is wider than MAX_ALLOWED_SL_PCT %g, every entry would be refused", c.StopLossPct, c.MaxAllowedSLPct)
+	}
+	switch c.Intrabar {
+	case "", StopFirst, TargetFirst, NearestFirst:
+		return nil
+	}
+	return fmt.Errorf("invalid INTRABAR_POLICY '%s' (expected stop_first, target_first or nearest_first)", c.Intrabar)
+}
+
+// Position is the open position tracked by a manager
+type Position struct {
+	Side       int // 1 for long, -1 for short
+	EntryTime  time.Time
+	EntryPrice float64
+	StopLoss   float64 // 0 without a fixed stop
+	TakeProfit float64 // 0 without a target
+	Extreme    float64 // High-water mark: highest high of a long, lowest low of a short
+}
+
+// Exit is a close the manager decided on
+type Exit struct {
+	Price  float64 // Fill price before slippage: the level of a stop or target, worse when the candle gapped through it, or the close
+	Reason string
+}
+
+// Manager applies the stops, the target and the holding time limit to one position at a
+// time. The backtest and the live trader both feed it the closed entry timeframe
+// candles, so positions are closed the same way in every mode.
+type Manager struct {
+	cfg Config
+	pos *Position
+}
+
+// NewManager creates a manager without an open position
+func NewManager(cfg Config) *Manager {
+	return &Manager{cfg: cfg}
+}
+
+// Position returns the open position, nil when flat
+func (m *Manager) Position() *Position {
+	return m.pos
+}
+
+// CheckEntry refuses an entry of side at price whose structural stop is on the wrong side
+// or further than MaxAllowedSLPct. A stop of 0 means the fixed StopLossPct, which
+// Validate already compared with the limit.
+func (m *Manager) CheckEntry(side int, price, stop float64) error {
+	if stop <= 0 {
+		return nil
+	}
+	if float64(side)*(price-stop) <= 0 {
+		return fmt.Errorf("stop %.2f is on the wrong side of the entry at %.2f", stop, price)
+	}
+	if distance := math.Abs(price-stop) / price * 100; m.cfg.MaxAllowedSLPct > 0 && distance > m.cfg.MaxAllowedSLPct {
+		return fmt.Errorf("%w: %.2f%% from %.2f to %.2f, at most %.2f%%", ErrStopTooWide, distance, price, stop, m.cfg.MaxAllowedSLPct)
+	}
+	return nil
+}
+
+// StopLevel returns the stop of an entry of side at price: the structural stop of the
+// signal, or StopLossPct away from the price when stop is 0. 0 means no stop.
+func (m *Manager) StopLevel(side int, price, stop float64) float64 {
+	if stop <= 0 && m.cfg.StopLossPct > 0 {
+		return price * (1 - float64(side)*m.cfg.StopLossPct/100)
+	}
+	return stop
+}
+
+// Open starts tracking a filled entry. stop is the structural stop of the signal, 0 places
+// the stop StopLossPct away from the fill.
+func (m *Manager) Open(side int, at time.Time, price, stop float64) {
+	pos := &Position{Side: side, EntryTime: at, EntryPrice: price, StopLoss: m.StopLevel(side, price, stop), Extreme: price}
+	if m.cfg.TakeProfitPct > 0 {
+		pos.TakeProfit = price * (1 + float64(side)*m.cfg.TakeProfitPct/100)
+	}
+	m.pos = pos
+}
+
+// Close forgets the position once its exit filled
+func (m *Manager) Close() {
+	m.pos = nil
+}
+
+// Check returns the exit of the open position on a closed candle, in order of
+// precedence: the stop or the take profit, resolved by the intrabar policy when the
+// candle touched both, an exit signal of the strategy and the holding time limit.
+// Without an exit the high-water mark moves.
+func (m *Manager) Check(c klinesfrombinance.Candle, signals []strategy.Signal) (Exit, bool) {
+	if m.pos == nil {
+		return Exit{}, false
+	}
+	if exit, ok := m.update(c); ok {
+		return exit, true
+	}
+	for _, sig := range signals {
+		if sig.Action != strategy.Exit {
+			continue
+		}
+		reason := sig.Reason
+		if reason == "" {
+			reason = ExitSignal
+		}
+		return Exit{Price: c.Close, Reason: reason}, true
+	}
+	if m.cfg.MaxPositionHoldHours > 0 && c.Datetime.Sub(m.pos.EntryTime) >= time.Duration(m.cfg.MaxPositionHoldHours)*time.Hour {
+		return Exit{Price: c.Close, Reason: ExitMaxHold}, true
+	}
+	return Exit{}, false
+}
+
+// update checks the candle against the stop and the take profit and otherwise moves the
+// high-water mark
+func (m *Manager) update(c klinesfrombinance.Candle) (Exit, bool) {
+	pos := m.pos
+	stop, reason := pos.stopLevel(m.cfg.TrailingStopPct)
+	stopHit := stop > 0 && pos.reachedAdverse(c, stop)
+	targetHit := pos.TakeProfit > 0 && pos.reachedFavorable(c, pos.TakeProfit)
+	if stopHit && targetHit && !m.stopFirst(c, stop) {
+		stopHit = false
+	}
+	switch {
+	case stopHit:
+		return Exit{Price: pos.adverseFill(c, stop), Reason: reason}, true
+	case targetHit:
+		return Exit{Price: pos.favorableFill(c, pos.TakeProfit), Reason: ExitTakeProfit}, true
+	case pos.Side > 0:
+		pos.Extreme = math.Max(pos.Extreme, c.High)
+	default:
+		pos.Extreme = math.Min(pos.Extreme, c.Low)
+	}
+	return Exit{}, false
+}
+
+// stopFirst decides whether a candle that touched both the stop and the target reached
+// the stop first
+func (m *Manager) stopFirst(c klinesfrombinance.Candle, stop float64) bool {
+	side := float64(m.pos.Side)
+	switch {
+	case side*(c.Open-stop) <= 0: // Opened through the stop
+		return true
+	case side*(c.Open-m.pos.TakeProfit) >= 0: // Opened through the target
+		return false
+	}
+	switch m.cfg.Intrabar {
+	case TargetFirst:
+		return false
+	case NearestFirst:
+		return math.Abs(c.Open-stop) <= math.Abs(m.pos.TakeProfit-c.Open)
+	}
+	return true
+}
+
+// stopLevel returns the active stop, the tighter of the fixed and the trailing stop,
+// together with the exit reason to report if it is hit. 0 means no stop.
+func (pos *Position) stopLevel(trailingPct float64) (float64, string) {
+	if trailingPct <= 0 {
+		return pos.StopLoss, ExitStopLoss
+	}
+	trailing := pos.Extreme * (1 - float64(pos.Side)*trailingPct/100)
+	if pos.StopLoss == 0 || float64(pos.Side)*(trailing-pos.StopLoss) > 0 {
+		return trailing, ExitTrailingStop
+	}
+	return pos.StopLoss, ExitStopLoss
+}
+
+// reachedAdverse reports whether the candle traded through a level against the position
+func (pos *Position) reachedAdverse(c klinesfrombinance.Candle, level float64) bool {
+	if pos.Side > 0 {
+		return c.Low <= level
+	}
+	return c.High >= level
+}
+
+// reachedFavorable reports whether the candle traded through a level in favor of the position
+func (pos *Position) reachedFavorable(c klinesfrombinance.Candle, level float64) bool {
+	if pos.Side > 0 {
+		return c.High >= level
+	}
+	return c.Low <= level
+}
+
+// adverseFill is the fill price of a stop, which is worse than the level when the candle gaps through it
+func (pos *Position) adverseFill(c klinesfrombinance.Candle, level float64) float64 {
+	if pos.Side > 0 {
+		return math.Min(level, c.Open)
+	}
+	return math.Max(level, c.Open)
+}
+
+// favorableFill is the fill price of a target, which is better than the level when the candle gaps through it
+func (pos *Position) favorableFill(c klinesfrombinance.Candle, level float64) float64 {
+	if pos.Side > 0 {
+		return math.Max(level, c.Open)
+	}
+	return math.Min(level, c.Open)
+}
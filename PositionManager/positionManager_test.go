@@ -0,0 +1,128 @@
+package positionmanager
+
+import (
+	"errors"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	strategy "learnGoLang/Strategy"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC)
+
+// bar builds the i-th 15m candle after start
+func bar(i int, open, high, low, close float64) klinesfrombinance.Candle {
+	return klinesfrombinance.NewCandle("ETHUSDT", start.Add(time.Duration(i)*15*time.Minute).UnixMilli(), open, high, low, close, 1)
+}
+
+func TestIntrabarPolicy(t *testing.T) {
+	// The candle touches the stop at 98 and the target at 102 of a long from 100
+	tests := []struct {
+		policy IntrabarPolicy
+		open   float64
+		want   string
+	}{
+		{StopFirst, 100.5, ExitStopLoss},
+		{"", 100.5, ExitStopLoss},
+		{TargetFirst, 100.5, ExitTakeProfit},
+		{NearestFirst, 100.5, ExitTakeProfit},
+		{NearestFirst, 99, ExitStopLoss},
+		{TargetFirst, 97, ExitStopLoss},    // Opened through the stop
+		{StopFirst, 102.5, ExitTakeProfit}, // Opened through the target
+	}
+	for _, tt := range tests {
+		m := NewManager(Config{StopLossPct: 2, TakeProfitPct: 2, Intrabar: tt.policy})
+		m.Open(1, start, 100, 0)
+		exit, ok := m.Check(bar(1, tt.open, 103, 96, 100), nil)
+		if !ok || exit.Reason != tt.want {
+			t.Errorf("policy %q with open %.1f: got %+v, want %s", tt.policy, tt.open, exit, tt.want)
+		}
+	}
+}
+
+func TestTrailingStopFollowsTheHighWaterMark(t *testing.T) {
+	m := NewManager(Config{StopLossPct: 5, TrailingStopPct: 2})
+	m.Open(1, start, 100, 0)
+	for i, c := range []klinesfrombinance.Candle{bar(1, 100, 104, 99, 103), bar(2, 103, 110, 102, 109), bar(3, 109, 109.5, 108, 109)} {
+		if exit, ok := m.Check(c, nil); ok {
+			t.Fatalf("bar %d exited early: %+v", i+1, exit)
+		}
+	}
+	if got := m.Position().Extreme; got != 110 {
+		t.Fatalf("high-water mark %f, want 110", got)
+	}
+
+	// 2% below 110 is 107.8, tighter than the fixed stop at 95
+	exit, ok := m.Check(bar(4, 108, 108, 107, 107.5), nil)
+	if !ok || exit.Reason != ExitTrailingStop || exit.Price != 107.8 {
+		t.Errorf("got %+v, want the trailing stop at 107.8", exit)
+	}
+
+	// A short gapping above its stop fills at the open
+	m = NewManager(Config{StopLossPct: 5})
+	m.Open(-1, start, 100, 0)
+	exit, _ = m.Check(bar(1, 106, 107, 105, 106), nil)
+	if exit.Reason != ExitStopLoss || exit.Price != 106 {
+		t.Errorf("got %+v, want the stop filled at the open of 106", exit)
+	}
+}
+
+func TestCheckOrderOfExits(t *testing.T) {
+	m := NewManager(Config{StopLossPct: 1, MaxPositionHoldHours: 1})
+	m.Open(1, start, 100, 0)
+	signal := []strategy.Signal{{Action: strategy.EnterShort}, {Action: strategy.Exit}}
+
+	if exit, ok := m.Check(bar(1, 100, 101, 99.5, 100), nil); ok {
+		t.Fatalf("exited before the time limit: %+v", exit)
+	}
+	// The strategy's exit comes before the time limit and after the stop
+	if exit, _ := m.Check(bar(4, 100, 101, 99.5, 100.5), signal); exit.Reason != ExitSignal || exit.Price != 100.5 {
+		t.Errorf("got %+v, want the strategy exit at the close", exit)
+	}
+	if exit, _ := m.Check(bar(4, 100, 101, 99.5, 100.5), nil); exit.Reason != ExitMaxHold {
+		t.Errorf("got %+v, want the time limit", exit)
+	}
+	if exit, _ := m.Check(bar(4, 100, 101, 98, 100.5), signal); exit.Reason != ExitStopLoss {
+		t.Errorf("got %+v, want the stop", exit)
+	}
+
+	m.Close()
+	if _, ok := m.Check(bar(5, 100, 101, 90, 100), signal); ok {
+		t.Error("a flat manager returned an exit")
+	}
+}
+
+func TestStructuralStops(t *testing.T) {
+	m := NewManager(Config{StopLossPct: 1, MaxAllowedSLPct: 3})
+	if err := m.CheckEntry(1, 100, 96); !errors.Is(err, ErrStopTooWide) {
+		t.Errorf("4%% stop returned %v, want ErrStopTooWide", err)
+	}
+	if err := m.CheckEntry(-1, 100, 98); err == nil {
+		t.Error("a short stop below the entry was accepted")
+	}
+	if err := m.CheckEntry(1, 100, 97.5); err != nil {
+		t.Errorf("2.5%% stop refused: %v", err)
+	}
+
+	// The structural stop replaces the fixed one
+	m.Open(1, start, 100, 97.5)
+	if exit, ok := m.Check(bar(1, 100, 100, 98, 99), nil); ok {
+		t.Errorf("the fixed stop at 99 was used: %+v", exit)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, cfg := range []Config{
+		{StopLossPct: -1},
+		{MaxPositionHoldHours: -1},
+		{StopLossPct: 4, MaxAllowedSLPct: 3},
+		{Intrabar: "random"},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%+v passed validation", cfg)
+		}
+	}
+	if err := (Config{StopLossPct: 2, MaxAllowedSLPct: 3, Intrabar: NearestFirst}).Validate(); err != nil {
+		t.Errorf("valid config failed: %v", err)
+	}
+}
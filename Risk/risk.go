@@ -0,0 +1,285 @@
+package risk
+
+import (
+	"errors"
+	"fmt"
+	indicators "learnGoLang/Indicators"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	loadenv "learnGoLang/LoadEnv"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SizingModel decides how large an entry is
+type SizingModel string
+
+const (
+	FixedQuote       SizingModel = "fixed_quote"       // The same quote amount on every entry
+	FixedFraction    SizingModel = "fixed_fraction"    // A fraction of the equity
+	RiskPerTrade     SizingModel = "risk_per_trade"    // Lose RiskPct of the equity when the stop is hit
+	VolatilityTarget SizingModel = "volatility_target" // Lose RiskPct of the equity on a move of ATRMultiple ATRs
+	Kelly            SizingModel = "kelly"             // The Kelly fraction of the recent trades, capped at KellyCap
+)
+
+// kellyMinTrades is the number of closed trades the Kelly fraction is estimated from at
+// the least. Until then entries use half of KellyCap.
+const kellyMinTrades = 10
+
+// ErrRefused is returned for entries the sizing model or the portfolio limits do not allow
+var ErrRefused = errors.New("entry refused by risk management")
+
+// Config holds the position sizing model and the portfolio limits
+type Config struct {
+	Sizing               SizingModel // Empty invests the whole equity in every entry
+	FixedQuote           float64     // Quote amount of a fixed_quote entry
+	EquityFraction       float64     // Fraction of the equity invested by fixed_fraction, 0 < f <= 1
+	RiskPct              float64     // Percent of the equity at risk in risk_per_trade and volatility_target
+	ATRLength            int
+	ATRMultiple          float64 // Adverse move in ATRs that volatility_target sizes for
+	KellyCap             float64 // Largest fraction of the equity a Kelly entry uses
+	KellyLookback        int     // Closed trades the Kelly fraction is estimated from, 0 uses all of them
+	MaxPositions         int     // Open positions across every symbol, 0 disables the limit
+	MaxSymbolExposurePct float64 // Notional of one symbol in percent of the equity, 0 disables the limit
+	DailyLossLimitPct    float64 // Realized loss in percent of the equity at the start of the UTC day that stops entries until the next day, 0 disables the breaker
+}
+
+// ConfigFromEnv reads the risk settings of the configuration
+func ConfigFromEnv(cfg loadenv.RiskConfig) Config {
+	return Config{
+		Sizing:               SizingModel(strings.ToLower(cfg.Sizing)),
+		FixedQuote:           cfg.FixedQuote,
+		EquityFraction:       cfg.EquityFraction,
+		RiskPct:              cfg.RiskPerTradePct,
+		ATRLength:            cfg.ATRLength,
+		ATRMultiple:          cfg.ATRMultiple,
+		KellyCap:             cfg.KellyCap,
+		KellyLookback:        cfg.KellyLookback,
+		MaxPositions:         cfg.MaxPositions,
+		MaxSymbolExposurePct: cfg.MaxSymbolExposurePct,
+		DailyLossLimitPct:    cfg.DailyLossLimitPct,
+	}
+}
+
+// Validate checks the settings the sizing model needs and the limits
+func (c Config) Validate() error {
+	switch c.Sizing {
+	case "":
+	case FixedQuote:
+		if c.FixedQuote <= 0 {
+			return fmt.Errorf("fixed_quote sizing needs a positive SIZING_FIXED_QUOTE, got %g", c.FixedQuote)
+		}
+	case FixedFraction:
+		if c.EquityFraction <= 0 || c.EquityFraction > 1 {
+			return fmt.Errorf("invalid SIZING_EQUITY_FRACTION %g (expected 0 < value <= 1)", c.EquityFraction)
+		}
+	case RiskPerTrade, VolatilityTarget:
+		if c.RiskPct <= 0 || c.RiskPct >= 100 {
+			return fmt.Errorf("invalid RISK_PER_TRADE_PCT %g (expected 0 < value < 100)", c.RiskPct)
+		}
+		if c.Sizing == VolatilityTarget && (c.ATRLength <= 0 || c.ATRMultiple <= 0) {
+			return fmt.Errorf("volatility_target sizing needs a positive SIZING_ATR_LENGTH and SIZING_ATR_MULTIPLE")
+		}
+	case Kelly:
+		if c.KellyCap <= 0 || c.KellyCap > 1 {
+			return fmt.Errorf("invalid KELLY_CAP %g (expected 0 < value <= 1)", c.KellyCap)
+		}
+		if c.KellyLookback < 0 {
+			return fmt.Errorf("KELLY_LOOKBACK must not be negative")
+		}
+	default:
+		return fmt.Errorf("invalid POSITION_SIZING '%s' (expected fixed_quote, fixed_fraction, risk_per_trade, volatility_target or kelly)", c.Sizing)
+	}
+	if c.MaxPositions < 0 || c.MaxSymbolExposurePct < 0 || c.DailyLossLimitPct < 0 {
+		return fmt.Errorf("MAX_CONCURRENT_POSITIONS, MAX_SYMBOL_EXPOSURE_PCT and DAILY_LOSS_LIMIT_PCT must not be negative")
+	}
+	return nil
+}
+
+// ValidateStops checks that the entries get the stop the sizing model needs. risk_per_trade
+// sizes by the distance to the stop, so it needs a fixed stopLossPct or a strategy giving
+// every entry a structural stop.
+func (c Config) ValidateStops(stopLossPct float64, structuralStops bool) error {
+	if c.Sizing == RiskPerTrade && stopLossPct <= 0 && !structuralStops {
+		return fmt.Errorf("risk_per_trade sizing needs STOP_LOSS_PCT or SWING_STOP_BARS")
+	}
+	return nil
+}
+
+// Entry is an entry a strategy wants to make
+type Entry struct {
+	Symbol   string
+	Time     time.Time
+	Price    float64
+	StopLoss float64 // Protective stop of the position, 0 without one
+	Equity   float64 // Account equity in the quote currency
+}
+
+// Manager sizes the entries and keeps the portfolio within its limits. The backtest and
+// the live trader ask it for the quantity of every entry and report the positions they
+// open and close, one position per symbol. A manager can be shared by the traders of
+// several symbols.
+type Manager struct {
+	cfg Config
+
+	mu         sync.Mutex
+	atr        map[string]*indicators.ATRStream
+	volatility map[string]float64 // Latest ATR by symbol
+	open       map[string]float64 // Notional of the open positions by symbol
+	returns    []float64          // Profit of the recent closed trades relative to their notional
+	equity     float64            // Last equity reported by Size, moved by the closed trades since
+	day        time.Time          // UTC day of the loss breaker
+	dayEquity  float64            // Equity at the start of the day
+	dayPnL     float64            // Realized profit of the day
+}
+
+// NewManager creates a manager without open positions
+func NewManager(cfg Config) *Manager {
+	return &Manager{
+		cfg:        cfg,
+		atr:        make(map[string]*indicators.ATRStream),
+		volatility: make(map[string]float64),
+		open:       make(map[string]float64),
+	}
+}
+
+// OnCandle updates the volatility of the candle's symbol. It only matters to
+// volatility_target sizing, which needs ATRLength candles of the entry timeframe.
+func (m *Manager) OnCandle(c klinesfrombinance.Candle) {
+	if m.cfg.Sizing != VolatilityTarget {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stream, ok := m.atr[c.Symbol]
+	if !ok {
+		stream = indicators.NewATRStream(m.cfg.ATRLength)
+		m.atr[c.Symbol] = stream
+	}
+	m.volatility[c.Symbol] = stream.Update(c)
+}
+
+// Size returns the quantity to buy or sell for an entry. Entries that break a limit
+// return an error wrapping ErrRefused.
+func (m *Manager) Size(e Entry) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.equity = e.Equity
+	m.startDay(e.Time)
+
+	if e.Equity <= 0 || e.Price <= 0 {
+		return 0, fmt.Errorf("%w: equity %.2f at price %.2f", ErrRefused, e.Equity, e.Price)
+	}
+	if m.cfg.DailyLossLimitPct > 0 && m.dayEquity > 0 && -m.dayPnL >= m.dayEquity*m.cfg.DailyLossLimitPct/100 {
+		return 0, fmt.Errorf("%w: lost %.2f today, the daily limit is %.2f%% of %.2f", ErrRefused, -m.dayPnL, m.cfg.DailyLossLimitPct, m.dayEquity)
+	}
+	if m.cfg.MaxPositions > 0 && len(m.open) >= m.cfg.MaxPositions {
+		return 0, fmt.Errorf("%w: %d positions are open, at most %d allowed", ErrRefused, len(m.open), m.cfg.MaxPositions)
+	}
+
+	notional, err := m.notional(e)
+	if err != nil {
+		return 0, err
+	}
+	notional = math.Min(notional, e.Equity) // Entries never use leverage
+	if limit := m.cfg.MaxSymbolExposurePct; limit > 0 {
+		room := e.Equity*limit/100 - m.open[e.Symbol]
+		if room <= 0 {
+			return 0, fmt.Errorf("%w: %s exposure is already %.2f, at most %.2f%% of the equity", ErrRefused, e.Symbol, m.open[e.Symbol], limit)
+		}
+		notional = math.Min(notional, room)
+	}
+	return notional / e.Price, nil
+}
+
+// notional returns the quote amount the sizing model invests in an entry
+func (m *Manager) notional(e Entry) (float64, error) {
+	switch m.cfg.Sizing {
+	case FixedQuote:
+		return m.cfg.FixedQuote, nil
+	case FixedFraction:
+		return e.Equity * m.cfg.EquityFraction, nil
+	case RiskPerTrade:
+		distance := math.Abs(e.Price - e.StopLoss)
+		if e.StopLoss <= 0 || distance == 0 {
+			return 0, fmt.Errorf("risk_per_trade sizing needs a stop, set STOP_LOSS_PCT or SWING_STOP_BARS")
+		}
+		return e.Equity * m.cfg.RiskPct / 100 / distance * e.Price, nil
+	case VolatilityTarget:
+		atr, ok := m.volatility[e.Symbol]
+		if !ok || math.IsNaN(atr) || atr <= 0 {
+			return 0, fmt.Errorf("%w: the ATR of %s is not ready yet", ErrRefused, e.Symbol)
+		}
+		return e.Equity * m.cfg.RiskPct / 100 / (atr * m.cfg.ATRMultiple) * e.Price, nil
+	case Kelly:
+		fraction := m.kellyFraction()
+		if fraction <= 0 {
+			return 0, fmt.Errorf("%w: the recent trades have no edge, the Kelly fraction is 0", ErrRefused)
+		}
+		return e.Equity * fraction, nil
+	}
+	return e.Equity, nil
+}
+
+// kellyFraction returns W - (1-W)/R of the recent trades, where W is the win rate and R
+// the average win over the average loss, limited to 0..KellyCap
+func (m *Manager) kellyFraction() float64 {
+	if len(m.returns) < kellyMinTrades {
+		return m.cfg.KellyCap / 2
+	}
+	wins, losses := 0, 0
+	won, lost := 0.0, 0.0
+	for _, r := range m.returns {
+		if r > 0 {
+			wins++
+			won += r
+		} else {
+			losses++
+			lost -= r
+		}
+	}
+	switch {
+	case wins == 0:
+		return 0
+	case losses == 0 || lost == 0:
+		return m.cfg.KellyCap
+	}
+	winRate := float64(wins) / float64(len(m.returns))
+	payoff := (won / float64(wins)) / (lost / float64(losses))
+	return math.Max(0, math.Min(winRate-(1-winRate)/payoff, m.cfg.KellyCap))
+}
+
+// Opened records a filled entry of symbol worth notional in the quote currency
+func (m *Manager) Opened(symbol string, notional float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.open[symbol] += notional
+}
+
+// Closed records the exit of the position in symbol at the given time with its realized
+// profit after fees
+func (m *Manager) Closed(symbol string, at time.Time, profit float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if notional := m.open[symbol]; notional > 0 {
+		m.returns = append(m.returns, profit/notional)
+		if lookback := m.cfg.KellyLookback; lookback > 0 && len(m.returns) > lookback {
+			m.returns = m.returns[len(m.returns)-lookback:]
+		}
+	}
+	delete(m.open, symbol)
+
+	m.startDay(at)
+	m.equity += profit
+	m.dayPnL += profit
+}
+
+// startDay resets the daily loss when t is on a new UTC day
+func (m *Manager) startDay(t time.Time) {
+	day := t.UTC().Truncate(24 * time.Hour)
+	if day.Equal(m.day) {
+		return
+	}
+	m.day, m.dayEquity, m.dayPnL = day, m.equity, 0
+}
@@ -0,0 +1,165 @@
+package risk
+
+import (
+	"errors"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"math"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC)
+
+// entry is a long of ETHUSDT at 100 with a stop at 98 and 1000 of equity
+func entry(at time.Time) Entry {
+	return Entry{Symbol: "ETHUSDT", Time: at, Price: 100, StopLoss: 98, Equity: 1000}
+}
+
+func mustSize(t *testing.T, m *Manager, e Entry) float64 {
+	t.Helper()
+	quantity, err := m.Size(e)
+	if err != nil {
+		t.Fatalf("Size returned error: %v", err)
+	}
+	return quantity
+}
+
+func TestSizingModels(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want float64
+	}{
+		{"whole equity", Config{}, 10},
+		{"fixed quote", Config{Sizing: FixedQuote, FixedQuote: 200}, 2},
+		{"fixed fraction", Config{Sizing: FixedFraction, EquityFraction: 0.5}, 5},
+		{"risk per trade", Config{Sizing: RiskPerTrade, RiskPct: 1}, 5},           // Losing 10 over a distance of 2
+		{"without leverage", Config{Sizing: RiskPerTrade, RiskPct: 5}, 10},        // 25 units would cost more than the equity
+		{"kelly before enough trades", Config{Sizing: Kelly, KellyCap: 0.5}, 2.5}, // Half the cap
+	}
+	for _, tt := range tests {
+		if got := mustSize(t, NewManager(tt.cfg), entry(start)); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: quantity %f, want %f", tt.name, got, tt.want)
+		}
+	}
+
+	e := entry(start)
+	e.StopLoss = 0
+	if _, err := NewManager(Config{Sizing: RiskPerTrade, RiskPct: 1}).Size(e); err == nil {
+		t.Error("risk per trade sized an entry without a stop")
+	}
+}
+
+func TestVolatilityTarget(t *testing.T) {
+	m := NewManager(Config{Sizing: VolatilityTarget, RiskPct: 1, ATRLength: 3, ATRMultiple: 2})
+	if _, err := m.Size(entry(start)); !errors.Is(err, ErrRefused) {
+		t.Errorf("entry before the ATR was ready returned %v, want ErrRefused", err)
+	}
+	for i := 0; i < 4; i++ {
+		m.OnCandle(klinesfrombinance.NewCandle("ETHUSDT", start.Add(time.Duration(i)*time.Hour).UnixMilli(), 100, 101, 99, 100, 1))
+	}
+	// An ATR of 2 times 2 is a move of 4 that may cost 10
+	if got := mustSize(t, m, entry(start)); math.Abs(got-2.5) > 1e-9 {
+		t.Errorf("quantity %f, want 2.5", got)
+	}
+}
+
+func TestKellyFraction(t *testing.T) {
+	m := NewManager(Config{Sizing: Kelly, KellyCap: 0.5, KellyLookback: 10})
+	// 6 wins of 2% and 4 losses of 1%: 0.6 - 0.4/2 = 0.4
+	for i := 0; i < 10; i++ {
+		profit := 2.0
+		if i%5 < 2 {
+			profit = -1
+		}
+		m.Opened("ETHUSDT", 100)
+		m.Closed("ETHUSDT", start, profit)
+	}
+	if got := mustSize(t, m, entry(start)); math.Abs(got-4) > 1e-9 {
+		t.Errorf("quantity %f, want 4", got)
+	}
+
+	// The lookback forgets the winners once ten losses closed
+	for i := 0; i < 10; i++ {
+		m.Opened("ETHUSDT", 100)
+		m.Closed("ETHUSDT", start, -1)
+	}
+	if _, err := m.Size(entry(start)); !errors.Is(err, ErrRefused) {
+		t.Errorf("entry without an edge returned %v, want ErrRefused", err)
+	}
+}
+
+func TestPortfolioLimits(t *testing.T) {
+	m := NewManager(Config{MaxPositions: 1})
+	m.Opened("BTCUSDT", 500)
+	if _, err := m.Size(entry(start)); !errors.Is(err, ErrRefused) {
+		t.Errorf("second position returned %v, want ErrRefused", err)
+	}
+	m.Closed("BTCUSDT", start, 0)
+	mustSize(t, m, entry(start))
+
+	// 300 of exposure is allowed and 200 is already open
+	m = NewManager(Config{MaxSymbolExposurePct: 30})
+	m.Opened("ETHUSDT", 200)
+	if got := mustSize(t, m, entry(start)); math.Abs(got-1) > 1e-9 {
+		t.Errorf("quantity %f, want 1", got)
+	}
+	m.Opened("ETHUSDT", 100)
+	if _, err := m.Size(entry(start)); !errors.Is(err, ErrRefused) {
+		t.Errorf("entry above the exposure limit returned %v, want ErrRefused", err)
+	}
+}
+
+func TestDailyLossLimit(t *testing.T) {
+	m := NewManager(Config{DailyLossLimitPct: 2})
+	morning := start.Add(9 * time.Hour)
+	mustSize(t, m, entry(morning))
+	m.Opened("ETHUSDT", 1000)
+	m.Closed("ETHUSDT", morning.Add(time.Hour), -15)
+
+	// 15 of the 20 allowed is lost, the next loss trips the breaker
+	mustSize(t, m, Entry{Symbol: "ETHUSDT", Time: morning.Add(2 * time.Hour), Price: 100, Equity: 985})
+	m.Opened("ETHUSDT", 985)
+	m.Closed("ETHUSDT", morning.Add(3*time.Hour), -5)
+	if _, err := m.Size(Entry{Symbol: "ETHUSDT", Time: morning.Add(4 * time.Hour), Price: 100, Equity: 980}); !errors.Is(err, ErrRefused) {
+		t.Errorf("entry after the daily loss limit returned %v, want ErrRefused", err)
+	}
+
+	// A new UTC day starts over
+	mustSize(t, m, Entry{Symbol: "ETHUSDT", Time: start.Add(24 * time.Hour), Price: 100, Equity: 980})
+}
+
+func TestValidate(t *testing.T) {
+	for _, cfg := range []Config{
+		{Sizing: "martingale"},
+		{Sizing: FixedQuote},
+		{Sizing: FixedFraction, EquityFraction: 1.5},
+		{Sizing: RiskPerTrade},
+		{Sizing: VolatilityTarget, RiskPct: 1},
+		{Sizing: Kelly, KellyCap: 2},
+		{MaxPositions: -1},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%+v passed validation", cfg)
+		}
+	}
+	if err := (Config{Sizing: VolatilityTarget, RiskPct: 1, ATRLength: 14, ATRMultiple: 2, DailyLossLimitPct: 3}).Validate(); err != nil {
+		t.Errorf("valid config failed: %v", err)
+	}
+}
+
+func TestValidateStops(t *testing.T) {
+	cfg := Config{Sizing: RiskPerTrade, RiskPct: 1}
+	if err := cfg.ValidateStops(0, false); err == nil {
+		t.Error("risk_per_trade sizing without a stop passed validation")
+	}
+	if err := cfg.ValidateStops(2, false); err != nil {
+		t.Errorf("risk_per_trade sizing with STOP_LOSS_PCT failed: %v", err)
+	}
+	if err := cfg.ValidateStops(0, true); err != nil {
+		t.Errorf("risk_per_trade sizing with structural stops failed: %v", err)
+	}
+	if err := (Config{Sizing: FixedFraction, EquityFraction: 0.5}).ValidateStops(0, false); err != nil {
+		t.Errorf("fixed_fraction sizing without a stop failed: %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package strategy
+
+import (
+	"fmt"
+	indicators "learnGoLang/Indicators"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	loadenv "learnGoLang/LoadEnv"
+	timeframe "learnGoLang/Timeframe"
+	"math"
+	"time"
+)
+
+// ExitMACDCross is the exit reason of a position closed by an opposite MACD cross
+const ExitMACDCross = "macd_cross"
+
+func init() {
+	Register("macd", func(cfg loadenv.StrategyConfig) (Strategy, error) {
+		return NewMACD(MACDParamsFromConfig(cfg))
+	})
+}
+
+// MACDParams holds the settings of the MACD crossover strategy
+type MACDParams struct {
+	FastLength          int
+	SlowLength          int
+	SignalLength        int
+	MinMACDStrength     float64 // Minimum |MACD - signal| required to open a trade
+	EnableShortTrades   bool
+	RequireConfirmation bool // Enter one bar after the signal, only if that bar confirms it
+	SwingStopBars       int  // Place the stop of an entry at the lowest low (highest high for shorts) of this many bars, 0 leaves it to STOP_LOSS_PCT
+	EntryInterval       time.Duration
+	TrendInterval       time.Duration // Higher timeframe whose MACD direction gates entries, 0 disables the filter
+}
+
+// MACDParamsFromConfig reads the MACD settings from the strategy configuration
+func MACDParamsFromConfig(cfg loadenv.StrategyConfig) MACDParams {
+	return MACDParams{
+		FastLength:          cfg.FastLength,
+		SlowLength:          cfg.SlowLength,
+		SignalLength:        cfg.SignalLength,
+		MinMACDStrength:     cfg.MinMACDStrength,
+		EnableShortTrades:   cfg.EnableShortTrades,
+		RequireConfirmation: cfg.RequireConfirmation,
+		SwingStopBars:       cfg.SwingStopBars,
+		EntryInterval:       time.Duration(cfg.EntryTFMinutes) * time.Minute,
+		TrendInterval:       time.Duration(cfg.TrendTFHours) * time.Hour,
+	}
+}
+
+// Validate checks that the parameters describe a usable strategy
+func (p MACDParams) Validate() error {
+	if p.FastLength <= 0 || p.SlowLength <= 0 || p.SignalLength <= 0 {
+		return fmt.Errorf("MACD lengths must be positive (fast=%d, slow=%d, signal=%d)", p.FastLength, p.SlowLength, p.SignalLength)
+	}
+	if p.SwingStopBars < 0 {
+		return fmt.Errorf("swing stop bars must not be negative (got %d)", p.SwingStopBars)
+	}
+	if p.FastLength >= p.SlowLength {
+		return fmt.Errorf("fast length (%d) must be smaller than slow length (%d)", p.FastLength, p.SlowLength)
+	}
+	if p.TrendInterval > 0 && (p.EntryInterval <= 0 || p.TrendInterval%p.EntryInterval != 0) {
+		return fmt.Errorf("trend timeframe %s must be a multiple of entry timeframe %s", p.TrendInterval, p.EntryInterval)
+	}
+	return nil
+}
+
+// MACD is the MACD crossover strategy. It goes long when the MACD line crosses above its
+// signal line by at least MinMACDStrength, short on the opposite cross if shorts are
+// enabled, and exits on the next cross against the position. With a trend timeframe,
+// entries must agree with the MACD direction of the last closed trend bar. With
+// SwingStopBars, entries carry the recent swing low or high as their structural stop.
+type MACD struct {
+	params MACDParams
+	macd   *indicators.MACDStream
+	prev   indicators.MACDValue
+	cur    indicators.MACDValue
+	last   klinesfrombinance.Candle
+	recent []klinesfrombinance.Candle // Last SwingStopBars candles, oldest first
+
+	trend          *timeframe.Resampler // nil without a trend filter
+	trendMACD      *indicators.MACDStream
+	trendDirection int // 1 while the trend MACD is above its signal line, -1 below, 0 unknown
+
+	side         int // Current position: 1 long, -1 short, 0 flat
+	rawEntry     int // Entry the last candle would give without confirmation
+	pending      int // Entry waiting for its confirmation candle
+	pendingClose float64
+}
+
+// NewMACD creates the MACD crossover strategy
+func NewMACD(p MACDParams) (*MACD, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	s := &MACD{
+		params: p,
+		macd:   indicators.NewMACDStream(p.FastLength, p.SlowLength, p.SignalLength),
+		prev:   indicators.MACDValue{MACD: math.NaN(), Signal: math.NaN()},
+		cur:    indicators.MACDValue{MACD: math.NaN(), Signal: math.NaN()},
+	}
+	if p.TrendInterval > 0 {
+		trend, err := timeframe.NewResampler(p.EntryInterval, p.TrendInterval)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up trend timeframe: %w", err)
+		}
+		s.trend = trend
+		s.trendMACD = indicators.NewMACDStream(p.FastLength, p.SlowLength, p.SignalLength)
+	}
+	return s, nil
+}
+
+// Name returns "macd"
+func (s *MACD) Name() string {
+	return "macd"
+}
+
+// OnCandle updates the MACD, and the trend MACD whenever a trend bar closes
+func (s *MACD) OnCandle(c klinesfrombinance.Candle) {
+	// A signal of the previous candle waits for confirmation if it left the strategy flat
+	s.pending = 0
+	if s.params.RequireConfirmation && s.side == 0 && s.rawEntry != 0 {
+		s.pending, s.pendingClose = s.rawEntry, s.last.Close
+	}
+
+	s.prev, s.cur, s.last = s.cur, s.macd.Update(c), c
+	if s.params.SwingStopBars > 0 {
+		if len(s.recent) == s.params.SwingStopBars {
+			s.recent = s.recent[1:]
+		}
+		s.recent = append(s.recent, c)
+	}
+	if s.trend != nil {
+		for _, bar := range s.trend.Add(c) {
+			s.trendDirection = direction(s.trendMACD.Update(bar))
+		}
+	}
+	s.rawEntry = s.entrySignal()
+}
+
+// OnFill tracks the position
+func (s *MACD) OnFill(f Fill) {
+	switch f.Action {
+	case EnterLong:
+		s.side = 1
+	case EnterShort:
+		s.side = -1
+	case Exit:
+		s.side = 0
+	}
+}
+
+// Signals returns an exit on a cross against the open position and, once flat, an entry
+func (s *MACD) Signals() []Signal {
+	var signals []Signal
+	side := s.side
+	if side != 0 && s.crossed(side < 0) {
+		signals = append(signals, s.signal(Exit, ExitMACDCross))
+		side = 0
+	}
+	if side != 0 {
+		return signals
+	}
+
+	entry := s.rawEntry
+	if s.params.RequireConfirmation {
+		entry = 0
+		if s.pending != 0 && float64(s.pending)*(s.last.Close-s.pendingClose) > 0 && float64(s.pending)*(s.cur.MACD-s.cur.Signal) > 0 {
+			entry = s.pending
+		}
+	}
+	switch entry {
+	case 1:
+		signals = append(signals, s.signal(EnterLong, "macd_cross_up"))
+	case -1:
+		signals = append(signals, s.signal(EnterShort, "macd_cross_down"))
+	}
+	return signals
+}
+
+// entrySignal returns 1 for a long entry, -1 for a short entry and 0 for none
+func (s *MACD) entrySignal() int {
+	if math.Abs(s.cur.MACD-s.cur.Signal) < s.params.MinMACDStrength {
+		return 0
+	}
+	switch {
+	case s.crossed(true) && (s.trend == nil || s.trendDirection > 0):
+		return 1
+	case s.params.EnableShortTrades && s.crossed(false) && (s.trend == nil || s.trendDirection < 0):
+		return -1
+	}
+	return 0
+}
+
+// crossed reports whether the MACD line crossed the signal line on the last candle
+func (s *MACD) crossed(up bool) bool {
+	if math.IsNaN(s.prev.Signal) || math.IsNaN(s.cur.Signal) {
+		return false
+	}
+	if up {
+		return s.prev.MACD <= s.prev.Signal && s.cur.MACD > s.cur.Signal
+	}
+	return s.prev.MACD >= s.prev.Signal && s.cur.MACD < s.cur.Signal
+}
+
+func (s *MACD) signal(action Action, reason string) Signal {
+	return Signal{Action: action, Time: s.last.Datetime, Price: s.last.Close, Reason: reason, StopLoss: s.swingStop(action)}
+}
+
+// swingStop returns the lowest low of the recent candles for a long entry and the
+// highest high for a short entry, or 0 for exits and without SwingStopBars
+func (s *MACD) swingStop(action Action) float64 {
+	if len(s.recent) == 0 || (action != EnterLong && action != EnterShort) {
+		return 0
+	}
+	stop := s.recent[0].Low
+	if action == EnterShort {
+		stop = s.recent[0].High
+	}
+	for _, c := range s.recent[1:] {
+		if action == EnterLong {
+			stop = math.Min(stop, c.Low)
+		} else {
+			stop = math.Max(stop, c.High)
+		}
+	}
+	return stop
+}
+
+// direction is 1 when MACD is above its signal line, -1 when below and 0 while the
+// signal line is not available
+func direction(v indicators.MACDValue) int {
+	switch {
+	case math.IsNaN(v.Signal):
+		return 0
+	case v.MACD > v.Signal:
+		return 1
+	case v.MACD < v.Signal:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,100 @@
+package strategy
+
+import (
+	"fmt"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	loadenv "learnGoLang/LoadEnv"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Action is what a signal asks the executor to do
+type Action string
+
+const (
+	EnterLong  Action = "enter_long"
+	EnterShort Action = "enter_short"
+	Exit       Action = "exit"
+)
+
+// Signal asks the executor to open or close a position at the close of the candle that
+// produced it
+type Signal struct {
+	Action   Action
+	Time     time.Time
+	Price    float64 // Close of the candle that produced the signal
+	Reason   string  // Reported as the exit reason of the trade
+	StopLoss float64 // Structural stop of an entry, such as a swing low. 0 uses STOP_LOSS_PCT.
+}
+
+// Fill tells a strategy that its position changed
+type Fill struct {
+	Action   Action
+	Time     time.Time
+	Price    float64
+	Quantity float64
+}
+
+// Strategy turns closed candles into trading signals. The backtester, the paper trader
+// and the live trader all drive it the same way: OnCandle with every closed candle of the
+// entry timeframe, then Signals, and OnFill whenever the position is opened or closed.
+type Strategy interface {
+	// Name is the name the strategy is registered under
+	Name() string
+	// OnCandle updates the strategy with the next closed candle
+	OnCandle(c klinesfrombinance.Candle)
+	// OnFill reports an executed signal, or an exit the executor made on its own such as
+	// a stop loss
+	OnFill(f Fill)
+	// Signals returns the signals for the last candle given the current position. It may
+	// be called again after a fill, e.g. to look for an entry once a stop closed the position.
+	Signals() []Signal
+}
+
+// Factory builds a strategy from the strategy configuration
+type Factory func(cfg loadenv.StrategyConfig) (Strategy, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a strategy available under name. It is meant to be called from the
+// init function of the file that implements the strategy, and panics if the name is taken.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, taken := registry[name]; taken {
+		panic(fmt.Sprintf("strategy: %s is registered twice", name))
+	}
+	registry[name] = factory
+}
+
+// New builds the strategy selected by STRATEGY
+func New(cfg loadenv.StrategyConfig) (Strategy, error) {
+	registryMu.RLock()
+	factory, ok := registry[cfg.Name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy '%s' (available: %s)", cfg.Name, strings.Join(Names(), ", "))
+	}
+	s, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s strategy settings: %w", cfg.Name, err)
+	}
+	return s, nil
+}
+
+// Names returns the registered strategy names in alphabetical order
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,125 @@
+package strategy
+
+import (
+	indicators "learnGoLang/Indicators"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	loadenv "learnGoLang/LoadEnv"
+	timeframe "learnGoLang/Timeframe"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waveCandles builds 15m candles following a slow sine wave, without the skipped indexes
+func waveCandles(n int, skip ...int) []klinesfrombinance.Candle {
+	start := time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC).UnixMilli()
+	skipped := make(map[int]bool)
+	for _, i := range skip {
+		skipped[i] = true
+	}
+	var candles []klinesfrombinance.Candle
+	for i := 0; i < n; i++ {
+		if skipped[i] {
+			continue
+		}
+		price := 2000 + 100*math.Sin(float64(i)/40) + 10*math.Sin(float64(i)/3)
+		ts := start + int64(i)*15*60*1000
+		candles = append(candles, klinesfrombinance.NewCandle("ETHUSDT", ts, price, price+3, price-3, price+1, 1))
+	}
+	return candles
+}
+
+func testConfig() loadenv.StrategyConfig {
+	return loadenv.StrategyConfig{Name: "macd", FastLength: 12, SlowLength: 26, SignalLength: 9, EntryTFMinutes: 15, TrendTFHours: 4}
+}
+
+func TestNewSelectsByName(t *testing.T) {
+	s, err := New(testConfig())
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if s.Name() != "macd" {
+		t.Errorf("Name() = %s, want macd", s.Name())
+	}
+
+	cfg := testConfig()
+	cfg.Name = "astrology"
+	if _, err := New(cfg); err == nil || !strings.Contains(err.Error(), "available: macd") {
+		t.Errorf("expected an unknown strategy error listing macd, got %v", err)
+	}
+
+	cfg = testConfig()
+	cfg.FastLength = 30
+	if _, err := New(cfg); err == nil {
+		t.Error("expected an error for fast length >= slow length")
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering macd twice did not panic")
+		}
+	}()
+	Register("macd", nil)
+}
+
+func TestMACDTrendHasNoLookAhead(t *testing.T) {
+	candles := waveCandles(3000, 100, 101, 715, 1500)
+	s, err := New(testConfig())
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	macd := s.(*MACD)
+
+	// The batch way: resample everything, then map every candle to the last closed trend bar
+	higher, _ := timeframe.Resample(candles, 15*time.Minute, 4*time.Hour)
+	lines := indicators.MACD(higher, 12, 26, 9)
+	index := timeframe.ClosedIndex(candles, 15*time.Minute, higher, 4*time.Hour)
+
+	for i, c := range candles {
+		macd.OnCandle(c)
+		want := 0
+		if j := index[i]; j >= 0 {
+			want = direction(indicators.MACDValue{MACD: lines.MACD[j], Signal: lines.Signal[j]})
+		}
+		if macd.trendDirection != want {
+			t.Fatalf("candle %d: trend direction %d, want %d", i, macd.trendDirection, want)
+		}
+	}
+}
+
+func TestMACDSignalsFollowThePosition(t *testing.T) {
+	p := MACDParamsFromConfig(testConfig())
+	p.TrendInterval = 0
+	s, err := NewMACD(p)
+	if err != nil {
+		t.Fatalf("NewMACD returned error: %v", err)
+	}
+
+	entries, exits := 0, 0
+	for _, c := range waveCandles(1000) {
+		s.OnCandle(c)
+		for _, sig := range s.Signals() {
+			switch sig.Action {
+			case EnterLong:
+				if s.side != 0 {
+					t.Fatalf("entry signal while in a position at %s", sig.Time)
+				}
+				entries++
+			case Exit:
+				if s.side != 1 || sig.Reason != ExitMACDCross {
+					t.Fatalf("unexpected exit %+v while side is %d", sig, s.side)
+				}
+				exits++
+			case EnterShort:
+				t.Fatalf("short signal with shorts disabled at %s", sig.Time)
+			}
+			s.OnFill(Fill{Action: sig.Action, Time: sig.Time, Price: sig.Price, Quantity: 1})
+		}
+	}
+	if entries == 0 || exits < entries-1 {
+		t.Errorf("got %d entries and %d exits", entries, exits)
+	}
+}
@@ -0,0 +1,295 @@
+package streamklines
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	loadenv "learnGoLang/LoadEnv"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// BackfillFunc downloads the candles between startTime and endTime (Unix milliseconds)
+type BackfillFunc func(ctx context.Context, symbol, interval string, startTime, endTime int64) ([]klinesfrombinance.Candle, error)
+
+// Stream subscribes to the Binance kline stream of one symbol and interval and emits
+// every closed candle exactly once, in timestamp order.
+type Stream struct {
+	URL        string
+	Symbol     string
+	Interval   string
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// ReadTimeout is how long the connection may stay silent before it is considered dead
+	ReadTimeout time.Duration
+	// Backfill fills the gap after a reconnect, it defaults to klinesfrombinance.FetchKlines
+	// against the configured BINANCE_API_BASE
+	Backfill BackfillFunc
+	Dialer   *websocket.Dialer
+
+	intervalMillis int64
+	lastTimestamp  int64 // Open time of the last emitted candle
+}
+
+// wsKline is the "k" object of a Binance kline event. encoding/json matches keys case
+// insensitively, so the upper-case keys must be declared too or they would overwrite
+// their lower-case twins ("L" and "l", "V" and "v", ...).
+type wsKline struct {
+	StartTime int64  `json:"t"`
+	CloseTime int64  `json:"T"`
+	Symbol    string `json:"s"`
+	Interval  string `json:"i"`
+	Open      string `json:"o"`
+	High      string `json:"h"`
+	Low       string `json:"l"`
+	Close     string `json:"c"`
+	Volume    string `json:"v"`
+	Closed    bool   `json:"x"`
+
+	LastTradeID      int64  `json:"L"`
+	TakerBuyVolume   string `json:"V"`
+	TakerQuoteVolume string `json:"Q"`
+}
+
+// wsEvent is a kline event, optionally wrapped in a combined stream envelope
+type wsEvent struct {
+	EventType string          `json:"e"`
+	EventTime int64           `json:"E"`
+	Kline     wsKline         `json:"k"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// New creates a stream for the configured WebSocket URL, symbol and interval. If the
+// URL points at the bare "/ws" endpoint, the kline stream name is appended.
+func New(cfg loadenv.ExchangeConfig) *Stream {
+	url := strings.TrimSuffix(cfg.WebsocketURL, "/")
+	if strings.HasSuffix(url, "/ws") {
+		url = fmt.Sprintf("%s/%s@kline_%s", url, strings.ToLower(cfg.Symbol), cfg.Interval)
+	}
+	apiBase := cfg.APIBase
+	return &Stream{
+		URL:         url,
+		Symbol:      cfg.Symbol,
+		Interval:    cfg.Interval,
+		MinBackoff:  time.Second,
+		MaxBackoff:  time.Minute,
+		ReadTimeout: 5 * time.Minute,
+		Backfill: func(ctx context.Context, symbol, interval string, startTime, endTime int64) ([]klinesfrombinance.Candle, error) {
+			return klinesfrombinance.FetchKlines(ctx, apiBase, symbol, interval, startTime, endTime)
+		},
+		Dialer: websocket.DefaultDialer,
+	}
+}
+
+// ResumeFrom tells the stream which candle the caller already has, so that the first
+// connection back-fills everything after it. timestamp is the candle open time in milliseconds.
+func (s *Stream) ResumeFrom(timestamp int64) {
+	s.lastTimestamp = timestamp
+}
+
+// Start runs the stream in the background and returns the channel of closed candles.
+// The channel is closed once ctx is cancelled.
+func (s *Stream) Start(ctx context.Context) (<-chan klinesfrombinance.Candle, error) {
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+	out := make(chan klinesfrombinance.Candle, 100)
+	go func() {
+		defer close(out)
+		s.run(ctx, out)
+	}()
+	return out, nil
+}
+
+// Run streams closed candles into out until ctx is cancelled, reconnecting with
+// exponential backoff whenever the connection drops.
+func (s *Stream) Run(ctx context.Context, out chan<- klinesfrombinance.Candle) error {
+	if err := s.init(); err != nil {
+		return err
+	}
+	s.run(ctx, out)
+	return ctx.Err()
+}
+
+func (s *Stream) init() error {
+	interval, err := klinesfrombinance.IntervalDuration(s.Interval)
+	if err != nil {
+		return err
+	}
+	s.intervalMillis = interval.Milliseconds()
+	return nil
+}
+
+func (s *Stream) run(ctx context.Context, out chan<- klinesfrombinance.Candle) {
+	backoff := s.MinBackoff
+	for ctx.Err() == nil {
+		received, err := s.connect(ctx, out)
+		if ctx.Err() != nil {
+			return
+		}
+		if received {
+			backoff = s.MinBackoff
+		}
+		log.Printf("Kline stream %s disconnected: %v. Reconnecting in %s", s.URL, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+	}
+}
+
+// connect runs one websocket session. It reports whether any message was received so
+// the caller knows if the backoff should be reset.
+func (s *Stream) connect(ctx context.Context, out chan<- klinesfrombinance.Candle) (bool, error) {
+	conn, _, err := s.Dialer.DialContext(ctx, s.URL, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+	log.Printf("Connected to kline stream %s", s.URL)
+
+	// Unblock ReadMessage when the context is cancelled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(s.ReadTimeout))
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(s.ReadTimeout))
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(10*time.Second))
+	})
+
+	// Fill whatever was missed while we were disconnected
+	if err := s.backfill(ctx, out); err != nil {
+		log.Printf("Error back-filling %s %s: %v", s.Symbol, s.Interval, err)
+	}
+
+	received := false
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return received, err
+		}
+		received = true
+		conn.SetReadDeadline(time.Now().Add(s.ReadTimeout))
+
+		candle, closed, err := s.parseEvent(message)
+		if err != nil {
+			log.Printf("Ignoring kline stream message: %v", err)
+			continue
+		}
+		if !closed {
+			continue
+		}
+		if candle.Timestamp > s.lastTimestamp+s.intervalMillis && s.lastTimestamp > 0 {
+			// A candle was skipped while the connection was up, fetch it before emitting this one
+			if err := s.backfillUntil(ctx, out, candle.Timestamp); err != nil {
+				log.Printf("Error back-filling %s %s: %v", s.Symbol, s.Interval, err)
+			}
+		}
+		s.emit(ctx, out, candle)
+		if ctx.Err() != nil {
+			return received, ctx.Err()
+		}
+	}
+}
+
+// backfill emits every closed candle after the last emitted one
+func (s *Stream) backfill(ctx context.Context, out chan<- klinesfrombinance.Candle) error {
+	if s.lastTimestamp == 0 {
+		return nil
+	}
+	return s.backfillUntil(ctx, out, time.Now().UnixMilli())
+}
+
+// backfillUntil emits the closed candles opened after the last emitted one and before endTime
+func (s *Stream) backfillUntil(ctx context.Context, out chan<- klinesfrombinance.Candle, endTime int64) error {
+	for ctx.Err() == nil {
+		startTime := s.lastTimestamp + s.intervalMillis
+		if startTime+s.intervalMillis > time.Now().UnixMilli() || startTime >= endTime {
+			return nil
+		}
+		candles, err := s.Backfill(ctx, s.Symbol, s.Interval, startTime, endTime-1)
+		if err != nil {
+			return err
+		}
+		emitted := false
+		for _, c := range candles {
+			if c.Timestamp+s.intervalMillis > time.Now().UnixMilli() {
+				break // Still forming
+			}
+			if s.emit(ctx, out, c) {
+				emitted = true
+			}
+		}
+		if !emitted {
+			return nil
+		}
+		log.Printf("Back-filled %s %s up to %s", s.Symbol, s.Interval,
+			time.UnixMilli(s.lastTimestamp).Format("2006-01-02 15:04:05"))
+	}
+	return ctx.Err()
+}
+
+// emit sends a candle unless it was already emitted. It returns false if nothing was sent.
+func (s *Stream) emit(ctx context.Context, out chan<- klinesfrombinance.Candle, c klinesfrombinance.Candle) bool {
+	if c.Timestamp <= s.lastTimestamp {
+		return false
+	}
+	select {
+	case out <- c:
+		s.lastTimestamp = c.Timestamp
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// parseEvent decodes a kline event and reports whether its candle is closed
+func (s *Stream) parseEvent(message []byte) (klinesfrombinance.Candle, bool, error) {
+	var event wsEvent
+	if err := json.Unmarshal(message, &event); err != nil {
+		return klinesfrombinance.Candle{}, false, fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+	if len(event.Data) > 0 {
+		if err := json.Unmarshal(event.Data, &event); err != nil {
+			return klinesfrombinance.Candle{}, false, fmt.Errorf("failed to unmarshal combined stream data: %w", err)
+		}
+	}
+	if event.EventType != "kline" {
+		return klinesfrombinance.Candle{}, false, fmt.Errorf("unexpected event type '%s'", event.EventType)
+	}
+
+	k := event.Kline
+	values := make([]float64, 5)
+	for i, field := range []string{k.Open, k.High, k.Low, k.Close, k.Volume} {
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return klinesfrombinance.Candle{}, false, fmt.Errorf("invalid kline value '%s': %w", field, err)
+		}
+		values[i] = v
+	}
+	symbol := k.Symbol
+	if symbol == "" {
+		symbol = s.Symbol
+	}
+	candle := klinesfrombinance.NewCandle(symbol, k.StartTime, values[0], values[1], values[2], values[3], values[4])
+	return candle, k.Closed, nil
+}
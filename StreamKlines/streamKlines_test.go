@@ -0,0 +1,134 @@
+package streamklines
+
+import (
+	"context"
+	"fmt"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	loadenv "learnGoLang/LoadEnv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const minute = int64(60 * 1000)
+
+var base = time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+func klineMessage(openTime int64, closed bool) string {
+	return fmt.Sprintf(`{"e":"kline","E":%d,"s":"ETHUSDT","k":{"t":%d,"T":%d,"s":"ETHUSDT","i":"1m","o":"100.0","c":"101.5","h":"102.0","l":"99.5","v":"12.5","L":42,"V":"3.0","Q":"300.0","x":%t}}`,
+		openTime, openTime, openTime+minute-1, closed)
+}
+
+// newStandIn starts a websocket server that plays one script per connection and then hangs up
+func newStandIn(t *testing.T, sessions [][]string) *httptest.Server {
+	var mu sync.Mutex
+	connection := 0
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		mu.Lock()
+		script := []string{}
+		if connection < len(sessions) {
+			script = sessions[connection]
+		}
+		connection++
+		mu.Unlock()
+
+		for _, message := range script {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+				return
+			}
+		}
+		if len(script) == 0 {
+			// Keep the last connection open until the client goes away
+			conn.ReadMessage()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func collect(t *testing.T, candles <-chan klinesfrombinance.Candle, n int) []int64 {
+	var got []int64
+	timeout := time.After(5 * time.Second)
+	for len(got) < n {
+		select {
+		case c := <-candles:
+			got = append(got, c.Timestamp)
+		case <-timeout:
+			t.Fatalf("timed out after %d candles: %v", len(got), got)
+		}
+	}
+	return got
+}
+
+func TestStreamEmitsClosedCandlesAndBackfillsAfterReconnect(t *testing.T) {
+	server := newStandIn(t, [][]string{
+		{klineMessage(base, false), klineMessage(base, true)},
+		{klineMessage(base+3*minute, true)},
+		{},
+	})
+
+	stream := New(loadenv.ExchangeConfig{WebsocketURL: "ws" + strings.TrimPrefix(server.URL, "http"), Symbol: "ETHUSDT", Interval: "1m"})
+	stream.MinBackoff = 10 * time.Millisecond
+	var backfillCalls []int64
+	stream.Backfill = func(ctx context.Context, symbol, interval string, startTime, endTime int64) ([]klinesfrombinance.Candle, error) {
+		backfillCalls = append(backfillCalls, startTime)
+		var candles []klinesfrombinance.Candle
+		for ts := base; ts < base+3*minute; ts += minute {
+			if ts >= startTime && ts <= endTime {
+				candles = append(candles, klinesfrombinance.NewCandle(symbol, ts, 1, 1, 1, 1, 1))
+			}
+		}
+		return candles, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	candles, err := stream.Start(ctx)
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+
+	got := collect(t, candles, 4)
+	want := []int64{base, base + minute, base + 2*minute, base + 3*minute}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("candles = %v, want %v", got, want)
+		}
+	}
+	if len(backfillCalls) == 0 || backfillCalls[0] != base+minute {
+		t.Errorf("backfill calls = %v, want first call from %d", backfillCalls, base+minute)
+	}
+
+	cancel()
+	for range candles {
+	}
+}
+
+func TestParseEventCombinedStream(t *testing.T) {
+	stream := New(loadenv.ExchangeConfig{WebsocketURL: "wss://stream.binance.com:9443/ws", Symbol: "ETHUSDT", Interval: "1m"})
+	if stream.URL != "wss://stream.binance.com:9443/ws/ethusdt@kline_1m" {
+		t.Errorf("URL = %s", stream.URL)
+	}
+
+	message := fmt.Sprintf(`{"stream":"ethusdt@kline_1m","data":%s}`, klineMessage(base, true))
+	candle, closed, err := stream.parseEvent([]byte(message))
+	if err != nil {
+		t.Fatalf("parseEvent returned error: %v", err)
+	}
+	if !closed || candle.Timestamp != base || candle.Close != 101.5 || candle.Volume != 12.5 {
+		t.Errorf("unexpected candle %+v (closed=%t)", candle, closed)
+	}
+}
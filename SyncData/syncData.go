@@ -0,0 +1,148 @@
+package syncdata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	candlestore "learnGoLang/CandleStore"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Pair is one symbol and interval to keep in sync
+type Pair struct {
+	Symbol   string
+	Interval string
+}
+
+func (p Pair) String() string {
+	return p.Symbol + " " + p.Interval
+}
+
+// Result is the outcome of syncing one pair
+type Result struct {
+	Pair     Pair
+	FilePath string
+	Candles  int
+	Duration time.Duration
+	Err      error
+}
+
+// Options configures a sync run
+type Options struct {
+	APIBase string
+	DataDir string
+	Workers int
+	Limiter *klinesfrombinance.RateLimiter
+	// BadRowPolicy handles invalid rows of the existing files, it defaults to reject
+	BadRowPolicy klinesfrombinance.BadRowPolicy
+	// Store is the storage backend of the histories, it defaults to CSV files
+	Store string
+}
+
+// ParsePairs parses "SYMBOL:interval" entries such as "ETHUSDT:15m"
+func ParsePairs(entries []string) ([]Pair, error) {
+	var pairs []Pair
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		symbol, interval, ok := strings.Cut(entry, ":")
+		if !ok || symbol == "" || interval == "" {
+			return nil, fmt.Errorf("invalid pair '%s', expected SYMBOL:interval", entry)
+		}
+		if _, err := klinesfrombinance.IntervalDuration(interval); err != nil {
+			return nil, fmt.Errorf("invalid pair '%s': %w", entry, err)
+		}
+		pairs = append(pairs, Pair{Symbol: strings.ToUpper(symbol), Interval: interval})
+	}
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("no pairs to sync")
+	}
+	return pairs, nil
+}
+
+// Sync updates the history file of every pair using a bounded pool of workers. A
+// failing pair is reported in its Result and does not stop the others. Results are
+// returned in the order of pairs.
+func Sync(ctx context.Context, pairs []Pair, opts Options) []Result {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	if err := os.MkdirAll(opts.DataDir, 0755); err != nil {
+		results := make([]Result, len(pairs))
+		for i, p := range pairs {
+			results[i] = Result{Pair: p, Err: fmt.Errorf("failed to create data folder: %w", err)}
+		}
+		return results
+	}
+
+	results := make([]Result, len(pairs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = syncPair(ctx, pairs[i], opts)
+
+				mu.Lock()
+				done++
+				logResult(done, len(pairs), results[i])
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := range pairs {
+		if ctx.Err() != nil {
+			results[i] = Result{Pair: pairs[i], Err: ctx.Err()}
+			continue
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func syncPair(ctx context.Context, pair Pair, opts Options) (result Result) {
+	start := time.Now()
+	backend := opts.Store
+	if backend == "" {
+		backend = candlestore.CSV
+	}
+	result = Result{Pair: pair, FilePath: candlestore.SeriesPath(backend, opts.DataDir, pair.Symbol, pair.Interval)}
+	defer func() { result.Duration = time.Since(start) }()
+
+	store, err := candlestore.Open(backend, result.FilePath, pair.Symbol, pair.Interval, opts.BadRowPolicy)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer store.Close()
+
+	updateErr := klinesfrombinance.UpdateStore(ctx, opts.APIBase, store, pair.Symbol, pair.Interval, opts.Limiter)
+	candles, err := klinesfrombinance.AllCandles(ctx, store)
+	result.Candles = len(candles)
+	result.Err = errors.Join(updateErr, err)
+	return result
+}
+
+func logResult(done, total int, r Result) {
+	if r.Err != nil {
+		log.Printf("[%d/%d] %s FAILED after %s: %v", done, total, r.Pair, r.Duration.Round(time.Millisecond), r.Err)
+		return
+	}
+	log.Printf("[%d/%d] %s up to date: %d candles in %s (%s)", done, total, r.Pair, r.Candles, r.FilePath, r.Duration.Round(time.Millisecond))
+}
@@ -0,0 +1,91 @@
+package syncdata
+
+import (
+	"context"
+	"fmt"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+)
+
+// newStandIn serves one daily kline per symbol on the first request and nothing after
+// that. BADUSDT always fails.
+func newStandIn(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	served := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		symbol := r.URL.Query().Get("symbol")
+		if symbol == "BADUSDT" {
+			http.Error(w, `{"code":-1121,"msg":"Invalid symbol."}`, http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		first := !served[symbol]
+		served[symbol] = true
+		mu.Unlock()
+		if !first {
+			fmt.Fprint(w, "[]")
+			return
+		}
+		start := r.URL.Query().Get("startTime")
+		fmt.Fprintf(w, `[[%s,"100.0","110.0","90.0","105.0","12.5",0,"0",1,"0","0","0"]]`, start)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestParsePairs(t *testing.T) {
+	pairs, err := ParsePairs([]string{"ethusdt:15m", " BTCUSDT:1h "})
+	if err != nil {
+		t.Fatalf("ParsePairs returned error: %v", err)
+	}
+	if len(pairs) != 2 || pairs[0] != (Pair{"ETHUSDT", "15m"}) || pairs[1] != (Pair{"BTCUSDT", "1h"}) {
+		t.Errorf("pairs = %v", pairs)
+	}
+	for _, bad := range [][]string{{"ETHUSDT"}, {"ETHUSDT:7m"}, {}} {
+		if _, err := ParsePairs(bad); err == nil {
+			t.Errorf("expected an error for %v", bad)
+		}
+	}
+}
+
+func TestSyncContinuesPastFailures(t *testing.T) {
+	server := newStandIn(t)
+	dir := t.TempDir()
+	pairs := []Pair{{"ETHUSDT", "1d"}, {"BADUSDT", "1d"}, {"BTCUSDT", "1d"}}
+
+	results := Sync(context.Background(), pairs, Options{
+		APIBase: server.URL,
+		DataDir: dir,
+		Workers: 2,
+		Limiter: klinesfrombinance.NewRateLimiter(6000),
+	})
+
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	for i, r := range results {
+		if r.Pair != pairs[i] {
+			t.Errorf("result %d is for %s, want %s", i, r.Pair, pairs[i])
+		}
+		if r.Duration <= 0 {
+			t.Errorf("%s: duration %s, want the time the sync took", r.Pair, r.Duration)
+		}
+	}
+	if results[1].Err == nil {
+		t.Error("expected BADUSDT to fail")
+	}
+	for _, i := range []int{0, 2} {
+		r := results[i]
+		if r.Err != nil || r.Candles != 1 {
+			t.Errorf("%s: candles %d, err %v", r.Pair, r.Candles, r.Err)
+			continue
+		}
+		if _, err := os.Stat(r.FilePath); err != nil {
+			t.Errorf("%s: history file missing: %v", r.Pair, err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package timeframe
+
+import (
+	"fmt"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"math"
+	"time"
+)
+
+// Resample aggregates base candles into bars of the given period. Buckets are aligned
+// on the Unix epoch (UTC), so a 4h series always starts at 00:00, 04:00, ... A trailing
+// bucket is only returned once its last base candle has closed, which means the
+// result never contains a bar that is still forming.
+func Resample(candles []klinesfrombinance.Candle, baseInterval, period time.Duration) ([]klinesfrombinance.Candle, error) {
+	if baseInterval <= 0 || period < baseInterval || period%baseInterval != 0 {
+		return nil, fmt.Errorf("period %s is not a multiple of base interval %s", period, baseInterval)
+	}
+	if period == baseInterval {
+		return append([]klinesfrombinance.Candle(nil), candles...), nil
+	}
+
+	periodMillis := period.Milliseconds()
+	baseMillis := baseInterval.Milliseconds()
+
+	var bars []klinesfrombinance.Candle
+	var current *klinesfrombinance.Candle
+	var lastClose int64
+	for _, c := range candles {
+		bucket := c.Timestamp - c.Timestamp%periodMillis
+		if current == nil || bucket != current.Timestamp {
+			if current != nil {
+				bars = append(bars, *current)
+			}
+			current = newBar(c, bucket)
+		} else {
+			current.High = math.Max(current.High, c.High)
+			current.Low = math.Min(current.Low, c.Low)
+			current.Close = c.Close
+			current.Volume += c.Volume
+		}
+		lastClose = c.Timestamp + baseMillis
+	}
+	if current != nil && lastClose == current.Timestamp+periodMillis {
+		bars = append(bars, *current)
+	}
+	return bars, nil
+}
+
+// newBar starts a higher-timeframe bar at the given bucket timestamp
+func newBar(c klinesfrombinance.Candle, bucket int64) *klinesfrombinance.Candle {
+	dt := time.UnixMilli(bucket).UTC()
+	return &klinesfrombinance.Candle{
+		Symbol:    c.Symbol,
+		Timestamp: bucket,
+		Datetime:  dt,
+		Date:      dt.Format("2006-01-02"),
+		Hour:      dt.Hour(),
+		Open:      c.Open,
+		High:      c.High,
+		Low:       c.Low,
+		Close:     c.Close,
+		Volume:    c.Volume,
+	}
+}
+
+// ClosedIndex maps every base candle to the index of the latest higher-timeframe bar
+// that had already closed when the base candle closed, or -1 if none had. Using this
+// mapping is what keeps a multi-timeframe strategy free of look-ahead bias.
+func ClosedIndex(base []klinesfrombinance.Candle, baseInterval time.Duration, higher []klinesfrombinance.Candle, period time.Duration) []int {
+	index := make([]int, len(base))
+	baseMillis := baseInterval.Milliseconds()
+	periodMillis := period.Milliseconds()
+
+	j := -1
+	for i, c := range base {
+		closeTime := c.Timestamp + baseMillis
+		for j+1 < len(higher) && higher[j+1].Timestamp+periodMillis <= closeTime {
+			j++
+		}
+		index[i] = j
+	}
+	return index
+}
+
+// Resampler is the incremental form of Resample. Base candles are added one at a time
+// and every higher-timeframe bar is returned as soon as Resample would include it: when
+// its last base candle closes, or when a candle of a later bucket arrives after a gap.
+type Resampler struct {
+	baseMillis   int64
+	periodMillis int64
+	current      *klinesfrombinance.Candle
+}
+
+// NewResampler creates a resampler from baseInterval candles to bars of the given period
+func NewResampler(baseInterval, period time.Duration) (*Resampler, error) {
+	if baseInterval <= 0 || period < baseInterval || period%baseInterval != 0 {
+		return nil, fmt.Errorf("period %s is not a multiple of base interval %s", period, baseInterval)
+	}
+	return &Resampler{baseMillis: baseInterval.Milliseconds(), periodMillis: period.Milliseconds()}, nil
+}
+
+// Add takes the next closed base candle and returns the bars it completed, oldest first
+func (r *Resampler) Add(c klinesfrombinance.Candle) []klinesfrombinance.Candle {
+	if r.periodMillis == r.baseMillis {
+		return []klinesfrombinance.Candle{c}
+	}
+
+	var bars []klinesfrombinance.Candle
+	bucket := c.Timestamp - c.Timestamp%r.periodMillis
+	if r.current != nil && bucket != r.current.Timestamp {
+		bars = append(bars, *r.current)
+		r.current = nil
+	}
+	if r.current == nil {
+		r.current = newBar(c, bucket)
+	} else {
+		r.current.High = math.Max(r.current.High, c.High)
+		r.current.Low = math.Min(r.current.Low, c.Low)
+		r.current.Close = c.Close
+		r.current.Volume += c.Volume
+	}
+	if c.Timestamp+r.baseMillis == bucket+r.periodMillis {
+		bars = append(bars, *r.current)
+		r.current = nil
+	}
+	return bars
+}
@@ -0,0 +1,103 @@
+package timeframe
+
+import (
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	"testing"
+	"time"
+)
+
+// makeCandles builds n consecutive 15m candles starting at 00:00 UTC with close = index
+func makeCandles(n int) []klinesfrombinance.Candle {
+	start := time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC)
+	candles := make([]klinesfrombinance.Candle, n)
+	for i := range candles {
+		dt := start.Add(time.Duration(i) * 15 * time.Minute)
+		candles[i] = klinesfrombinance.Candle{
+			Symbol:    "ETHUSDT",
+			Timestamp: dt.UnixMilli(),
+			Datetime:  dt,
+			Open:      float64(i),
+			High:      float64(i) + 1,
+			Low:       float64(i) - 1,
+			Close:     float64(i),
+			Volume:    1,
+		}
+	}
+	return candles
+}
+
+func TestResampleAggregatesOHLCV(t *testing.T) {
+	bars, err := Resample(makeCandles(8), 15*time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("Resample returned error: %v", err)
+	}
+	if len(bars) != 2 {
+		t.Fatalf("got %d bars, want 2", len(bars))
+	}
+	bar := bars[1]
+	if bar.Open != 4 || bar.High != 8 || bar.Low != 3 || bar.Close != 7 || bar.Volume != 4 {
+		t.Errorf("unexpected bar %+v", bar)
+	}
+	if bar.Datetime.Hour() != 1 || bar.Datetime.Minute() != 0 {
+		t.Errorf("bar starts at %s, want 01:00", bar.Datetime)
+	}
+}
+
+func TestResampleDropsFormingBar(t *testing.T) {
+	bars, err := Resample(makeCandles(10), 15*time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("Resample returned error: %v", err)
+	}
+	if len(bars) != 2 {
+		t.Fatalf("got %d bars, want 2 (the third hour is still forming)", len(bars))
+	}
+}
+
+func TestResampleRejectsUnalignedPeriod(t *testing.T) {
+	if _, err := Resample(makeCandles(4), 15*time.Minute, 20*time.Minute); err == nil {
+		t.Error("expected an error for a period that is not a multiple of the base interval")
+	}
+}
+
+func TestClosedIndexHasNoLookAhead(t *testing.T) {
+	base := makeCandles(12)
+	bars, _ := Resample(base, 15*time.Minute, time.Hour)
+	index := ClosedIndex(base, 15*time.Minute, bars, time.Hour)
+
+	want := []int{-1, -1, -1, 0, 0, 0, 0, 1, 1, 1, 1, 2}
+	for i := range want {
+		if index[i] != want[i] {
+			t.Errorf("index[%d] = %d, want %d", i, index[i], want[i])
+		}
+	}
+}
+
+func TestResamplerMatchesResample(t *testing.T) {
+	// Drop the last candle of the second hour and the first of the fourth
+	all := makeCandles(22)
+	base := append(append(all[:7:7], all[8:12]...), all[13:]...)
+	want, _ := Resample(base, 15*time.Minute, time.Hour)
+
+	r, err := NewResampler(15*time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("NewResampler returned error: %v", err)
+	}
+	var got []klinesfrombinance.Candle
+	for i, c := range base {
+		for _, bar := range r.Add(c) {
+			// A bar must never be returned before ClosedIndex considers it closed
+			if closed := ClosedIndex(base[:i+1], 15*time.Minute, want, time.Hour)[i]; closed < len(got) {
+				t.Errorf("bar %s returned at candle %d before it closed", bar.Datetime, i)
+			}
+			got = append(got, bar)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d bars, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("bar %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
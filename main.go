@@ -1,27 +1,215 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	backtest "learnGoLang/Backtest"
+	binanceclient "learnGoLang/BinanceClient"
+	candlestore "learnGoLang/CandleStore"
+	digest "learnGoLang/Digest"
+	execution "learnGoLang/Execution"
 	klinesfrombinance "learnGoLang/KlinesFromBinanace"
 	loadenv "learnGoLang/LoadEnv"
+	sendtelegramnotification "learnGoLang/NotificationTelegram"
+	notifier "learnGoLang/Notifier"
+	risk "learnGoLang/Risk"
+	strategy "learnGoLang/Strategy"
+	timeframe "learnGoLang/Timeframe"
 	"log"
 	"os"
 	"time"
 )
 
 func main() {
-	loadenv.LoadEnv()
+	cfg, err := loadenv.Load("")
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
 	log.Println("Environment variables loaded successfully.")
-	log.Println("Symbol:", loadenv.SYMBOL)
+	log.Println("Symbol:", cfg.Exchange.Symbol)
 	log.SetOutput(os.Stdout)
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 	log.Println("Start date:", time.Now().Format("2006-01-02 15:04:05"))
-	// message := fmt.Sprintf("Ro Bot service started! Symbol:%s | Time:%s", loadenv.SYMBOL, time.Now().Format("2006-01-02 15:04:05"))
-	// sendnotification.SendTelegramNotification(message)
-	_, err := klinesfrombinance.FetchData()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "sync":
+			runSync(cfg, os.Args[2:])
+		case "migrate":
+			runMigrate(cfg, os.Args[2:])
+		case "optimize":
+			runOptimize(cfg, os.Args[2:])
+		case "walkforward":
+			runWalkForward(cfg, os.Args[2:])
+		case "serve":
+			runServe(cfg, os.Args[2:])
+		default:
+			log.Printf("Unknown command '%s' (available: sync, migrate, optimize, walkforward, serve)\n", os.Args[1])
+		}
+		return
+	}
+
+	if cfg.Execution.TradingMode != "backtest" {
+		runServe(cfg, nil)
+		return
+	}
+	candles, err := candlestore.FetchData(context.Background(), cfg)
 	if err != nil {
 		log.Printf("Error updating historical data: %v\n", err)
 		return
 	} else {
 		log.Println("Historical data updated successfully.")
 	}
+	runBacktest(cfg, candles)
+}
+
+// newBroker creates the order executor selected by TRADING_MODE
+func newBroker(cfg loadenv.Config) (execution.Broker, error) {
+	switch cfg.Execution.TradingMode {
+	case "paper":
+		broker, err := execution.NewPaperBroker(cfg.Execution.PaperStateFile, cfg.Execution.PaperInitialBalance,
+			cfg.Strategy.CommissionPercent, cfg.Strategy.SlippagePoints)
+		if err != nil {
+			return nil, err
+		}
+		return broker, nil
+	case "live":
+		baseURL, err := binanceclient.BaseURLFrom(cfg.Exchange.APIBase)
+		if err != nil {
+			return nil, err
+		}
+		client := binanceclient.New(baseURL, cfg.Exchange.APIKey, cfg.Exchange.SecretKey)
+		broker, err := execution.NewLiveBroker(context.Background(), client, cfg.Exchange.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		return broker, nil
+	}
+	return nil, fmt.Errorf("unsupported trading mode '%s'", cfg.Execution.TradingMode)
+}
+
+// newNotifier fans the events out to every configured backend. A backend that cannot
+// be set up is skipped, notifications must never stop the trading. The Telegram
+// notifier is also returned, for the digest charts; it is nil without Telegram.
+func newNotifier(cfg loadenv.Config) (*notifier.Dispatcher, *sendtelegramnotification.Notifier) {
+	minSeverity, err := notifier.ParseSeverity(cfg.Notify.MinSeverity)
+	if err != nil {
+		log.Printf("Invalid NOTIFY_MIN_SEVERITY, sending every notification: %v\n", err)
+	}
+	var routes []notifier.Route
+	var telegram *sendtelegramnotification.Notifier
+	add := func(name string, n notifier.Notifier, err error) {
+		if err != nil {
+			log.Printf("%s notifications are disabled: %v\n", name, err)
+			return
+		}
+		routes = append(routes, notifier.Route{Name: name, Notifier: n, MinSeverity: minSeverity})
+	}
+	if cfg.Telegram.BotToken != "" {
+		n, err := sendtelegramnotification.NewNotifier(cfg.Telegram)
+		add(telegramRoute, n, err)
+		if err == nil {
+			telegram = n
+		}
+	}
+	if cfg.Notify.WebhookURL != "" {
+		n, err := notifier.NewWebhookNotifier(cfg.Notify.WebhookURL, cfg.Notify.WebhookFormat)
+		add("webhook", n, err)
+	}
+	if cfg.Notify.SMTPAddr != "" {
+		n, err := notifier.NewEmailNotifier(cfg.Notify.SMTPAddr, cfg.Notify.SMTPUsername, cfg.Notify.SMTPPassword, cfg.Notify.EmailFrom, cfg.Notify.EmailTo)
+		add("email", n, err)
+	}
+	if cfg.Notify.File != "" {
+		add("file", notifier.NewFileNotifier(cfg.Notify.File), nil)
+	}
+	return notifier.NewDispatcher(cfg.Notify.QueueSize, routes...), telegram
+}
+
+// telegramRoute is the name of the Telegram route of the dispatcher
+const telegramRoute = "Telegram"
+
+// telegramPhotos sends images through the Telegram route of the dispatcher, so a chart
+// arrives after the digest queued before it
+type telegramPhotos struct {
+	dispatcher *notifier.Dispatcher
+	telegram   *sendtelegramnotification.Notifier
+}
+
+func (p telegramPhotos) SendPhoto(ctx context.Context, png []byte, caption string) error {
+	return p.dispatcher.Do(telegramRoute, func(ctx context.Context) error {
+		return p.telegram.SendPhoto(ctx, png, caption)
+	})
+}
+
+// startDigest sends the performance digests of the trader in the background when
+// DIGEST_PERIOD is set
+func startDigest(ctx context.Context, cfg loadenv.Config, trader *execution.Trader, n *notifier.Dispatcher, telegram *sendtelegramnotification.Notifier) {
+	digestConfig, err := digest.ConfigFromEnv(cfg.Digest)
+	if err != nil {
+		log.Printf("Digests are disabled: %v\n", err)
+		return
+	}
+	if digestConfig.Period == digest.Off {
+		return
+	}
+	var photos digest.PhotoSender
+	if telegram != nil {
+		photos = telegramPhotos{dispatcher: n, telegram: telegram}
+	}
+	go digest.NewScheduler(digestConfig, trader, n, photos).Run(ctx)
+}
+
+// runBacktest simulates the strategy over the historical candles and writes the trade log
+func runBacktest(cfg loadenv.Config, candles []klinesfrombinance.Candle) {
+	params := backtest.ParamsFromConfig(cfg.Strategy)
+	params.Risk = risk.ConfigFromEnv(cfg.Risk)
+	params.InitialEquity = cfg.Risk.InitialEquity
+	baseInterval, err := klinesfrombinance.IntervalDuration(cfg.Exchange.Interval)
+	if err != nil {
+		log.Printf("Error reading candle interval: %v\n", err)
+		return
+	}
+	entryCandles, err := timeframe.Resample(candles, baseInterval, params.EntryInterval)
+	if err != nil {
+		log.Printf("Error resampling candles to the entry timeframe: %v\n", err)
+		return
+	}
+
+	strat, err := strategy.New(cfg.Strategy)
+	if err != nil {
+		log.Printf("Error creating strategy: %v\n", err)
+		return
+	}
+
+	result, err := backtest.Run(entryCandles, strat, params)
+	if err != nil {
+		log.Printf("Error running backtest: %v\n", err)
+		return
+	}
+	log.Printf("Backtest of %s finished: %d trades, %d wins, %d losses, total return %.2f%%, final equity %.2f\n",
+		strat.Name(), len(result.Trades), result.Wins, result.Losses, result.TotalReturnPct, result.FinalEquity)
+	if result.RefusedEntries > 0 {
+		log.Printf("%d entries refused by MAX_ALLOWED_SL_PCT or the risk limits\n", result.RefusedEntries)
+	}
+	if err := backtest.WriteTradeLog(cfg.Data.OutputFileName, result.Trades); err != nil {
+		log.Printf("Error writing trade log: %v\n", err)
+		return
+	}
+	log.Println("Trade log written to", cfg.Data.OutputFileName)
+
+	report := backtest.NewReport(strat.Name(), entryCandles, params.EntryInterval, result)
+	m := report.Metrics
+	log.Printf("CAGR %.2f%%, Sharpe %.2f, Sortino %.2f, max drawdown %.2f%% lasting %.0fh, profit factor %.2f, exposure %.1f%%\n",
+		m.CAGRPct, m.Sharpe, m.Sortino, m.MaxDrawdownPct, m.MaxDrawdownHours, m.ProfitFactor, m.ExposurePct)
+	jsonPath, htmlPath := backtest.ReportPaths(cfg.Data.OutputFileName)
+	if err := backtest.WriteReportJSON(jsonPath, report); err != nil {
+		log.Printf("Error writing report: %v\n", err)
+		return
+	}
+	if err := backtest.WriteReportHTML(htmlPath, report); err != nil {
+		log.Printf("Error writing report: %v\n", err)
+		return
+	}
+	log.Println("Report written to", jsonPath, "and", htmlPath)
 }
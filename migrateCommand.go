@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	candlestore "learnGoLang/CandleStore"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	loadenv "learnGoLang/LoadEnv"
+	"log"
+)
+
+// runMigrate copies the history of one series from one storage backend to another, e.g.
+//
+//	go run . migrate -to sqlite -to-path data/candles.db
+//
+// The source defaults to the configured DATA_STORE, DATA_FILE_PATH, SYMBOL and BINANCE_INTERVAL.
+func runMigrate(cfg loadenv.Config, args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := flags.String("from", cfg.Data.Store, "source backend: csv, sqlite or parquet")
+	fromPath := flags.String("from-path", candlestore.DataPath(cfg), "source file or database")
+	to := flags.String("to", "", "destination backend: csv, sqlite or parquet")
+	toPath := flags.String("to-path", "", "destination file or database")
+	symbol := flags.String("symbol", cfg.Exchange.Symbol, "symbol of the series")
+	interval := flags.String("interval", cfg.Exchange.Interval, "interval of the series")
+	flags.Parse(args)
+
+	if *to == "" || *toPath == "" {
+		log.Println("Both -to and -to-path must be given")
+		flags.Usage()
+		return
+	}
+	if *from == *to && *fromPath == *toPath {
+		log.Println("Source and destination are the same store")
+		return
+	}
+	policy, err := klinesfrombinance.ParseBadRowPolicy(cfg.Data.BadRowPolicy)
+	if err != nil {
+		log.Printf("Error reading BAD_ROW_POLICY: %v\n", err)
+		return
+	}
+
+	src, err := candlestore.Open(*from, *fromPath, *symbol, *interval, policy)
+	if err != nil {
+		log.Printf("Error opening source store: %v\n", err)
+		return
+	}
+	defer src.Close()
+	dst, err := candlestore.Open(*to, *toPath, *symbol, *interval, policy)
+	if err != nil {
+		log.Printf("Error opening destination store: %v\n", err)
+		return
+	}
+	defer dst.Close()
+
+	log.Printf("Migrating %s %s from %s %s to %s %s\n", *symbol, *interval, *from, *fromPath, *to, *toPath)
+	copied, err := candlestore.Migrate(context.Background(), src, dst)
+	if err != nil {
+		log.Printf("Migration failed after %d candles: %v\n", copied, err)
+		return
+	}
+	log.Printf("Migrated %d candles. Set DATA_STORE=%s and DATA_FILE_PATH=%s to use the new store.\n", copied, *to, *toPath)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	candlestore "learnGoLang/CandleStore"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	loadenv "learnGoLang/LoadEnv"
+	optimizer "learnGoLang/Optimizer"
+	risk "learnGoLang/Risk"
+	timeframe "learnGoLang/Timeframe"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// rangeFlags collects the repeated -param flags
+type rangeFlags []string
+
+func (r *rangeFlags) String() string {
+	return strings.Join(*r, " ")
+}
+
+func (r *rangeFlags) Set(value string) error {
+	*r = append(*r, value)
+	return nil
+}
+
+// searchFlags are the flags of the parameter search shared by optimize and walkforward
+type searchFlags struct {
+	params    rangeFlags
+	objective *string
+	random    *int
+	seed      *int64
+	workers   *int
+	minTrades *int
+}
+
+func addSearchFlags(flags *flag.FlagSet) *searchFlags {
+	s := &searchFlags{}
+	flags.Var(&s.params, "param", "NAME=min:max:step or NAME=v1,v2,... to vary, repeatable (e.g. FAST_LENGTH=8:16:2)")
+	s.objective = flags.String("objective", string(optimizer.Sharpe), "ranking objective: sharpe, profit_factor, max_drawdown or return")
+	s.random = flags.Int("random", 0, "try this many random combinations instead of the whole grid")
+	s.seed = flags.Int64("seed", time.Now().UnixNano(), "seed of the random search")
+	s.workers = flags.Int("workers", runtime.NumCPU(), "backtests run in parallel")
+	s.minTrades = flags.Int("min-trades", 10, "runs with fewer trades are not ranked")
+	return s
+}
+
+// options parses the ranges and the objective into the options of a search
+func (s *searchFlags) options(cfg loadenv.Config) (optimizer.Options, error) {
+	if len(s.params) == 0 {
+		return optimizer.Options{}, fmt.Errorf("at least one -param is needed (supported: %s)", strings.Join(optimizer.Parameters(), ", "))
+	}
+	sizing := risk.ConfigFromEnv(cfg.Risk)
+	if err := sizing.Validate(); err != nil {
+		return optimizer.Options{}, err
+	}
+	opts := optimizer.Options{
+		Base:      cfg.Strategy,
+		Risk:      sizing,
+		Equity:    cfg.Risk.InitialEquity,
+		Random:    *s.random,
+		Seed:      *s.seed,
+		Workers:   *s.workers,
+		MinTrades: *s.minTrades,
+	}
+	for _, p := range s.params {
+		r, err := optimizer.ParseRange(p)
+		if err != nil {
+			return optimizer.Options{}, fmt.Errorf("error reading -param: %w", err)
+		}
+		opts.Ranges = append(opts.Ranges, r)
+	}
+	objective, err := optimizer.ParseObjective(*s.objective)
+	if err != nil {
+		return optimizer.Options{}, fmt.Errorf("error reading -objective: %w", err)
+	}
+	opts.Objective = objective
+	return opts, nil
+}
+
+// runOptimize backtests combinations of strategy settings over the stored candles and
+// ranks them, e.g.
+//
+//	go run . optimize -param FAST_LENGTH=8:16:2 -param SLOW_LENGTH=20:32:4 -param STOP_LOSS_PCT=1,2,3 -objective profit_factor
+//
+// Settings that are not varied keep their .env values.
+func runOptimize(cfg loadenv.Config, args []string) {
+	flags := flag.NewFlagSet("optimize", flag.ExitOnError)
+	search := addSearchFlags(flags)
+	heatmap := flags.String("heatmap", "", "X,Y parameters of the heatmap (default: the first two -param names)")
+	top := flags.Int("top", 10, "number of best runs to log")
+	flags.Parse(args)
+
+	opts, err := search.options(cfg)
+	if err != nil {
+		log.Printf("Invalid optimization settings: %v\n", err)
+		flags.Usage()
+		return
+	}
+	ranges, objective := opts.Ranges, opts.Objective
+
+	candles, err := loadEntryCandles(cfg)
+	if err != nil {
+		log.Printf("Error loading candles: %v\n", err)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	log.Printf("Optimizing %s over %d candles with %d workers\n", cfg.Strategy.Name, len(candles), opts.Workers)
+	report, err := optimizer.Optimize(ctx, candles, opts)
+	if err != nil {
+		log.Printf("Optimization failed: %v\n", err)
+		return
+	}
+	log.Printf("Optimization finished: %d runs, %d combinations rejected by the strategy\n", len(report.Runs), report.Skipped)
+	for _, run := range report.Runs[:min(*top, len(report.Runs))] {
+		if run.Rank == 0 {
+			break
+		}
+		log.Printf("#%d %s: %s %.4f, %d trades, return %.2f%%, max drawdown %.2f%%\n", run.Rank, describeRun(ranges, run),
+			objective, run.Score, run.Metrics.Trades, run.Metrics.TotalReturnPct, run.Metrics.MaxDrawdownPct)
+	}
+
+	resultsPath, heatmapPath := optimizer.OutputPaths(cfg.Data.OutputFileName)
+	if err := optimizer.WriteResults(resultsPath, ranges, report); err != nil {
+		log.Printf("Error writing results: %v\n", err)
+		return
+	}
+	log.Println("Results written to", resultsPath)
+
+	x, y, ok := strings.Cut(*heatmap, ",")
+	if *heatmap == "" && len(ranges) >= 2 {
+		x, y, ok = ranges[0].Name, ranges[1].Name, true
+	}
+	if !ok {
+		return
+	}
+	if err := optimizer.WriteHeatmap(heatmapPath, ranges, report, strings.TrimSpace(x), strings.TrimSpace(y), objective); err != nil {
+		log.Printf("Error writing heatmap: %v\n", err)
+		return
+	}
+	log.Println("Heatmap written to", heatmapPath)
+}
+
+// loadEntryCandles reads the configured history from the local store, without
+// downloading, and resamples it to the entry timeframe
+func loadEntryCandles(cfg loadenv.Config) ([]klinesfrombinance.Candle, error) {
+	policy, err := klinesfrombinance.ParseBadRowPolicy(cfg.Data.BadRowPolicy)
+	if err != nil {
+		return nil, err
+	}
+	store, err := candlestore.Open(cfg.Data.Store, candlestore.DataPath(cfg), cfg.Exchange.Symbol, cfg.Exchange.Interval, policy)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	candles, err := klinesfrombinance.AllCandles(context.Background(), store)
+	if err != nil {
+		return nil, err
+	}
+
+	baseInterval, err := klinesfrombinance.IntervalDuration(cfg.Exchange.Interval)
+	if err != nil {
+		return nil, err
+	}
+	return timeframe.Resample(candles, baseInterval, time.Duration(cfg.Strategy.EntryTFMinutes)*time.Minute)
+}
+
+// describeRun formats the parameter values of a run as NAME=value pairs
+func describeRun(ranges []optimizer.Range, run optimizer.Run) string {
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		parts[i] = fmt.Sprintf("%s=%g", r.Name, run.Values[i])
+	}
+	return strings.Join(parts, " ")
+}
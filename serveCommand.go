@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	candlestore "learnGoLang/CandleStore"
+	execution "learnGoLang/Execution"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	loadenv "learnGoLang/LoadEnv"
+	sendtelegramnotification "learnGoLang/NotificationTelegram"
+	notifier "learnGoLang/Notifier"
+	positionmanager "learnGoLang/PositionManager"
+	risk "learnGoLang/Risk"
+	strategy "learnGoLang/Strategy"
+	streamklines "learnGoLang/StreamKlines"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout limits how long closing the position and delivering the last
+// notifications may take once the service is asked to stop
+const shutdownTimeout = 30 * time.Second
+
+// runServe runs the bot as a long-running service: it backfills the candle history, then
+// trades the streamed candles in paper or live mode until SIGINT or SIGTERM. Every
+// closed candle is saved as it arrives. On the way out the position is optionally sold
+// and the pending notifications are delivered.
+func runServe(cfg loadenv.Config, args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	closeOnShutdown := flags.Bool("close-on-shutdown", cfg.Execution.CloseOnShutdown, "sell the open position before exiting")
+	flags.Parse(args)
+
+	if cfg.Execution.TradingMode == "backtest" {
+		log.Println("The service trades in paper or live mode, set TRADING_MODE accordingly")
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	notifications, telegram := newNotifier(cfg)
+	defer func() {
+		shutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := notifications.Close(shutdown); err != nil {
+			log.Printf("Error flushing notifications: %v\n", err)
+		}
+	}()
+
+	started := time.Now()
+	notifications.Notify(ctx, notifier.Message(notifier.Info, fmt.Sprintf("Ro Bot service started! Symbol:%s | Mode:%s | Time:%s",
+		cfg.Exchange.Symbol, cfg.Execution.TradingMode, started.Format("2006-01-02 15:04:05")), started))
+
+	err := serve(ctx, cfg, notifications, telegram, *closeOnShutdown)
+	severity, message := notifier.Info, fmt.Sprintf("Ro Bot service stopped. Symbol:%s | Uptime:%s | Time:%s",
+		cfg.Exchange.Symbol, time.Since(started).Round(time.Second), time.Now().Format("2006-01-02 15:04:05"))
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Service failed: %v\n", err)
+		severity, message = notifier.Error, fmt.Sprintf("%s | Error:%v", message, err)
+	}
+	log.Println(message)
+	notifications.Notify(context.Background(), notifier.Message(severity, message, time.Now()))
+}
+
+// serve backfills the history and trades until ctx is cancelled
+func serve(ctx context.Context, cfg loadenv.Config, notifications *notifier.Dispatcher, telegram *sendtelegramnotification.Notifier, closeOnShutdown bool) error {
+	candles, err := candlestore.FetchData(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to update historical data: %w", err)
+	}
+	log.Println("Historical data updated successfully.")
+
+	store, err := candlestore.OpenData(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to open candle store: %w", err)
+	}
+	defer store.Close()
+	broker, err := newBroker(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create %s broker: %w", cfg.Execution.TradingMode, err)
+	}
+	return runTrading(ctx, cfg, broker, store, candles, notifications, telegram, closeOnShutdown)
+}
+
+// runTrading streams live candles through the strategy into the broker and saves them
+// to the store until ctx is cancelled, then shuts the trading down
+func runTrading(ctx context.Context, cfg loadenv.Config, broker execution.Broker, store klinesfrombinance.CandleStore, candles []klinesfrombinance.Candle,
+	notifications *notifier.Dispatcher, telegram *sendtelegramnotification.Notifier, closeOnShutdown bool) error {
+	strat, err := strategy.New(cfg.Strategy)
+	if err != nil {
+		return fmt.Errorf("failed to create strategy: %w", err)
+	}
+	baseInterval, err := klinesfrombinance.IntervalDuration(cfg.Exchange.Interval)
+	if err != nil {
+		return fmt.Errorf("failed to read candle interval: %w", err)
+	}
+	entryInterval := time.Duration(cfg.Strategy.EntryTFMinutes) * time.Minute
+	riskConfig := risk.ConfigFromEnv(cfg.Risk)
+	if err := riskConfig.Validate(); err != nil {
+		return fmt.Errorf("invalid risk settings: %w", err)
+	}
+	if err := riskConfig.ValidateStops(cfg.Strategy.StopLossPct, cfg.Strategy.SwingStopBars > 0); err != nil {
+		return fmt.Errorf("invalid risk settings: %w", err)
+	}
+	trader, err := execution.NewTrader(broker, strat, cfg.Exchange.Symbol, baseInterval, entryInterval,
+		positionmanager.ConfigFromStrategy(cfg.Strategy), risk.NewManager(riskConfig))
+	if err != nil {
+		return fmt.Errorf("failed to create trader: %w", err)
+	}
+	trader.Notifier = notifications
+
+	if err := trader.Warmup(ctx, candles); err != nil {
+		return fmt.Errorf("failed to warm up %s strategy: %w", strat.Name(), err)
+	}
+	log.Printf("Trading %s with the %s strategy in %s mode\n", cfg.Exchange.Symbol, strat.Name(), cfg.Execution.TradingMode)
+
+	startDigest(ctx, cfg, trader, notifications, telegram)
+	if cfg.Telegram.BotToken != "" {
+		bot, err := sendtelegramnotification.NewCommandBot(cfg, trader)
+		if err != nil {
+			log.Printf("Telegram commands are disabled: %v\n", err)
+		} else {
+			go bot.Run(ctx)
+		}
+	}
+
+	stream := streamklines.New(cfg.Exchange)
+	if len(candles) > 0 {
+		stream.ResumeFrom(candles[len(candles)-1].Timestamp)
+	}
+	live, err := stream.Start(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start kline stream: %w", err)
+	}
+
+	for c := range live {
+		log.Printf("Closed candle %s: O %.2f H %.2f L %.2f C %.2f\n", c.Datetime.Format("2006-01-02 15:04:05"), c.Open, c.High, c.Low, c.Close)
+		// Saved right away, so the next start only backfills what was missed while the service was down
+		if err := store.Append(ctx, []klinesfrombinance.Candle{c}); err != nil {
+			log.Printf("Error saving candle: %v\n", err)
+		}
+		fills, err := trader.OnCandle(ctx, c)
+		if err != nil {
+			log.Printf("Error processing candle: %v\n", err)
+			notifications.Notify(ctx, notifier.ErrorOccurred(cfg.Exchange.Symbol, err, c.Datetime))
+		}
+		for _, f := range fills {
+			log.Printf("Order %s %s %s %.6f @ %.2f: %s %s\n", f.Order.ID, f.Order.Side, f.Order.Type,
+				f.Order.Quantity, f.Order.FillPrice, f.Order.Status, f.Order.Reason)
+		}
+	}
+	log.Println("Trading stopped.")
+	shutdownTrading(trader, closeOnShutdown)
+	return nil
+}
+
+// shutdownTrading sells the position when asked to. The candles are already saved and
+// the paper broker saves its state after every change, so nothing needs flushing.
+func shutdownTrading(trader *execution.Trader, closeOnShutdown bool) {
+	if !closeOnShutdown {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	order, err := trader.CloseNow(ctx)
+	switch {
+	case err != nil:
+		log.Printf("Error closing the position on shutdown: %v\n", err)
+	case order.ID == "":
+		log.Println("No position to close on shutdown.")
+	case order.Status == execution.StatusNew:
+		log.Printf("Sell order %s placed on shutdown is still waiting for a fill\n", order.ID)
+	default:
+		log.Printf("Sell order %s placed on shutdown: %s %.6f @ %.2f\n", order.ID, order.Status, order.Quantity, order.FillPrice)
+	}
+}
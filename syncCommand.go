@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	klinesfrombinance "learnGoLang/KlinesFromBinanace"
+	loadenv "learnGoLang/LoadEnv"
+	syncdata "learnGoLang/SyncData"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+)
+
+// runSync updates the history file of every SYMBOL:interval pair given on the command
+// line, or of SYNC_PAIRS when none are given
+func runSync(cfg loadenv.Config, args []string) {
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	workers := flags.Int("workers", cfg.Data.SyncWorkers, "number of pairs downloaded in parallel")
+	dataDir := flags.String("dir", filepath.Dir(cfg.Data.FilePath), "folder of the per-pair history files")
+	flags.Parse(args)
+
+	entries := flags.Args()
+	if len(entries) == 0 {
+		entries = cfg.Data.SyncPairs
+	}
+	pairs, err := syncdata.ParsePairs(entries)
+	if err != nil {
+		log.Printf("Error reading pairs: %v\n", err)
+		return
+	}
+	policy, err := klinesfrombinance.ParseBadRowPolicy(cfg.Data.BadRowPolicy)
+	if err != nil {
+		log.Printf("Error reading BAD_ROW_POLICY: %v\n", err)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	log.Printf("Syncing %d pairs with %d workers into %s\n", len(pairs), *workers, *dataDir)
+	results := syncdata.Sync(ctx, pairs, syncdata.Options{
+		APIBase:      cfg.Exchange.APIBase,
+		DataDir:      *dataDir,
+		Workers:      *workers,
+		Limiter:      klinesfrombinance.NewRateLimiter(cfg.Exchange.WeightPerMinute),
+		BadRowPolicy: policy,
+		Store:        cfg.Data.Store,
+	})
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	log.Printf("Sync finished: %d pairs updated, %d failed\n", len(results)-failed, failed)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	loadenv "learnGoLang/LoadEnv"
+	optimizer "learnGoLang/Optimizer"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// runWalkForward optimizes the strategy settings on a rolling in-sample window and tests
+// the best ones on the window that follows, e.g.
+//
+//	go run . walkforward -in-sample-days 90 -out-of-sample-days 30 -param FAST_LENGTH=8:16:2 -param SLOW_LENGTH=20:32:4
+//
+// The stitched out-of-sample results show how the parameters hold up on data they were
+// not fitted to.
+func runWalkForward(cfg loadenv.Config, args []string) {
+	flags := flag.NewFlagSet("walkforward", flag.ExitOnError)
+	search := addSearchFlags(flags)
+	inSampleDays := flags.Int("in-sample-days", 90, "days of the window the parameters are optimized on")
+	outOfSampleDays := flags.Int("out-of-sample-days", 30, "days of the window they are tested on, also the roll step")
+	flags.Parse(args)
+
+	opts, err := search.options(cfg)
+	if err != nil {
+		log.Printf("Invalid optimization settings: %v\n", err)
+		flags.Usage()
+		return
+	}
+
+	candles, err := loadEntryCandles(cfg)
+	if err != nil {
+		log.Printf("Error loading candles: %v\n", err)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	log.Printf("Walk-forward of %s over %d candles: %d day in-sample and %d day out-of-sample windows\n",
+		cfg.Strategy.Name, len(candles), *inSampleDays, *outOfSampleDays)
+	report, err := optimizer.WalkForward(ctx, candles, optimizer.WalkForwardOptions{
+		Options:     opts,
+		InSample:    time.Duration(*inSampleDays) * 24 * time.Hour,
+		OutOfSample: time.Duration(*outOfSampleDays) * 24 * time.Hour,
+	})
+	if err != nil {
+		log.Printf("Walk-forward failed: %v\n", err)
+		return
+	}
+
+	m := report.OutOfSample
+	log.Printf("Out-of-sample over %d windows: %d trades, return %.2f%%, max drawdown %.2f%%, profit factor %.2f, Sharpe %.2f\n",
+		len(report.Windows), m.Trades, m.TotalReturnPct, m.MaxDrawdownPct, m.ProfitFactor, m.Sharpe)
+	if math.IsNaN(report.Efficiency) {
+		log.Println("Walk-forward efficiency is undefined, the best in-sample runs did not make money")
+	} else {
+		log.Printf("Walk-forward efficiency: %.1f%%\n", report.Efficiency)
+	}
+	for _, s := range report.Stability {
+		log.Printf("%s: mean %.2f, std dev %.2f, changed %d times\n", s.Name, s.Mean, s.StdDev, s.Changes)
+	}
+
+	windowsPath, equityPath := optimizer.WalkForwardPaths(cfg.Data.OutputFileName)
+	if err := optimizer.WriteWindows(windowsPath, opts.Ranges, report); err != nil {
+		log.Printf("Error writing windows: %v\n", err)
+		return
+	}
+	log.Println("Windows written to", windowsPath)
+	if err := optimizer.WriteEquity(equityPath, report); err != nil {
+		log.Printf("Error writing equity curve: %v\n", err)
+		return
+	}
+	log.Println("Out-of-sample equity curve written to", equityPath)
+}